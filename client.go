@@ -0,0 +1,125 @@
+/*
+Package onetable – DynamoDB client construction.
+
+NewClient builds an AWS SDK v2 DynamoDB client from a few options so small
+tools don't need the SDK configuration boilerplate before NewTable. The
+configuration is loaded with config.LoadDefaultConfig, so environment
+variables, shared profiles, SSO and instance roles work as in any SDK client.
+*/
+package onetable
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+// ClientOptions configures NewClient.
+type ClientOptions struct {
+	// Region defaults to the region of the SDK configuration (AWS_REGION or
+	// the shared profile).
+	Region string
+	// Endpoint overrides the DynamoDB endpoint, e.g. "http://localhost:8000"
+	// for DynamoDB Local.
+	Endpoint string
+	// Credentials defaults to the SDK's default credential chain, or to dummy
+	// credentials when Endpoint is set and AWS_ACCESS_KEY_ID is not.
+	Credentials aws.CredentialsProvider
+	// MaxAttempts and MaxBackoff override the SDK's standard retryer
+	// (3 attempts, 20s).
+	MaxAttempts int
+	MaxBackoff  time.Duration
+	// AssumeRole, if set, signs requests with credentials of the role assumed
+	// with Credentials.
+	AssumeRole *AssumeRole
+	// HTTPClient defaults to the SDK's HTTP client.
+	HTTPClient aws.HTTPClient
+}
+
+// AssumeRole configures the STS AssumeRole call of NewClient.
+type AssumeRole struct {
+	RoleARN     string
+	SessionName string        // default "onetable"
+	ExternalID  string        // optional
+	Duration    time.Duration // default 1h
+	// Endpoint defaults to the regional STS endpoint.
+	Endpoint string
+}
+
+// NewClient returns a DynamoDB client for opts. With AssumeRole, the role is
+// assumed once with ctx so configuration errors surface here; the credentials
+// are refreshed before they expire.
+func NewClient(ctx context.Context, opts ClientOptions) (*dynamodb.Client, error) {
+	var load []func(*config.LoadOptions) error
+	if opts.Region != "" {
+		load = append(load, config.WithRegion(opts.Region))
+	}
+	if opts.HTTPClient != nil {
+		load = append(load, config.WithHTTPClient(opts.HTTPClient))
+	}
+	if opts.MaxAttempts > 0 || opts.MaxBackoff > 0 {
+		load = append(load, config.WithRetryer(func() aws.Retryer {
+			return retry.NewStandard(func(o *retry.StandardOptions) {
+				if opts.MaxAttempts > 0 {
+					o.MaxAttempts = opts.MaxAttempts
+				}
+				if opts.MaxBackoff > 0 {
+					o.MaxBackoff = opts.MaxBackoff
+				}
+			})
+		}))
+	}
+	creds := opts.Credentials
+	if creds == nil && opts.Endpoint != "" && os.Getenv("AWS_ACCESS_KEY_ID") == "" {
+		// DynamoDB Local accepts any credentials
+		creds = credentials.NewStaticCredentialsProvider("local", "local", "")
+	}
+	if creds != nil {
+		load = append(load, config.WithCredentialsProvider(creds))
+	}
+	cfg, err := config.LoadDefaultConfig(ctx, load...)
+	if err != nil {
+		return nil, NewError("Cannot load AWS configuration", WithCode(ErrRuntime), WithCause(err))
+	}
+	if cfg.Region == "" {
+		return nil, NewArgError("Missing Region")
+	}
+
+	if role := opts.AssumeRole; role != nil {
+		if role.RoleARN == "" {
+			return nil, NewArgError("Missing AssumeRole.RoleARN")
+		}
+		client := sts.NewFromConfig(cfg, func(o *sts.Options) {
+			if role.Endpoint != "" {
+				o.BaseEndpoint = aws.String(role.Endpoint)
+			}
+		})
+		cfg.Credentials = aws.NewCredentialsCache(stscreds.NewAssumeRoleProvider(client, role.RoleARN,
+			func(o *stscreds.AssumeRoleOptions) {
+				o.RoleSessionName = cmp.Or(role.SessionName, "onetable")
+				o.Duration = cmp.Or(role.Duration, time.Hour)
+				if role.ExternalID != "" {
+					o.ExternalID = aws.String(role.ExternalID)
+				}
+			}))
+		if _, err := cfg.Credentials.Retrieve(ctx); err != nil {
+			return nil, NewError(fmt.Sprintf(`Cannot assume role "%s"`, role.RoleARN), WithCode(ErrRuntime), WithCause(err))
+		}
+	}
+
+	return dynamodb.NewFromConfig(cfg, func(o *dynamodb.Options) {
+		if opts.Endpoint != "" {
+			o.BaseEndpoint = aws.String(opts.Endpoint)
+		}
+	}), nil
+}
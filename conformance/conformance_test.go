@@ -0,0 +1,335 @@
+package conformance
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	ot "github.com/cloudxsgmbh/dynamodb-onetable-go"
+)
+
+// conformanceSchema returns a fresh schema using the given schema params.
+func conformanceSchema(params *ot.SchemaParams) *ot.SchemaDef {
+	hidden, shown := true, false
+	return &ot.SchemaDef{
+		Version: "0.0.1",
+		Indexes: map[string]*ot.IndexDef{"primary": {Hash: "pk", Sort: "sk"}},
+		Models: map[string]ot.ModelDef{
+			"Account": {
+				"pk":      {Type: ot.FieldTypeString, Value: "account#${id}"},
+				"sk":      {Type: ot.FieldTypeString, Value: "account#"},
+				"id":      {Type: ot.FieldTypeString, Generate: "ulid"},
+				"name":    {Type: ot.FieldTypeString, Required: true},
+				"email":   {Type: ot.FieldTypeString, Unique: true},
+				"status":  {Type: ot.FieldTypeString, Default: "active"},
+				"balance": {Type: ot.FieldTypeNumber},
+				"secret":  {Type: ot.FieldTypeString, Hidden: &hidden},
+				"label":   {Type: ot.FieldTypeString, Value: "${name}:${status}", Hidden: &shown},
+				"joined":  {Type: ot.FieldTypeDate},
+				"address": {Type: ot.FieldTypeObject, Schema: ot.FieldMap{
+					"street": {Type: ot.FieldTypeString},
+					"city":   {Type: ot.FieldTypeString},
+				}},
+			},
+		},
+		Params: params,
+	}
+}
+
+type conformanceCase struct {
+	area     string
+	behavior string
+	params   *ot.SchemaParams // schema params (nil = defaults)
+	partial  bool             // TableParams.Partial
+	check    func(t *testing.T, Account *ot.Model, st *store)
+}
+
+// absent is the expected value of a property JS OneTable does not return.
+var absent = struct{ absent bool }{true}
+
+var conformanceCases = []conformanceCase{
+	// ─── defaults ────────────────────────────────────────────────────────────
+	{area: "defaults", behavior: "default applied on create",
+		check: func(t *testing.T, Account *ot.Model, _ *store) {
+			item := mustCreate(t, Account, ot.Item{"name": "a"})
+			expectProperty(t, item, "status", "active")
+		}},
+	{area: "defaults", behavior: "default applied when upsert creates the item",
+		check: func(t *testing.T, Account *ot.Model, _ *store) {
+			item, err := Account.Upsert(bg(), ot.Item{"id": "u1", "name": "a"}, nil)
+			if err != nil {
+				t.Fatalf("Upsert: %v", err)
+			}
+			expectProperty(t, item, "status", "active")
+		}},
+	{area: "defaults", behavior: "default does not overwrite a value on update",
+		check: func(t *testing.T, Account *ot.Model, _ *store) {
+			item := mustCreate(t, Account, ot.Item{"name": "a", "status": "closed"})
+			item, err := Account.Update(bg(), ot.Item{"id": item["id"], "balance": 5}, nil)
+			if err != nil {
+				t.Fatalf("Update: %v", err)
+			}
+			expectProperty(t, item, "status", "closed")
+		}},
+	{area: "defaults", behavior: "generate: ulid fills the id on create",
+		check: func(t *testing.T, Account *ot.Model, _ *store) {
+			item := mustCreate(t, Account, ot.Item{"name": "a"})
+			id, _ := item["id"].(string)
+			expect(t, "id is a 26 character ULID", true, len(id) == 26 && strings.ToUpper(id) == id)
+		}},
+
+	// ─── validation ──────────────────────────────────────────────────────────
+	{area: "validation", behavior: "required field missing on create fails",
+		check: func(t *testing.T, Account *ot.Model, _ *store) {
+			_, err := Account.Create(bg(), ot.Item{"email": "a@example.com"}, nil)
+			expectErrCode(t, err, ot.ErrValidation)
+		}},
+	{area: "validation", behavior: "update of a missing item fails (exists: true)",
+		check: func(t *testing.T, Account *ot.Model, st *store) {
+			_, err := Account.Update(bg(), ot.Item{"id": "missing", "balance": 1}, nil)
+			expect(t, "update fails", true, err != nil)
+			expect(t, "stored items", 0, st.len())
+		}},
+
+	// ─── hidden ──────────────────────────────────────────────────────────────
+	{area: "hidden", behavior: "value-template and type fields are hidden by default",
+		check: func(t *testing.T, Account *ot.Model, _ *store) {
+			item := mustCreate(t, Account, ot.Item{"name": "a"})
+			for _, key := range []string{"pk", "sk", "_type"} {
+				expectProperty(t, item, key, absent)
+			}
+		}},
+	{area: "hidden", behavior: "hidden: false returns a value-template field",
+		check: func(t *testing.T, Account *ot.Model, _ *store) {
+			item := mustCreate(t, Account, ot.Item{"name": "a"})
+			expectProperty(t, item, "label", "a:active")
+		}},
+	{area: "hidden", behavior: "hidden: true field is stored but not returned",
+		check: func(t *testing.T, Account *ot.Model, st *store) {
+			item := mustCreate(t, Account, ot.Item{"name": "a", "secret": "s"})
+			item, _ = Account.Get(bg(), ot.Item{"id": item["id"]}, nil)
+			expectProperty(t, item, "secret", absent)
+			expect(t, "stored secret", "s", avString(storedAccount(st, item["id"])["secret"]))
+		}},
+	{area: "hidden", behavior: "params.hidden returns hidden fields",
+		check: func(t *testing.T, Account *ot.Model, _ *store) {
+			item := mustCreate(t, Account, ot.Item{"name": "a", "secret": "s"})
+			hidden := true
+			item, _ = Account.Get(bg(), ot.Item{"id": item["id"]}, &ot.Params{Hidden: &hidden})
+			expectProperty(t, item, "pk", fmt.Sprintf("account#%v", item["id"]))
+			expectProperty(t, item, "_type", "Account")
+			expectProperty(t, item, "secret", "s")
+		}},
+
+	// ─── timestamps ──────────────────────────────────────────────────────────
+	{area: "timestamps", behavior: "timestamps: true sets created and updated",
+		params: &ot.SchemaParams{Timestamps: true},
+		check: func(t *testing.T, Account *ot.Model, _ *store) {
+			item := mustCreate(t, Account, ot.Item{"name": "a"})
+			created, _ := item["created"].(time.Time)
+			time.Sleep(2 * time.Millisecond)
+			item, err := Account.Update(bg(), ot.Item{"id": item["id"], "balance": 1}, nil)
+			if err != nil {
+				t.Fatalf("Update: %v", err)
+			}
+			expect(t, "created set on create", true, !created.IsZero())
+			expectProperty(t, item, "created", created)
+			updated, _ := item["updated"].(time.Time)
+			expect(t, "updated advanced by update", true, updated.After(created))
+		}},
+	{area: "timestamps", behavior: `timestamps: "create" sets only created`,
+		params: &ot.SchemaParams{Timestamps: "create"},
+		check: func(t *testing.T, Account *ot.Model, _ *store) {
+			item := mustCreate(t, Account, ot.Item{"name": "a"})
+			expect(t, "created set", true, item["created"] != nil)
+			expectProperty(t, item, "updated", absent)
+		}},
+	{area: "timestamps", behavior: `timestamps: "update" sets only updated`,
+		params: &ot.SchemaParams{Timestamps: "update"},
+		check: func(t *testing.T, Account *ot.Model, _ *store) {
+			item := mustCreate(t, Account, ot.Item{"name": "a"})
+			expect(t, "updated set", true, item["updated"] != nil)
+			expectProperty(t, item, "created", absent)
+		}},
+	{area: "timestamps", behavior: "timestamps: false sets neither",
+		check: func(t *testing.T, Account *ot.Model, _ *store) {
+			item := mustCreate(t, Account, ot.Item{"name": "a"})
+			expectProperty(t, item, "created", absent)
+			expectProperty(t, item, "updated", absent)
+		}},
+	{area: "timestamps", behavior: "dates are stored as epoch milliseconds by default",
+		params: &ot.SchemaParams{Timestamps: true},
+		check: func(t *testing.T, Account *ot.Model, st *store) {
+			item := mustCreate(t, Account, ot.Item{"name": "a", "joined": time.UnixMilli(1700000000000)})
+			raw := storedAccount(st, item["id"])
+			expect(t, "stored joined", types.AttributeValue(&types.AttributeValueMemberN{Value: "1700000000000"}), raw["joined"])
+			_, isNumber := raw["created"].(*types.AttributeValueMemberN)
+			expect(t, "created stored as a number", true, isNumber)
+		}},
+	{area: "timestamps", behavior: "isoDates: true stores ISO 8601 strings",
+		params: &ot.SchemaParams{Timestamps: true, IsoDates: true},
+		check: func(t *testing.T, Account *ot.Model, st *store) {
+			item := mustCreate(t, Account, ot.Item{"name": "a", "joined": time.UnixMilli(1700000000000)})
+			raw := storedAccount(st, item["id"])
+			expect(t, "stored joined", "2023-11-14T22:13:20Z", avString(raw["joined"]))
+			_, err := time.Parse(time.RFC3339Nano, avString(raw["created"]))
+			expect(t, "created stored as ISO 8601", true, err == nil)
+		}},
+
+	// ─── unique ──────────────────────────────────────────────────────────────
+	{area: "unique", behavior: "duplicate unique value fails on create",
+		check: func(t *testing.T, Account *ot.Model, _ *store) {
+			mustCreate(t, Account, ot.Item{"name": "a", "email": "x@example.com"})
+			_, err := Account.Create(bg(), ot.Item{"name": "b", "email": "x@example.com"}, nil)
+			expectErrCode(t, err, ot.ErrUnique)
+		}},
+	{area: "unique", behavior: "update releases the previous unique value",
+		check: func(t *testing.T, Account *ot.Model, _ *store) {
+			item := mustCreate(t, Account, ot.Item{"name": "a", "email": "x@example.com"})
+			if _, err := Account.Update(bg(), ot.Item{"id": item["id"], "email": "y@example.com"}, nil); err != nil {
+				t.Fatalf("Update: %v", err)
+			}
+			_, err := Account.Create(bg(), ot.Item{"name": "b", "email": "x@example.com"}, nil)
+			expect(t, "create with the released value", error(nil), err)
+			_, err = Account.Create(bg(), ot.Item{"name": "c", "email": "y@example.com"}, nil)
+			expectErrCode(t, err, ot.ErrUnique)
+		}},
+	{area: "unique", behavior: "remove releases the unique value",
+		check: func(t *testing.T, Account *ot.Model, st *store) {
+			item := mustCreate(t, Account, ot.Item{"name": "a", "email": "x@example.com"})
+			if _, err := Account.Remove(bg(), ot.Item{"id": item["id"]}, nil); err != nil {
+				t.Fatalf("Remove: %v", err)
+			}
+			expect(t, "stored items after remove", 0, st.len())
+			_, err := Account.Create(bg(), ot.Item{"name": "b", "email": "x@example.com"}, nil)
+			expect(t, "create with the released value", error(nil), err)
+		}},
+
+	// ─── partial updates ─────────────────────────────────────────────────────
+	{area: "partial", behavior: "partial: false replaces a nested object",
+		check: func(t *testing.T, Account *ot.Model, _ *store) {
+			expect(t, "address", ot.Item{"city": "Paris"}, updateAddress(t, Account, nil))
+		}},
+	{area: "partial", behavior: "partial: true updates nested properties in place",
+		partial: true,
+		check: func(t *testing.T, Account *ot.Model, _ *store) {
+			expect(t, "address", ot.Item{"street": "1 Main St", "city": "Paris"}, updateAddress(t, Account, nil))
+		}},
+	{area: "partial", behavior: "params.partial overrides the table setting",
+		check: func(t *testing.T, Account *ot.Model, _ *store) {
+			partial := true
+			expect(t, "address", ot.Item{"street": "1 Main St", "city": "Paris"},
+				updateAddress(t, Account, &ot.Params{Partial: &partial}))
+		}},
+	{area: "partial", behavior: "null removes an attribute on update",
+		check: func(t *testing.T, Account *ot.Model, st *store) {
+			item := mustCreate(t, Account, ot.Item{"name": "a", "balance": 5})
+			if _, err := Account.Update(bg(), ot.Item{"id": item["id"], "balance": nil}, nil); err != nil {
+				t.Fatalf("Update: %v", err)
+			}
+			expect(t, "stored balance", types.AttributeValue(nil), storedAccount(st, item["id"])["balance"])
+		}},
+}
+
+// TestConformance runs every case against a fresh table. A diverging case
+// fails and logs the JS OneTable result next to the result of this package;
+// the summary lists all diverging behaviors.
+func TestConformance(t *testing.T) {
+	var diverging []string
+	for _, c := range conformanceCases {
+		name := c.area + "/" + c.behavior
+		ok := t.Run(name, func(t *testing.T) {
+			st, client := newStore()
+			tbl, err := ot.NewTable(ot.TableParams{
+				Name:    "ConformanceTable",
+				Client:  client,
+				Schema:  conformanceSchema(c.params),
+				Partial: c.partial,
+			})
+			if err != nil {
+				t.Fatalf("NewTable: %v", err)
+			}
+			Account, err := tbl.GetModel("Account")
+			if err != nil {
+				t.Fatalf("GetModel: %v", err)
+			}
+			c.check(t, Account, st)
+		})
+		if !ok {
+			diverging = append(diverging, name)
+		}
+	}
+	t.Logf("%d of %d documented JS OneTable behaviors match", len(conformanceCases)-len(diverging), len(conformanceCases))
+	for _, name := range diverging {
+		t.Logf("diverges: %s", name)
+	}
+}
+
+// expect reports a divergence when got differs from js, the JS OneTable result.
+func expect(t *testing.T, what string, js, got any) {
+	t.Helper()
+	if !reflect.DeepEqual(js, got) {
+		t.Errorf("%s diverges\n  expected (JS): %#v\n  actual (Go):   %#v", what, js, got)
+	}
+}
+
+// expectProperty compares a property of item; js may be absent.
+func expectProperty(t *testing.T, item ot.Item, key string, js any) {
+	t.Helper()
+	got, ok := item[key]
+	if !ok {
+		got = absent
+	}
+	expect(t, fmt.Sprintf("property %q", key), js, got)
+}
+
+// expectErrCode compares the OneTableError code of err.
+func expectErrCode(t *testing.T, err error, js ot.ErrorCode) {
+	t.Helper()
+	var got any = err
+	var oerr *ot.OneTableError
+	if errors.As(err, &oerr) {
+		got = oerr.Code
+	}
+	expect(t, "error", js, got)
+}
+
+func bg() context.Context { return context.Background() }
+
+func mustCreate(t *testing.T, model *ot.Model, properties ot.Item) ot.Item {
+	t.Helper()
+	item, err := model.Create(bg(), properties, nil)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	return item
+}
+
+// storedAccount returns the raw stored account with the given id.
+func storedAccount(st *store, id any) map[string]types.AttributeValue {
+	return st.item(fmt.Sprintf("account#%v", id), "account#")
+}
+
+// updateAddress creates an account with a full address and updates only its
+// city. Returns the address read back.
+func updateAddress(t *testing.T, Account *ot.Model, params *ot.Params) ot.Item {
+	t.Helper()
+	item := mustCreate(t, Account, ot.Item{"name": "a",
+		"address": ot.Item{"street": "1 Main St", "city": "Berlin"}})
+	if _, err := Account.Update(bg(), ot.Item{"id": item["id"],
+		"address": ot.Item{"city": "Paris"}}, params); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	item, err := Account.Get(bg(), ot.Item{"id": item["id"]}, nil)
+	if err != nil || item == nil {
+		t.Fatalf("Get: %v %v", item, err)
+	}
+	address, _ := item["address"].(ot.Item)
+	return address
+}
@@ -0,0 +1,13 @@
+/*
+Package conformance checks documented JS OneTable behaviors against this
+module: defaults, validation, hidden fields, timestamp modes, unique fields and
+partial updates. It holds tests only. Each case names the behavior as the JS
+documentation states it and fails when the Go port diverges, logging the JS
+result next to the Go one, so
+
+	go test ./conformance -v
+
+prints the compatibility statement: one PASS line per matching behavior and a
+summary of the diverging ones.
+*/
+package conformance
@@ -0,0 +1,295 @@
+package conformance
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	ddb "github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/cloudxsgmbh/dynamodb-onetable-go/mocks"
+)
+
+// store is an in-memory single-table DynamoDB behind a mocks.MockDynamoClient.
+// It evaluates just the expressions the conformance cases produce: key
+// existence and equality conditions, SET (with if_not_exists and nested
+// paths) and REMOVE updates. Anything else fails the request, so a change in
+// the generated expressions shows up as a divergence rather than a silent pass.
+type store struct {
+	mu    sync.Mutex
+	items map[string]map[string]types.AttributeValue
+}
+
+func newStore() (*store, *mocks.MockDynamoClient) {
+	s := &store{items: map[string]map[string]types.AttributeValue{}}
+	client := mocks.NewMockDynamoClient()
+	client.PutItemFunc = s.putItem
+	client.GetItemFunc = s.getItem
+	client.DeleteItemFunc = s.deleteItem
+	client.UpdateItemFunc = s.updateItem
+	client.TransactWriteItemsFunc = s.transactWriteItems
+	return s, client
+}
+
+// item returns the stored item with the given primary key.
+func (s *store) item(pk, sk string) map[string]types.AttributeValue {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.items[pk+"||"+sk]
+}
+
+func (s *store) len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.items)
+}
+
+func storeKey(key map[string]types.AttributeValue) string {
+	return avString(key["pk"]) + "||" + avString(key["sk"])
+}
+
+func avString(av types.AttributeValue) string {
+	switch v := av.(type) {
+	case *types.AttributeValueMemberS:
+		return v.Value
+	case *types.AttributeValueMemberN:
+		return v.Value
+	}
+	return ""
+}
+
+func (s *store) putItem(_ context.Context, p *ddb.PutItemInput, _ ...func(*ddb.Options)) (*ddb.PutItemOutput, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	k := storeKey(p.Item)
+	ok, err := evalCondition(s.items[k], aws.ToString(p.ConditionExpression), p.ExpressionAttributeNames, p.ExpressionAttributeValues)
+	if err != nil || !ok {
+		return nil, conditionErr(err)
+	}
+	s.items[k] = p.Item
+	return &ddb.PutItemOutput{}, nil
+}
+
+func (s *store) getItem(_ context.Context, p *ddb.GetItemInput, _ ...func(*ddb.Options)) (*ddb.GetItemOutput, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return &ddb.GetItemOutput{Item: s.items[storeKey(p.Key)]}, nil
+}
+
+func (s *store) deleteItem(_ context.Context, p *ddb.DeleteItemInput, _ ...func(*ddb.Options)) (*ddb.DeleteItemOutput, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	k := storeKey(p.Key)
+	prior := s.items[k]
+	ok, err := evalCondition(prior, aws.ToString(p.ConditionExpression), p.ExpressionAttributeNames, p.ExpressionAttributeValues)
+	if err != nil || !ok {
+		return nil, conditionErr(err)
+	}
+	delete(s.items, k)
+	return &ddb.DeleteItemOutput{Attributes: prior}, nil
+}
+
+func (s *store) updateItem(_ context.Context, p *ddb.UpdateItemInput, _ ...func(*ddb.Options)) (*ddb.UpdateItemOutput, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	k := storeKey(p.Key)
+	ok, err := evalCondition(s.items[k], aws.ToString(p.ConditionExpression), p.ExpressionAttributeNames, p.ExpressionAttributeValues)
+	if err != nil || !ok {
+		return nil, conditionErr(err)
+	}
+	item, err := applyUpdate(s.items[k], p.Key, aws.ToString(p.UpdateExpression), p.ExpressionAttributeNames, p.ExpressionAttributeValues)
+	if err != nil {
+		return nil, err
+	}
+	s.items[k] = item
+	return &ddb.UpdateItemOutput{Attributes: item}, nil
+}
+
+func (s *store) transactWriteItems(_ context.Context, p *ddb.TransactWriteItemsInput, _ ...func(*ddb.Options)) (*ddb.TransactWriteItemsOutput, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	reasons := make([]types.CancellationReason, len(p.TransactItems))
+	canceled := false
+	for i, ti := range p.TransactItems {
+		var key map[string]types.AttributeValue
+		var cond *string
+		var names map[string]string
+		var values map[string]types.AttributeValue
+		switch {
+		case ti.Put != nil:
+			key, cond, names, values = ti.Put.Item, ti.Put.ConditionExpression, ti.Put.ExpressionAttributeNames, ti.Put.ExpressionAttributeValues
+		case ti.Update != nil:
+			key, cond, names, values = ti.Update.Key, ti.Update.ConditionExpression, ti.Update.ExpressionAttributeNames, ti.Update.ExpressionAttributeValues
+		case ti.Delete != nil:
+			key, cond, names, values = ti.Delete.Key, ti.Delete.ConditionExpression, ti.Delete.ExpressionAttributeNames, ti.Delete.ExpressionAttributeValues
+		case ti.ConditionCheck != nil:
+			key, cond, names, values = ti.ConditionCheck.Key, ti.ConditionCheck.ConditionExpression, ti.ConditionCheck.ExpressionAttributeNames, ti.ConditionCheck.ExpressionAttributeValues
+		}
+		ok, err := evalCondition(s.items[storeKey(key)], aws.ToString(cond), names, values)
+		if err != nil {
+			return nil, err
+		}
+		reasons[i].Code = aws.String("None")
+		if !ok {
+			reasons[i].Code = aws.String("ConditionalCheckFailed")
+			canceled = true
+		}
+	}
+	if canceled {
+		return nil, &types.TransactionCanceledException{
+			Message:             aws.String("Transaction cancelled"),
+			CancellationReasons: reasons,
+		}
+	}
+	for _, ti := range p.TransactItems {
+		switch {
+		case ti.Put != nil:
+			s.items[storeKey(ti.Put.Item)] = ti.Put.Item
+		case ti.Delete != nil:
+			delete(s.items, storeKey(ti.Delete.Key))
+		case ti.Update != nil:
+			k := storeKey(ti.Update.Key)
+			item, err := applyUpdate(s.items[k], ti.Update.Key, aws.ToString(ti.Update.UpdateExpression),
+				ti.Update.ExpressionAttributeNames, ti.Update.ExpressionAttributeValues)
+			if err != nil {
+				return nil, err
+			}
+			s.items[k] = item
+		}
+	}
+	return &ddb.TransactWriteItemsOutput{}, nil
+}
+
+// conditionErr returns err, or a failed condition when err is nil.
+func conditionErr(err error) error {
+	if err != nil {
+		return err
+	}
+	return &types.ConditionalCheckFailedException{Message: aws.String("The conditional request failed")}
+}
+
+var reCondition = regexp.MustCompile(`^(attribute_exists|attribute_not_exists)\((\S+)\)$|^(\S+) (=|<>) (\S+)$`)
+
+// evalCondition evaluates a conjunction of attribute_exists,
+// attribute_not_exists and (in)equality terms against item.
+func evalCondition(item map[string]types.AttributeValue, expr string, names map[string]string, values map[string]types.AttributeValue) (bool, error) {
+	if expr == "" {
+		return true, nil
+	}
+	for term := range strings.SplitSeq(expr, " and ") {
+		term = strings.TrimSpace(term)
+		for strings.HasPrefix(term, "(") && strings.HasSuffix(term, ")") {
+			term = strings.TrimSpace(term[1 : len(term)-1])
+		}
+		m := reCondition.FindStringSubmatch(term)
+		if m == nil {
+			return false, fmt.Errorf("conformance store: unsupported condition %q", expr)
+		}
+		var ok bool
+		switch {
+		case m[1] == "attribute_exists":
+			ok = lookup(item, m[2], names) != nil
+		case m[1] == "attribute_not_exists":
+			ok = lookup(item, m[2], names) == nil
+		default:
+			equal := avString(lookup(item, m[3], names)) == avString(values[m[5]])
+			ok = equal == (m[4] == "=")
+		}
+		if !ok {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// lookup resolves a document path such as "#_0.#_1" in item.
+func lookup(item map[string]types.AttributeValue, path string, names map[string]string) types.AttributeValue {
+	var value types.AttributeValue = &types.AttributeValueMemberM{Value: item}
+	for tok := range strings.SplitSeq(path, ".") {
+		m, ok := value.(*types.AttributeValueMemberM)
+		if !ok {
+			return nil
+		}
+		value = m.Value[cmpName(tok, names)]
+	}
+	return value
+}
+
+func cmpName(tok string, names map[string]string) string {
+	if name, ok := names[tok]; ok {
+		return name
+	}
+	return tok
+}
+
+var reClause = regexp.MustCompile(`(?i)\b(set|remove|add|delete) `)
+
+// applyUpdate applies the SET and REMOVE clauses of expr to a copy of item,
+// created from key if missing.
+func applyUpdate(item, key map[string]types.AttributeValue, expr string, names map[string]string, values map[string]types.AttributeValue) (map[string]types.AttributeValue, error) {
+	out := maps.Clone(item)
+	if out == nil {
+		out = map[string]types.AttributeValue{}
+	}
+	maps.Copy(out, key)
+	bounds := reClause.FindAllStringSubmatchIndex(expr, -1)
+	for i, b := range bounds {
+		end := len(expr)
+		if i+1 < len(bounds) {
+			end = bounds[i+1][0]
+		}
+		keyword, body := strings.ToLower(expr[b[2]:b[3]]), expr[b[1]:end]
+		for action := range strings.SplitSeq(body, ",") {
+			action = strings.TrimSpace(action)
+			if action == "" {
+				continue
+			}
+			switch keyword {
+			case "set":
+				lhs, rhs, _ := strings.Cut(action, "=")
+				path, rhs := strings.TrimSpace(lhs), strings.TrimSpace(rhs)
+				if inner, ok := strings.CutPrefix(rhs, "if_not_exists("); ok {
+					if lookup(out, path, names) != nil {
+						continue
+					}
+					rhs = strings.TrimSpace(strings.TrimSuffix(inner[strings.LastIndex(inner, " ")+1:], ")"))
+				}
+				value, ok := values[rhs]
+				if !ok {
+					return nil, fmt.Errorf("conformance store: unsupported update %q", expr)
+				}
+				setPath(out, path, names, value)
+			case "remove":
+				setPath(out, action, names, nil)
+			default:
+				return nil, fmt.Errorf("conformance store: unsupported update %q", expr)
+			}
+		}
+	}
+	return out, nil
+}
+
+// setPath sets (or with a nil value removes) the attribute at path. Missing
+// parent maps are left alone, as in DynamoDB where the update would fail.
+func setPath(item map[string]types.AttributeValue, path string, names map[string]string, value types.AttributeValue) {
+	toks := strings.Split(path, ".")
+	target := item
+	for _, tok := range toks[:len(toks)-1] {
+		m, ok := target[cmpName(tok, names)].(*types.AttributeValueMemberM)
+		if !ok {
+			return
+		}
+		target = m.Value
+	}
+	name := cmpName(toks[len(toks)-1], names)
+	if value == nil {
+		delete(target, name)
+	} else {
+		target[name] = value
+	}
+}
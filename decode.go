@@ -0,0 +1,106 @@
+/*
+Package onetable – decoding items into Go structs.
+
+Items are converted with the AWS attributevalue codec. Struct fields are matched
+by the table decode tag (default `onetable`), then the `dynamodbav` tag, then
+case-insensitively by field name. Item keys are schema field names, so tags name
+schema fields, not mapped DynamoDB attributes.
+*/
+package onetable
+
+import (
+	"context"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+)
+
+const defaultDecodeTag = "onetable"
+
+// Decode decodes the result items into dest, which must be a pointer to a
+// slice of structs or maps.
+func (r *Result) Decode(dest any) error {
+	if err := checkDecodeDest(dest, reflect.Slice); err != nil {
+		return err
+	}
+	items := r.Items
+	if items == nil {
+		items = []Item{}
+	}
+	tag := defaultDecodeTag
+	if r.table != nil {
+		tag = r.table.decodeTag
+	}
+	return decodeValue(items, dest, tag)
+}
+
+// GetAs retrieves a single item like Get and decodes it into dest, which must
+// be a pointer to a struct or map. It returns false when no item was found; dest
+// is left untouched in that case.
+func (m *Model) GetAs(ctx context.Context, properties Item, dest any, params *Params) (bool, error) {
+	if err := checkDecodeDest(dest, reflect.Struct, reflect.Map); err != nil {
+		return false, err
+	}
+	item, err := m.Get(ctx, properties, params)
+	if err != nil || item == nil {
+		return false, err
+	}
+	return true, decodeValue(item, dest, m.table.decodeTag)
+}
+
+// DecodeItem decodes a single item into dest, which must be a pointer to a
+// struct or map. Struct fields are matched by the `onetable` tag.
+func DecodeItem(item Item, dest any) error {
+	if err := checkDecodeDest(dest, reflect.Struct, reflect.Map); err != nil {
+		return err
+	}
+	return decodeValue(item, dest, defaultDecodeTag)
+}
+
+func checkDecodeDest(dest any, kinds ...reflect.Kind) error {
+	rv := reflect.ValueOf(dest)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return NewArgError("Decode destination must be a non-nil pointer")
+	}
+	kind := rv.Elem().Kind()
+	for _, k := range kinds {
+		if kind == k {
+			return nil
+		}
+	}
+	return NewArgError("Decode destination must point to a " + kinds[0].String() + ", not " + kind.String())
+}
+
+func decodeValue(value any, dest any, tag string) error {
+	av, err := attributevalue.Marshal(value)
+	if err != nil {
+		return NewError("Cannot encode item for decoding", WithCode(ErrType), WithCause(err))
+	}
+	dec := attributevalue.NewDecoder(func(o *attributevalue.DecoderOptions) {
+		o.TagKey = tag
+		// dates are read like transformReadAttribute: ISO strings or epoch millis
+		o.DecodeTime.S = decodeTimeString
+		o.DecodeTime.N = decodeTimeMillis
+	})
+	if err := dec.Decode(av, dest); err != nil {
+		return NewError("Cannot decode item", WithCode(ErrType), WithCause(err))
+	}
+	return nil
+}
+
+func decodeTimeString(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return t, nil
+	}
+	return decodeTimeMillis(s)
+}
+
+func decodeTimeMillis(s string) (time.Time, error) {
+	ms, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.UnixMilli(ms).UTC(), nil
+}
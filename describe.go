@@ -0,0 +1,322 @@
+/*
+Package onetable – schema description.
+
+DescribeSchema turns a SchemaDef into a structured description of the
+single-table design (indexes, models, key templates, unique constraints) that
+can be rendered, e.g. as Markdown with SchemaDescription.Markdown.
+*/
+package onetable
+
+import (
+	"cmp"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// SchemaDescription describes a schema.
+type SchemaDescription struct {
+	Name    string
+	Version string
+	Indexes []IndexDescription
+	Models  []ModelDescription
+}
+
+// IndexDescription describes an index and the models that populate it.
+type IndexDescription struct {
+	Name    string
+	Type    string // "primary" | "global" | "local"
+	Hash    string
+	Sort    string
+	Project string // "all" | "keys" | "include: a, b"
+	Models  []string
+}
+
+// ModelDescription describes a model.
+type ModelDescription struct {
+	Name   string
+	Keys   []KeyDescription
+	Fields []FieldDescription
+	Unique []string // fields with a unique constraint
+}
+
+// KeyDescription gives the key templates a model writes to one index.
+type KeyDescription struct {
+	Index string
+	Hash  string
+	Sort  string
+}
+
+// FieldDescription describes a field. Nested object and array element fields
+// are listed in Fields.
+type FieldDescription struct {
+	Name      string
+	Type      FieldType
+	Attribute string // DynamoDB attribute (Map), when it differs from Name
+	Value     string
+	Default   any
+	Generate  string
+	Validate  string
+	Enum      []string
+	Scope     string
+	Required  bool
+	Hidden    bool
+	Unique    bool
+	Crypt     bool
+	TTL       bool
+	Fields    []FieldDescription
+}
+
+// DescribeSchema returns a structured description of schema.
+func DescribeSchema(schema *SchemaDef) (*SchemaDescription, error) {
+	if schema == nil {
+		return nil, NewArgError("Missing schema")
+	}
+	primary := schema.Indexes["primary"]
+	if primary == nil {
+		return nil, NewArgError("Schema is missing a primary index")
+	}
+	desc := &SchemaDescription{Name: schema.Name, Version: schema.Version}
+
+	// primary first, then secondary indexes by name
+	names := []string{"primary"}
+	for name := range schema.Indexes {
+		if name != "primary" {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names[1:])
+	for _, name := range names {
+		idx := schema.Indexes[name]
+		d := IndexDescription{Name: name, Type: "global", Hash: idx.Hash, Sort: idx.Sort, Project: describeProjection(idx.Project)}
+		if name == "primary" {
+			d.Type = "primary"
+		} else if idx.Type == "local" || idx.Hash == "" {
+			d.Type = "local"
+			d.Hash = primary.Hash
+		}
+		desc.Indexes = append(desc.Indexes, d)
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(schema.Models)) {
+		fields := schema.Models[name]
+		m := ModelDescription{Name: name, Fields: describeFields(fields)}
+		for _, f := range m.Fields {
+			if f.Unique {
+				m.Unique = append(m.Unique, f.Name)
+			}
+		}
+		for i := range desc.Indexes {
+			idx := &desc.Indexes[i]
+			hash := keyTemplate(fields, idx.Hash)
+			sort := keyTemplate(fields, idx.Sort)
+			// a local index only holds items that write its sort key
+			if hash == "" || (idx.Type == "local" && sort == "") {
+				continue
+			}
+			m.Keys = append(m.Keys, KeyDescription{Index: idx.Name, Hash: hash, Sort: sort})
+			idx.Models = append(idx.Models, name)
+		}
+		desc.Models = append(desc.Models, m)
+	}
+	return desc, nil
+}
+
+// describeProjection renders an IndexDef.Project value.
+func describeProjection(project any) string {
+	switch p := project.(type) {
+	case string:
+		if p != "" {
+			return p
+		}
+	case []string:
+		return "include: " + strings.Join(p, ", ")
+	case []any:
+		parts := make([]string, len(p))
+		for i, v := range p {
+			parts[i] = fmt.Sprint(v)
+		}
+		return "include: " + strings.Join(parts, ", ")
+	}
+	return "all"
+}
+
+// keyTemplate returns the value template of the field stored in attribute att,
+// or "${name}" for a plain field. It returns "" when no field maps to att.
+func keyTemplate(fields FieldMap, att string) string {
+	name, def := keyField(fields, att)
+	if def == nil {
+		return ""
+	}
+	if def.Value != "" {
+		return def.Value
+	}
+	return "${" + name + "}"
+}
+
+// keyField returns the field stored in attribute att, if any.
+func keyField(fields FieldMap, att string) (string, *FieldDef) {
+	if att == "" {
+		return "", nil
+	}
+	for _, name := range slices.Sorted(maps.Keys(fields)) {
+		if def := fields[name]; def != nil && fieldAttribute(name, def) == att {
+			return name, def
+		}
+	}
+	return "", nil
+}
+
+// fieldAttribute returns the top-level DynamoDB attribute a field is stored in.
+func fieldAttribute(name string, def *FieldDef) string {
+	if def.Map == "" {
+		return name
+	}
+	att, _, _ := strings.Cut(def.Map, ".")
+	return att
+}
+
+// describeFields describes fields sorted by name.
+func describeFields(fields FieldMap) []FieldDescription {
+	out := make([]FieldDescription, 0, len(fields))
+	for _, name := range slices.Sorted(maps.Keys(fields)) {
+		def := fields[name]
+		if def == nil {
+			continue
+		}
+		d := FieldDescription{
+			Name:     name,
+			Type:     cmp.Or(def.Type, FieldTypeString),
+			Value:    def.Value,
+			Default:  def.Default,
+			Generate: def.Generate,
+			Validate: def.Validate,
+			Enum:     def.Enum,
+			Scope:    def.Scope,
+			Required: def.Required,
+			Hidden:   def.Value != "",
+			Unique:   def.Unique,
+			Crypt:    def.Crypt || def.CryptKey != "",
+			TTL:      def.TTL,
+		}
+		if def.Hidden != nil {
+			d.Hidden = *def.Hidden
+		}
+		if def.Map != "" && def.Map != name {
+			d.Attribute = def.Map
+		}
+		if def.Schema != nil {
+			d.Fields = describeFields(def.Schema)
+		} else if def.Items != nil && def.Items.Schema != nil {
+			d.Fields = describeFields(def.Items.Schema)
+		}
+		out = append(out, d)
+	}
+	return out
+}
+
+// Markdown renders the description as a Markdown document.
+func (d *SchemaDescription) Markdown() string {
+	var b strings.Builder
+	title := cmp.Or(d.Name, "Schema")
+	if d.Version != "" {
+		fmt.Fprintf(&b, "# %s (version %s)\n", title, d.Version)
+	} else {
+		fmt.Fprintf(&b, "# %s\n", title)
+	}
+
+	b.WriteString("\n## Indexes\n\n")
+	b.WriteString("| Index | Type | Hash | Sort | Projection | Models |\n")
+	b.WriteString("|-------|------|------|------|------------|--------|\n")
+	for _, idx := range d.Indexes {
+		fmt.Fprintf(&b, "| %s | %s | %s | %s | %s | %s |\n", idx.Name, idx.Type, mdCode(idx.Hash), mdCode(idx.Sort),
+			idx.Project, strings.Join(idx.Models, ", "))
+	}
+
+	b.WriteString("\n## Models\n")
+	for _, m := range d.Models {
+		fmt.Fprintf(&b, "\n### %s\n", m.Name)
+		if len(m.Keys) > 0 {
+			b.WriteString("\n| Index | Hash | Sort |\n")
+			b.WriteString("|-------|------|------|\n")
+			for _, k := range m.Keys {
+				fmt.Fprintf(&b, "| %s | %s | %s |\n", k.Index, mdCode(k.Hash), mdCode(k.Sort))
+			}
+		}
+		if len(m.Unique) > 0 {
+			fmt.Fprintf(&b, "\nUnique: %s\n", strings.Join(m.Unique, ", "))
+		}
+		b.WriteString("\n| Field | Type | Required | Details |\n")
+		b.WriteString("|-------|------|----------|---------|\n")
+		writeFieldRows(&b, "", m.Fields)
+	}
+	return b.String()
+}
+
+// writeFieldRows writes one table row per field, nested fields prefixed by
+// their parent path.
+func writeFieldRows(b *strings.Builder, prefix string, fields []FieldDescription) {
+	for _, f := range fields {
+		path := prefix + f.Name
+		required := ""
+		if f.Required {
+			required = "yes"
+		}
+		fmt.Fprintf(b, "| %s | %s | %s | %s |\n", mdCode(path), f.Type, required, f.details())
+		if len(f.Fields) > 0 {
+			sub := path + "."
+			if f.Type == FieldTypeArray {
+				sub = path + "[]."
+			}
+			writeFieldRows(b, sub, f.Fields)
+		}
+	}
+}
+
+// details summarises the field's constraints for the Markdown table.
+func (f FieldDescription) details() string {
+	var parts []string
+	if f.Attribute != "" {
+		parts = append(parts, "map "+mdCode(f.Attribute))
+	}
+	if f.Value != "" {
+		parts = append(parts, "value "+mdCode(f.Value))
+	}
+	if f.Default != nil {
+		parts = append(parts, "default "+mdCode(fmt.Sprint(f.Default)))
+	}
+	if f.Generate != "" {
+		parts = append(parts, "generate "+f.Generate)
+	}
+	if f.Validate != "" {
+		parts = append(parts, "validate "+mdCode(f.Validate))
+	}
+	if len(f.Enum) > 0 {
+		parts = append(parts, "enum "+strings.Join(f.Enum, ", "))
+	}
+	if f.Unique {
+		if f.Scope != "" {
+			parts = append(parts, "unique in "+mdCode(f.Scope))
+		} else {
+			parts = append(parts, "unique")
+		}
+	}
+	for _, flag := range []struct {
+		on   bool
+		name string
+	}{{f.Hidden, "hidden"}, {f.Crypt, "encrypted"}, {f.TTL, "ttl"}} {
+		if flag.on {
+			parts = append(parts, flag.name)
+		}
+	}
+	return strings.Join(parts, "; ")
+}
+
+// mdCode formats s as inline code, escaping table pipes.
+func mdCode(s string) string {
+	if s == "" {
+		return ""
+	}
+	return "`" + strings.ReplaceAll(s, "|", `\|`) + "`"
+}
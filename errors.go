@@ -5,7 +5,15 @@ Mirrors JS: OneTableError / OneTableArgError.
 */
 package onetable
 
-import "fmt"
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
 
 // ErrorCode is a well-known error category string.
 type ErrorCode string
@@ -27,6 +35,15 @@ const (
 	ErrRuntime ErrorCode = "RuntimeError"
 	// ErrType indicates type mismatch or conversion failure.
 	ErrType ErrorCode = "TypeError"
+	// ErrThrottling indicates DynamoDB throttled the request.
+	ErrThrottling ErrorCode = "ThrottlingError"
+	// ErrLimitExceeded indicates too many concurrent control plane operations.
+	ErrLimitExceeded ErrorCode = "LimitExceededError"
+	// ErrTransactionConflict indicates a transaction conflicted with another
+	// request on the same items.
+	ErrTransactionConflict ErrorCode = "TransactionConflictError"
+	// ErrTimeout indicates the request or its context timed out.
+	ErrTimeout ErrorCode = "TimeoutError"
 )
 
 // OneTableError is the general runtime error. It carries an optional Code and
@@ -97,3 +114,75 @@ func NewArgError(msg string, code ...ErrorCode) *OneTableArgError {
 	}
 	return &OneTableArgError{Message: msg, Code: c}
 }
+
+// IsRetryable reports whether err is a transient failure worth retrying:
+// throttling, a control plane limit, a transaction conflict or a timeout.
+// It accepts OneTable errors as well as raw AWS SDK errors.
+func IsRetryable(err error) bool {
+	switch errorCode(err) {
+	case ErrThrottling, ErrLimitExceeded, ErrTransactionConflict, ErrTimeout:
+		return true
+	}
+	return false
+}
+
+// errorCode returns the code of a OneTable error, or classifies a raw error.
+func errorCode(err error) ErrorCode {
+	var ote *OneTableError
+	if errors.As(err, &ote) && ote.Code != ErrRuntime {
+		return ote.Code
+	}
+	var arg *OneTableArgError
+	if errors.As(err, &arg) {
+		return arg.Code
+	}
+	return classifyError(err)
+}
+
+// apiError is implemented by AWS SDK service errors (smithy.APIError).
+type apiError interface {
+	ErrorCode() string
+}
+
+// classifyError maps a client error to a transient ErrorCode, or "" when it
+// is not transient.
+func classifyError(err error) ErrorCode {
+	if err == nil {
+		return ""
+	}
+	var canceled *types.TransactionCanceledException
+	if errors.As(err, &canceled) {
+		// a transaction is retryable only if no item failed for good
+		var code ErrorCode
+		for _, reason := range canceled.CancellationReasons {
+			switch aws.ToString(reason.Code) {
+			case "", "None":
+			case "TransactionConflict":
+				code = cmp.Or(code, ErrTransactionConflict)
+			case "ThrottlingError", "ProvisionedThroughputExceeded", "RequestLimitExceeded":
+				code = cmp.Or(code, ErrThrottling)
+			default:
+				return ""
+			}
+		}
+		return code
+	}
+	var api apiError
+	if errors.As(err, &api) {
+		switch api.ErrorCode() {
+		case "ProvisionedThroughputExceededException", "RequestLimitExceeded", "ThrottlingException":
+			return ErrThrottling
+		case "LimitExceededException":
+			return ErrLimitExceeded
+		case "TransactionConflictException", "TransactionInProgressException":
+			return ErrTransactionConflict
+		case "RequestTimeout", "RequestTimeoutException":
+			return ErrTimeout
+		}
+	}
+	var timeout interface{ Timeout() bool }
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &timeout) && timeout.Timeout()) {
+		return ErrTimeout
+	}
+	return ""
+}
@@ -7,9 +7,13 @@ package onetable
 
 import (
 	"fmt"
+	"maps"
 	"regexp"
+	"slices"
 	"strconv"
 	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 )
 
 // KeyOperators are valid sort-key comparison operators for find operations.
@@ -18,6 +22,104 @@ var KeyOperators = map[string]bool{
 	"begins": true, "begins_with": true, "between": true,
 }
 
+// ExistsFilter is a Find/Scan property value that matches on whether the
+// attribute is present rather than on its value. The map {"exists": bool} is
+// accepted as well.
+type ExistsFilter struct {
+	exists bool
+}
+
+// Exists matches items that have the attribute.
+func Exists() ExistsFilter { return ExistsFilter{exists: true} }
+
+// NotExists matches items without the attribute.
+func NotExists() ExistsFilter { return ExistsFilter{exists: false} }
+
+// existsFilter returns the ExistsFilter a property value stands for, if any.
+func existsFilter(value any) (ExistsFilter, bool) {
+	switch v := value.(type) {
+	case ExistsFilter:
+		return v, true
+	case map[string]any:
+		if b, ok := v["exists"].(bool); ok && len(v) == 1 {
+			return ExistsFilter{exists: b}, true
+		}
+	}
+	return ExistsFilter{}, false
+}
+
+// condition renders the filter for the attribute reference target.
+func (f ExistsFilter) condition(target string) string {
+	if f.exists {
+		return fmt.Sprintf("attribute_exists(%s)", target)
+	}
+	return fmt.Sprintf("attribute_not_exists(%s)", target)
+}
+
+// ContainsFilter is a Find/Scan property value that matches lists and sets
+// holding a value, or strings containing it, with DynamoDB's contains().
+type ContainsFilter struct {
+	value any
+}
+
+// Contains matches list or set attributes holding value and string attributes
+// containing it as a substring. On a list of objects, value must equal a whole
+// element; use AnyElement to match on some of its fields.
+func Contains(value any) ContainsFilter { return ContainsFilter{value: value} }
+
+// ElementFilter is a Find/Scan property value for a top-level array of
+// objects. It matches items holding an element whose fields equal those of
+// the filter. DynamoDB cannot filter on the fields of list elements, so the
+// query only requires the attribute to exist and the elements are compared
+// client-side after the read. As with DynamoDB filters, Limit counts the items
+// read, so a page may hold fewer items than Limit.
+type ElementFilter struct {
+	match Item
+}
+
+// AnyElement matches arrays holding an element with the values of match, e.g.
+// Item{"addresses": AnyElement(Item{"zip": "3000"})}.
+func AnyElement(match Item) ElementFilter { return ElementFilter{match: match} }
+
+// matches reports whether list holds an element with the filter's values.
+// Values are compared by their formatted form, so numbers match across types.
+func (f ElementFilter) matches(list any) bool {
+	elems, _ := list.([]any)
+	if items, ok := list.([]Item); ok {
+		for _, item := range items {
+			elems = append(elems, item)
+		}
+	}
+	for _, elem := range elems {
+		obj, _ := elem.(map[string]any)
+		if obj != nil && f.matchesElement(obj) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f ElementFilter) matchesElement(obj map[string]any) bool {
+	for name, want := range f.match {
+		got, ok := obj[name]
+		if !ok || fmt.Sprint(got) != fmt.Sprint(want) {
+			return false
+		}
+	}
+	return true
+}
+
+// isFilterValue reports whether value is a Find/Scan filter rather than a
+// plain value to compare with.
+func isFilterValue(value any) bool {
+	switch value.(type) {
+	case ContainsFilter, ElementFilter:
+		return true
+	}
+	_, ok := existsFilter(value)
+	return ok
+}
+
 type updates struct {
 	add    []string
 	del    []string
@@ -57,6 +159,14 @@ type expression struct {
 	updates updates
 	execute bool
 	canPut  bool
+	follow  bool   // find on a secondary index resolved by primary-key gets
+	count   bool   // find/scan returning only the count
+	selects string // normalized Params.Select (find/scan)
+
+	// client-side AnyElement filters of top-level array fields
+	elements map[*preparedField]ElementFilter
+
+	err error // first invalid filter found while adding properties
 
 	tableName string
 }
@@ -92,6 +202,7 @@ func (e *expression) init(model *Model, op string, properties Item, params *Para
 	e.index = model.selectIndex(params)
 	e.hash = e.index.Hash
 	e.sort = e.index.Sort
+	e.follow = op == "find" && e.index != model.indexes["primary"] && shouldFollow(params, e.index)
 
 	if model.table.client == nil {
 		return NewArgError("Table has not yet defined a client instance")
@@ -101,6 +212,37 @@ func (e *expression) init(model *Model, op string, properties Item, params *Para
 
 func (e *expression) prepare() error {
 	op := e.op
+	switch e.params.ConditionLogic {
+	case "", "and", "or":
+	default:
+		return NewArgError(fmt.Sprintf(`Invalid ConditionLogic "%s", expected "and" or "or"`, e.params.ConditionLogic))
+	}
+	if e.params.Condition != "" {
+		switch op {
+		case "put", "delete", "update", "check":
+		default:
+			return NewArgError(fmt.Sprintf(`Params.Condition is not supported for "%s", use Params.Where`, op))
+		}
+	}
+	if e.params.MaxCapacity < 0 {
+		return NewArgError("Params.MaxCapacity must not be negative")
+	}
+	if e.params.ChunkUpdates && (e.params.Batch != nil || e.params.Transaction != nil) {
+		return NewArgError("Params.ChunkUpdates cannot be used in a batch or transaction")
+	}
+	if op == "check" {
+		if err := e.checkParams(); err != nil {
+			return err
+		}
+	}
+	if _, err := returnValue(op, e.params.Return); err != nil {
+		return err
+	}
+	if op == "find" || op == "scan" {
+		if err := e.selectParams(); err != nil {
+			return err
+		}
+	}
 	switch op {
 	case "find":
 		e.addWhereFilters()
@@ -108,93 +250,201 @@ func (e *expression) prepare() error {
 		e.addConditions(op)
 	case "scan":
 		e.addWhereFilters()
+		// typed high-level scans only ever return their own model's items
+		typed := e.params.High && !e.model.generic
+		if typed {
+			e.addTypeFilter()
+		}
 		// generic scan filters for unknown fields
-		for name, value := range e.properties {
+		for _, name := range slices.Sorted(maps.Keys(e.properties)) {
+			value := e.properties[name]
+			if typed && name == e.model.typeField {
+				continue
+			}
 			if _, ok := e.model.block.Fields[name]; !ok && value != nil {
 				e.addGenericFilter(name, value)
 			}
 		}
 	}
 
-	e.puts = e.addProperties(op, &e.model.block, e.properties)
+	e.puts = e.addProperties(op, &e.model.block, e.properties, "", true)
 
 	// check mapped attributes are complete
-	for att, props := range e.mapped {
+	for _, att := range slices.Sorted(maps.Keys(e.mapped)) {
+		props := e.mapped[att]
 		expected := len(e.model.mappings[att])
 		if len(props) != expected {
 			return NewArgError(fmt.Sprintf(`Missing properties for mapped field "%s" in model "%s"`, att, e.model.Name))
 		}
 	}
 	// emit mapped attributes as top-level fields
-	for k, v := range e.mapped {
+	for _, k := range slices.Sorted(maps.Keys(e.mapped)) {
+		v := e.mapped[k]
 		field := &preparedField{Attribute: []string{k}, Name: k}
-		e.add(op, e.properties, field, k, v, true)
+		e.add(op, e.properties, field, EscapePath(k), v, true)
 		e.puts[k] = v
 	}
+	if e.err != nil {
+		return e.err
+	}
 
-	// projection fields
-	if e.params.Fields != nil {
+	// projection fields: a followed query only needs the primary key, the
+	// follow gets apply Fields
+	if e.follow {
+		primary := e.model.indexes["primary"]
+		e.project = append(e.project, fmt.Sprintf("#_%d", e.addName(primary.Hash)))
+		if primary.Sort != "" {
+			e.project = append(e.project, fmt.Sprintf("#_%d", e.addName(primary.Sort)))
+		}
+	} else if e.params.Fields != nil {
+		projected := map[string]bool{}
+		project := func(att string) {
+			if !projected[att] {
+				projected[att] = true
+				e.project = append(e.project, fmt.Sprintf("#_%d", e.addName(att)))
+			}
+		}
+		bound := false
 		for _, name := range e.params.Fields {
 			if e.params.Batch != nil || e.model.generic {
-				e.project = append(e.project, fmt.Sprintf("#_%d", e.addName(name)))
+				project(name)
 			} else if f, ok := e.model.block.Fields[name]; ok {
-				att := f.Attribute[0]
-				e.project = append(e.project, fmt.Sprintf("#_%d", e.addName(att)))
+				project(f.Attribute[0])
+				bound = bound || (f.Crypt && e.model.table.cryptBind)
+			}
+		}
+		// bound encrypted values need the primary key to decrypt
+		if bound {
+			primary := e.model.indexes["primary"]
+			project(primary.Hash)
+			if primary.Sort != "" {
+				project(primary.Sort)
 			}
 		}
 	}
 	return nil
 }
 
-// addProperties processes all properties for a given block level.
-func (e *expression) addProperties(op string, block *fieldBlock, properties Item) Item {
+// checkParams rejects params a ConditionCheck cannot carry and requires a
+// condition.
+func (e *expression) checkParams() error {
+	p := e.params
+	for name, set := range map[string]bool{
+		"Set": p.Set != nil, "Add": p.Add != nil, "Remove": p.Remove != nil, "Delete": p.Delete != nil,
+		"Push": p.Push != nil, "ChunkUpdates": p.ChunkUpdates, "Return": p.Return != nil, "Batch": p.Batch != nil,
+		"Fields": p.Fields != nil,
+	} {
+		if set {
+			return NewArgError(fmt.Sprintf(`Params.%s cannot be used with a condition check`, name))
+		}
+	}
+	if p.Where == "" && p.Condition == "" && p.Exists == nil {
+		return NewArgError("A condition check needs Params.Where, Params.Condition or Params.Exists")
+	}
+	return nil
+}
+
+// selectValues are the DynamoDB Select values accepted by Params.Select.
+var selectValues = []string{"ALL_ATTRIBUTES", "ALL_PROJECTED_ATTRIBUTES", "SPECIFIC_ATTRIBUTES", "COUNT"}
+
+// selectParams validates Params.Select against Count, Fields and the index and
+// normalizes it. A counting query is never followed: the count comes from the
+// index itself.
+func (e *expression) selectParams() error {
+	p := e.params
+	sel := strings.ToUpper(p.Select)
+	if sel != "" && !slices.Contains(selectValues, sel) {
+		return NewArgError(fmt.Sprintf(`Invalid Params.Select "%s", expected one of %v`, p.Select, selectValues))
+	}
+	if p.Count {
+		if sel != "" && sel != "COUNT" {
+			return NewArgError(fmt.Sprintf(`Params.Count cannot be used with Params.Select "%s"`, sel))
+		}
+		sel = "COUNT"
+	}
+	switch sel {
+	case "COUNT":
+		if p.Fields != nil {
+			return NewArgError("Params.Fields cannot be used when counting")
+		}
+		e.count = true
+		e.follow = false
+	case "SPECIFIC_ATTRIBUTES":
+		if p.Fields == nil && !e.follow {
+			return NewArgError(`Params.Select "SPECIFIC_ATTRIBUTES" requires Params.Fields`)
+		}
+	case "ALL_ATTRIBUTES", "ALL_PROJECTED_ATTRIBUTES":
+		if p.Fields != nil {
+			return NewArgError(fmt.Sprintf(`Params.Fields cannot be used with Params.Select "%s"`, sel))
+		}
+		if sel == "ALL_PROJECTED_ATTRIBUTES" && e.index == e.model.indexes["primary"] {
+			return NewArgError(`Params.Select "ALL_PROJECTED_ATTRIBUTES" requires a secondary index`)
+		}
+		if e.follow {
+			// the followed gets return the full items
+			sel = ""
+		}
+	}
+	e.selects = sel
+	return nil
+}
+
+// addProperties processes all properties for a given block level. prefix is
+// the escaped document path of the block ("" at the top level); nested fields
+// are emitted individually only when their parent is partial, otherwise the
+// parent is written as a whole.
+func (e *expression) addProperties(op string, block *fieldBlock, properties Item, prefix string, emit bool) Item {
 	rec := Item{}
 	fields := block.Fields
 
 	if properties == nil {
 		return rec
 	}
-	for name, value := range properties {
+	for _, name := range slices.Sorted(maps.Keys(properties)) {
+		value := properties[name]
 		field := fields[name]
 		if field == nil {
 			// unknown field
 			synth := &preparedField{Attribute: []string{name}, Name: name}
 			if e.model.generic {
-				e.add(op, properties, synth, name, value, true)
+				e.add(op, properties, synth, EscapePath(name), value, emit)
 			}
 			rec[name] = value
 			continue
 		}
-		att := field.Attribute[0]
-		path := att
-		if field.Block == nil {
-			e.add(op, properties, field, path, value, true)
+		path := EscapePath(field.Name)
+		if prefix != "" {
+			path = prefix + "." + path
+		}
+		if isFilterValue(value) && (op == "find" || op == "scan") {
+			e.add(op, properties, field, path, value, emit)
+		} else if field.Block == nil {
+			e.add(op, properties, field, path, value, emit)
 		} else {
-			// nested schema
-			partial := e.model.getPartial(field, e.params)
+			// nested schema: a structure created by an upsert is written whole
+			name := unescapePath(path)
+			partial := e.model.getPartial(field, e.params) && !e.params.ifNotExists[name] && !e.params.replace[name]
 			if field.IsArray {
 				if arr, ok := value.([]any); ok {
 					cp := make([]any, len(arr))
 					for i, v := range arr {
-						ipath := fmt.Sprintf("%s[%d]", path, i)
 						if sub, ok := v.(Item); ok {
-							cp[i] = e.addProperties(op, field.Block, sub)
+							cp[i] = e.addProperties(op, field.Block, sub, fmt.Sprintf("%s[%d]", path, i), emit && partial)
 						} else {
 							cp[i] = v
 						}
-						_ = ipath
 					}
 					if !partial {
-						e.add(op, properties, field, path, cp, true)
+						e.add(op, properties, field, path, cp, emit)
 					}
 					value = cp
 				}
 			} else {
 				if sub, ok := value.(Item); ok {
-					value = e.addProperties(op, field.Block, sub)
+					value = e.addProperties(op, field.Block, sub, path, emit && partial)
 				}
 				if !partial {
-					e.add(op, properties, field, path, value, true)
+					e.add(op, properties, field, path, value, emit)
 				}
 			}
 		}
@@ -203,9 +453,10 @@ func (e *expression) addProperties(op string, block *fieldBlock, properties Item
 	return rec
 }
 
-// add emits key / filter / update expressions for a single field value.
+// add emits key / filter / update expressions for a single field value at the
+// escaped document path.
 func (e *expression) add(op string, properties Item, field *preparedField, path string, value any, emit bool) {
-	if e.already[path] {
+	if e.already[unescapePath(path)] {
 		return
 	}
 	att := field.Attribute
@@ -222,12 +473,19 @@ func (e *expression) add(op string, properties Item, field *preparedField, path
 		return
 	}
 
-	isHash := path == e.hash
-	isSort := path == e.sort
+	isHash, isSort := e.isKeyPath(field, path)
 
 	if isHash || isSort {
 		switch op {
 		case "find":
+			if _, ok := existsFilter(value); ok {
+				e.fail(NewArgError(fmt.Sprintf(`Cannot use an exists filter on key field "%s"`, field.Name)))
+				return
+			}
+			if isFilterValue(value) {
+				e.fail(NewArgError(fmt.Sprintf(`Cannot use a filter on key field "%s"`, field.Name)))
+				return
+			}
 			e.addKey(op, field, value)
 		case "scan":
 			if properties[field.Name] != nil && !filterDisabled(field) {
@@ -241,6 +499,9 @@ func (e *expression) add(op string, properties Item, field *preparedField, path
 	} else if emit {
 		switch op {
 		case "find", "scan":
+			if op == "scan" && field.Name == e.model.typeField && e.params.High {
+				break // added by addTypeFilter
+			}
 			if properties[field.Name] != nil && !filterDisabled(field) && e.params.Batch == nil {
 				e.addFilter(field, path, value)
 			}
@@ -250,6 +511,23 @@ func (e *expression) add(op string, properties Item, field *preparedField, path
 	}
 }
 
+// fail records err as the expression's error unless one is already recorded.
+func (e *expression) fail(err error) {
+	if e.err == nil {
+		e.err = err
+	}
+}
+
+// isKeyPath reports whether path addresses the top-level hash or sort
+// attribute of the expression's index.
+func (e *expression) isKeyPath(field *preparedField, path string) (hash, sort bool) {
+	if path != EscapePath(field.Name) {
+		return false, false
+	}
+	att := field.Attribute[0]
+	return att == e.hash, e.sort != "" && att == e.sort
+}
+
 func filterDisabled(field *preparedField) bool {
 	return field.Def.Filter != nil && !*field.Def.Filter
 }
@@ -260,15 +538,16 @@ func (e *expression) addConditions(op string) {
 	sort := e.index.Sort
 	params := e.params
 
+	var exists, user []string
 	if params.Exists != nil && *params.Exists {
-		e.conditions = append(e.conditions, fmt.Sprintf("attribute_exists(#_%d)", e.addName(hash)))
+		exists = append(exists, fmt.Sprintf("attribute_exists(#_%d)", e.addName(hash)))
 		if sort != "" {
-			e.conditions = append(e.conditions, fmt.Sprintf("attribute_exists(#_%d)", e.addName(sort)))
+			exists = append(exists, fmt.Sprintf("attribute_exists(#_%d)", e.addName(sort)))
 		}
 	} else if params.Exists != nil && !*params.Exists {
-		e.conditions = append(e.conditions, fmt.Sprintf("attribute_not_exists(#_%d)", e.addName(hash)))
+		exists = append(exists, fmt.Sprintf("attribute_not_exists(#_%d)", e.addName(hash)))
 		if sort != "" {
-			e.conditions = append(e.conditions, fmt.Sprintf("attribute_not_exists(#_%d)", e.addName(sort)))
+			exists = append(exists, fmt.Sprintf("attribute_not_exists(#_%d)", e.addName(sort)))
 		}
 	}
 
@@ -277,8 +556,19 @@ func (e *expression) addConditions(op string) {
 	}
 
 	if params.Where != "" {
-		e.conditions = append(e.conditions, e.expand(params.Where))
+		user = append(user, e.expand(params.Where))
 	}
+	if params.Condition != "" {
+		user = append(user, e.expand(params.Condition))
+	}
+
+	// ConditionLogic "or": the exists check or the caller's conditions must hold
+	if params.ConditionLogic == "or" && len(exists) > 0 && len(user) > 0 {
+		e.conditions = append(e.conditions, fmt.Sprintf("(%s) or (%s)", e.and(exists), e.and(user)))
+		return
+	}
+	e.conditions = append(e.conditions, exists...)
+	e.conditions = append(e.conditions, user...)
 }
 
 func (e *expression) addWhereFilters() {
@@ -288,14 +578,47 @@ func (e *expression) addWhereFilters() {
 }
 
 func (e *expression) addFilter(field *preparedField, path string, value any) {
-	if path == e.hash || path == e.sort {
+	if hash, sort := e.isKeyPath(field, path); hash || sort {
+		return
+	}
+	if f, ok := existsFilter(value); ok {
+		e.filters = append(e.filters, f.condition(e.prepareKey(path)))
+		return
+	}
+	switch f := value.(type) {
+	case ContainsFilter:
+		e.filters = append(e.filters, fmt.Sprintf("contains(%s, %s)", e.prepareKey(path), e.addValueExp(f.value)))
+		return
+	case ElementFilter:
+		if path != EscapePath(field.Name) || field.Type != FieldTypeArray {
+			e.fail(NewArgError(fmt.Sprintf(`AnyElement needs a top-level array field, not "%s"`, unescapePath(path))))
+			return
+		}
+		if e.elements == nil {
+			e.elements = map[*preparedField]ElementFilter{}
+		}
+		e.elements[field] = f
+		e.filters = append(e.filters, fmt.Sprintf("attribute_exists(%s)", e.prepareKey(path)))
 		return
 	}
 	target, variable := e.prepareKeyValue(path, value)
 	e.filters = append(e.filters, fmt.Sprintf("%s = %s", target, variable))
 }
 
+// addTypeFilter restricts a scan to items of the expression's model.
+func (e *expression) addTypeFilter() {
+	att := e.model.typeField
+	if field, ok := e.model.block.Fields[att]; ok {
+		att = field.Attribute[0]
+	}
+	e.addGenericFilter(att, e.model.Name)
+}
+
 func (e *expression) addGenericFilter(att string, value any) {
+	if f, ok := existsFilter(value); ok {
+		e.filters = append(e.filters, f.condition(fmt.Sprintf("#_%d", e.addName(att))))
+		return
+	}
 	e.filters = append(e.filters, fmt.Sprintf("#_%d = :_%d", e.addName(att), e.addValue(value)))
 }
 
@@ -304,7 +627,8 @@ func (e *expression) addKey(op string, field *preparedField, value any) {
 	if op == "find" {
 		if att == e.sort {
 			if obj, ok := value.(map[string]any); ok && len(obj) > 0 {
-				for action, vars := range obj {
+				for _, action := range slices.Sorted(maps.Keys(obj)) {
+					vars := obj[action]
 					if !KeyOperators[action] {
 						panic(NewArgError(`Invalid KeyCondition operator "` + action + `"`).Error())
 					}
@@ -332,7 +656,7 @@ func (e *expression) addKey(op string, field *preparedField, value any) {
 }
 
 func (e *expression) addUpdate(field *preparedField, path string, value any) {
-	if path == e.hash || path == e.sort {
+	if hash, sort := e.isKeyPath(field, path); hash || sort {
 		return
 	}
 	if field.Name == e.model.typeField {
@@ -340,11 +664,17 @@ func (e *expression) addUpdate(field *preparedField, path string, value any) {
 			return
 		}
 	}
-	if containsStr(e.params.Remove, field.Name) {
+	name := unescapePath(path)
+	if containsStr(e.params.Remove, name) {
 		return
 	}
 	target := e.prepareKey(path)
 	variable := e.addValueExp(value)
+	if e.params.ifNotExists[name] {
+		// nested structure created by an upsert only if it is missing
+		e.updates.set = append(e.updates.set, fmt.Sprintf("%s = if_not_exists(%s, %s)", target, target, variable))
+		return
+	}
 	e.updates.set = append(e.updates.set, fmt.Sprintf("%s = %s", target, variable))
 }
 
@@ -355,12 +685,14 @@ func (e *expression) addUpdateConditions() {
 			panic(NewArgError(fmt.Sprintf("Cannot %s hash or sort", op)).Error())
 		}
 	}
-	for key, value := range params.Add {
+	for _, key := range slices.Sorted(maps.Keys(params.Add)) {
+		value := params.Add[key]
 		assertNotPartition(key, "add")
 		target, variable := e.prepareKeyValue(key, value)
 		e.updates.add = append(e.updates.add, fmt.Sprintf("%s %s", target, variable))
 	}
-	for key, value := range params.Delete {
+	for _, key := range slices.Sorted(maps.Keys(params.Delete)) {
+		value := params.Delete[key]
 		assertNotPartition(key, "delete")
 		target, variable := e.prepareKeyValue(key, value)
 		e.updates.del = append(e.updates.del, fmt.Sprintf("%s %s", target, variable))
@@ -370,12 +702,14 @@ func (e *expression) addUpdateConditions() {
 		target := e.prepareKey(key)
 		e.updates.remove = append(e.updates.remove, target)
 	}
-	for key, value := range params.Set {
+	for _, key := range slices.Sorted(maps.Keys(params.Set)) {
+		value := params.Set[key]
 		assertNotPartition(key, "set")
 		target, variable := e.prepareKeyValue(key, value)
 		e.updates.set = append(e.updates.set, fmt.Sprintf("%s = %s", target, variable))
 	}
-	for key, value := range params.Push {
+	for _, key := range slices.Sorted(maps.Keys(params.Push)) {
+		value := params.Push[key]
 		assertNotPartition(key, "push")
 		emptyIdx := e.addValue([]any{})
 		itemsIdx := e.addValue(asSlice(value))
@@ -388,10 +722,11 @@ func (e *expression) addUpdateConditions() {
 // expand replaces ${attr} and {value} tokens in a where/set expression string.
 func (e *expression) expand(where string) string {
 	fields := e.model.block.Fields
+	// "$${" is a literal "${", e.g. in a value: {$${not a field}}
+	where = strings.ReplaceAll(where, templateEscape, escapeMark)
 
 	// ${attr} → #_N expression name
-	attrRe := regexp.MustCompile(`\$\{(.*?)\}`)
-	where = attrRe.ReplaceAllStringFunc(where, func(m string) string {
+	where = reTemplateVar.ReplaceAllStringFunc(where, func(m string) string {
 		varName := m[2 : len(m)-1]
 		return e.makeTarget(fields, varName)
 	})
@@ -420,10 +755,10 @@ func (e *expression) expand(where string) string {
 		return fmt.Sprintf(":_%d", e.addValue(val))
 	})
 
-	// {value} → :_N literal value
-	valRe := regexp.MustCompile(`\{([^}]*)\}`)
+	// {value} → :_N literal value; a value may contain escaped "$${...}"
+	valRe := regexp.MustCompile(`\{((?:[^}\x00]|\x00[^}]*\})*)\}`)
 	where = valRe.ReplaceAllStringFunc(where, func(m string) string {
-		inner := m[1 : len(m)-1]
+		inner := strings.ReplaceAll(m[1:len(m)-1], escapeMark, "${")
 		var val any
 		// numeric?
 		switch inner {
@@ -446,12 +781,13 @@ func (e *expression) expand(where string) string {
 		return fmt.Sprintf(":_%d", e.addValue(val))
 	})
 
-	return where
+	return strings.ReplaceAll(where, escapeMark, "${")
 }
 
 // makeTarget translates a dotted field path into expression attribute name references.
+// Dots escaped as `\.` are part of the attribute name (see EscapePath).
 func (e *expression) makeTarget(fields map[string]*preparedField, name string) string {
-	parts := strings.Split(name, ".")
+	parts := splitPath(name)
 	targets := make([]string, 0, len(parts))
 	for _, part := range parts {
 		subscript := ""
@@ -477,10 +813,51 @@ func (e *expression) makeTarget(fields map[string]*preparedField, name string) s
 }
 
 func (e *expression) prepareKey(key string) string {
-	e.already[key] = true
+	e.already[unescapePath(key)] = true
 	return e.makeTarget(e.model.block.Fields, key)
 }
 
+// EscapePath escapes an attribute name so that it is addressed as a single
+// path element in Where, Set, Add, Delete, Remove and Push, even if it
+// contains dots. Example: "${" + EscapePath("meta.source") + "} = {csv}".
+func EscapePath(name string) string {
+	if !strings.ContainsAny(name, `.\`) {
+		return name
+	}
+	name = strings.ReplaceAll(name, `\`, `\\`)
+	return strings.ReplaceAll(name, ".", `\.`)
+}
+
+// splitPath splits a document path on unescaped dots and unescapes each element.
+func splitPath(path string) []string {
+	if !strings.Contains(path, `\`) {
+		return strings.Split(path, ".")
+	}
+	var parts []string
+	var cur strings.Builder
+	for i := 0; i < len(path); i++ {
+		switch c := path[i]; {
+		case c == '\\' && i+1 < len(path):
+			i++
+			cur.WriteByte(path[i])
+		case c == '.':
+			parts = append(parts, cur.String())
+			cur.Reset()
+		default:
+			cur.WriteByte(c)
+		}
+	}
+	return append(parts, cur.String())
+}
+
+// unescapePath returns the path with escapes removed, joined by dots.
+func unescapePath(path string) string {
+	if !strings.Contains(path, `\`) {
+		return path
+	}
+	return strings.Join(splitPath(path), ".")
+}
+
 func (e *expression) prepareKeyValue(key string, value any) (string, string) {
 	target := e.prepareKey(key)
 	if s, ok := value.(string); ok {
@@ -582,6 +959,9 @@ func (e *expression) command() (Item, error) {
 		if len(e.filters) > 0 {
 			return nil, NewArgError("Invalid filters with batch operation")
 		}
+		if params.Condition != "" {
+			return nil, NewArgError("Invalid conditions with batch operation")
+		}
 		return args, nil
 	}
 
@@ -605,6 +985,17 @@ func (e *expression) command() (Item, error) {
 		projExpr = &s
 	}
 
+	if condExpr != nil {
+		if err := e.checkSize("ConditionExpression", *condExpr, e.conditions); err != nil {
+			return nil, err
+		}
+	}
+	if filterExpr != nil {
+		if err := e.checkSize("FilterExpression", *filterExpr, e.filters); err != nil {
+			return nil, err
+		}
+	}
+
 	args := Item{
 		"TableName": e.tableName,
 	}
@@ -627,41 +1018,40 @@ func (e *expression) command() (Item, error) {
 		args["ProjectionExpression"] = *projExpr
 	}
 
-	if params.Select != "" {
-		args["Select"] = params.Select
-	} else if params.Count {
-		args["Select"] = "COUNT"
+	if e.selects != "" {
+		args["Select"] = e.selects
 	}
 
-	if params.Stats != nil || e.model.table.metrics != nil {
+	if params.Stats != nil || params.MaxCapacity > 0 || e.model.table.metrics != nil {
 		args["ReturnConsumedCapacity"] = coalesce(params.Capacity, "TOTAL")
 		args["ReturnItemCollectionMetrics"] = "SIZE"
 	}
 
 	// return values
-	var returnValues string
-	if params.Return != nil {
-		switch r := params.Return.(type) {
-		case bool:
-			if r {
-				if op == "delete" {
-					returnValues = "ALL_OLD"
-				} else {
-					returnValues = "ALL_NEW"
-				}
-			} else {
-				returnValues = "NONE"
-			}
-		case string:
-			if strings.ToLower(r) == "none" {
-				returnValues = "NONE"
-			} else if r != "get" {
-				returnValues = r
-			}
-		}
+	rv, err := returnValue(op, params.Return)
+	if err != nil {
+		return nil, err
+	}
+	if rv == ReturnGet {
+		// read back by Model.run after the write
+		rv = ReturnNone
 	}
+	returnValues := string(rv)
 
 	switch op {
+	case "check":
+		// a ConditionCheck has no return values, projection or capacity
+		check := Item{"TableName": e.tableName, "Key": key, "ConditionExpression": args["ConditionExpression"]}
+		if namesLen > 0 {
+			check["ExpressionAttributeNames"] = e.names
+			if valuesLen > 0 {
+				check["ExpressionAttributeValues"] = values
+			}
+		}
+		if params.PostFormat != nil {
+			check = params.PostFormat(e.model, check)
+		}
+		return check, nil
 	case "put":
 		args["Item"] = puts
 		if returnValues == "" {
@@ -686,7 +1076,13 @@ func (e *expression) command() (Item, error) {
 		if len(e.updates.set) > 0 {
 			updateParts = append(updateParts, "set "+strings.Join(e.updates.set, ", "))
 		}
-		args["UpdateExpression"] = strings.Join(updateParts, " ")
+		update := strings.Join(updateParts, " ")
+		// chunked updates are checked chunk by chunk
+		terms := slices.Concat(e.updates.add, e.updates.del, e.updates.remove, e.updates.set)
+		if err := e.checkSize("UpdateExpression", update, terms); err != nil && !params.ChunkUpdates {
+			return nil, err
+		}
+		args["UpdateExpression"] = update
 	case "delete":
 		if returnValues == "" {
 			returnValues = "ALL_OLD"
@@ -733,6 +1129,10 @@ func (e *expression) command() (Item, error) {
 				}
 			}
 			if start[e.hash] != nil {
+				sm := e.model.schema
+				for att, v := range start {
+					start[att] = sm.coerceKey(att, v)
+				}
 				mk, err := marshallForDynamo(start)
 				if err == nil {
 					args["ExclusiveStartKey"] = mk
@@ -763,6 +1163,138 @@ func (e *expression) command() (Item, error) {
 	return cleaned, nil
 }
 
+// DynamoDB expression limits.
+const (
+	maxExpressionSize      = 4096
+	maxExpressionOperators = 300
+)
+
+var reExprOperator = regexp.MustCompile(`\(| [-+=<>] | <> | <= | >= | and | or | not | between | in `)
+
+var (
+	reExprPath  = regexp.MustCompile(`#_\d+(?:\.#_\d+|\[\d+\])*`)
+	reExprName  = regexp.MustCompile(`#_\d+`)
+	reExprValue = regexp.MustCompile(`:_\d+`)
+)
+
+// exprOperators estimates the number of operators and functions in expr.
+func exprOperators(expr string, terms []string) int {
+	return len(terms) + len(reExprOperator.FindAllString(strings.ToLower(expr), -1))
+}
+
+// checkSize rejects an expression that exceeds the DynamoDB size or operator
+// limits, naming the field paths of its largest terms.
+func (e *expression) checkSize(name, expr string, terms []string) error {
+	operators := exprOperators(expr, terms)
+	if len(expr) <= maxExpressionSize && operators <= maxExpressionOperators {
+		return nil
+	}
+	largest := slices.Clone(terms)
+	slices.SortStableFunc(largest, func(a, b string) int { return len(b) - len(a) })
+	if len(largest) > 3 {
+		largest = largest[:3]
+	}
+	fields := make([]string, 0, len(largest))
+	for _, term := range largest {
+		fields = append(fields, fmt.Sprintf("%s (%d bytes)", e.termPath(term), len(term)))
+	}
+	return NewError(fmt.Sprintf(`%s for "%s" exceeds DynamoDB limits (%d bytes, ~%d operators; max %d bytes, %d operators). Largest terms: %s`,
+		name, e.model.Name, len(expr), operators, maxExpressionSize, maxExpressionOperators, strings.Join(fields, ", ")),
+		WithCode(ErrArgument), WithContext(map[string]any{"expression": name, "size": len(expr), "operators": operators, "fields": fields}))
+}
+
+// chunkUpdates splits an update command into commands whose UpdateExpression
+// each fit the DynamoDB limits. The first command keeps the caller's
+// conditions, the others only require the item to exist. Only the last one
+// returns values.
+func (e *expression) chunkUpdates(cmd Item) ([]Item, error) {
+	verbs := [4]string{"add", "delete", "remove", "set"}
+	clauses := [4][]string{e.updates.add, e.updates.del, e.updates.remove, e.updates.set}
+	render := func(parts [4][]string) string {
+		var out []string
+		for i, terms := range parts {
+			if len(terms) > 0 {
+				out = append(out, verbs[i]+" "+strings.Join(terms, ", "))
+			}
+		}
+		return strings.Join(out, " ")
+	}
+
+	// greedily fill each chunk in clause order
+	var chunks [][4][]string
+	var chunk [4][]string
+	count := 0
+	for i, terms := range clauses {
+		for _, term := range terms {
+			next := chunk
+			next[i] = append(slices.Clone(chunk[i]), term)
+			expr := render(next)
+			if count > 0 && (len(expr) > maxExpressionSize || exprOperators(expr, nil)+count+1 > maxExpressionOperators) {
+				chunks = append(chunks, chunk)
+				next, count = [4][]string{}, 0
+				next[i] = []string{term}
+			}
+			chunk = next
+			count++
+		}
+	}
+	if count > 0 {
+		chunks = append(chunks, chunk)
+	}
+	if len(chunks) <= 1 {
+		return []Item{cmd}, nil
+	}
+
+	guard := fmt.Sprintf("attribute_exists(#_%d)", e.addName(e.hash))
+	values, _ := cmd["ExpressionAttributeValues"].(map[string]types.AttributeValue)
+	cmds := make([]Item, 0, len(chunks))
+	for i, parts := range chunks {
+		update := render(parts)
+		if err := e.checkSize("UpdateExpression", update, slices.Concat(parts[:]...)); err != nil {
+			return nil, err
+		}
+		args := maps.Clone(cmd)
+		args["UpdateExpression"] = update
+		if i > 0 {
+			args["ConditionExpression"] = guard
+		}
+		if i < len(chunks)-1 {
+			args["ReturnValues"] = "NONE"
+		}
+		// each command may only carry the names and values it references
+		refs, _ := args["ConditionExpression"].(string)
+		refs += " " + update
+		names := map[string]string{}
+		for _, ref := range reExprName.FindAllString(refs, -1) {
+			names[ref] = e.names[ref]
+		}
+		used := map[string]types.AttributeValue{}
+		for _, ref := range reExprValue.FindAllString(refs, -1) {
+			if v, ok := values[ref]; ok {
+				used[ref] = v
+			}
+		}
+		delete(args, "ExpressionAttributeValues")
+		if len(used) > 0 {
+			args["ExpressionAttributeValues"] = used
+		}
+		args["ExpressionAttributeNames"] = names
+		cmds = append(cmds, args)
+	}
+	return cmds, nil
+}
+
+// termPath resolves the first attribute path referenced by an expression term.
+func (e *expression) termPath(term string) string {
+	match := reExprPath.FindString(term)
+	if match == "" {
+		return term
+	}
+	return reExprName.ReplaceAllStringFunc(match, func(ref string) string {
+		return EscapePath(e.names[ref])
+	})
+}
+
 // asSlice wraps a value in a []any if it isn't already.
 func asSlice(v any) []any {
 	if arr, ok := v.([]any); ok {
@@ -0,0 +1,106 @@
+/*
+Package onetable – IAM policy generation.
+
+IAMStatements lists the DynamoDB actions a Table built from a schema issues,
+per resource, so services embedding the library can grant least privilege.
+Transactions need no actions of their own: IAM authorizes each item of a
+TransactWriteItems / TransactGetItems call by its PutItem, UpdateItem,
+DeleteItem, ConditionCheckItem or GetItem action.
+*/
+package onetable
+
+import (
+	"maps"
+	"slices"
+)
+
+// IAMParams selects what IAMStatements grants.
+type IAMParams struct {
+	// TableARN is the table resource, e.g.
+	// "arn:aws:dynamodb:eu-west-1:123456789012:table/MyTable".
+	TableARN string
+	// ReadOnly grants only the read actions.
+	ReadOnly bool
+	// DDL adds CreateTable, DeleteTable, UpdateTable and ListTables.
+	DDL bool
+}
+
+// IAMStatement is one Allow statement of an IAM policy.
+type IAMStatement struct {
+	Actions   []string
+	Resources []string
+}
+
+// IAMStatements returns the Allow statements for the DynamoDB actions this
+// package uses on a table with schema: item, query, scan and batch actions on
+// the table, Query and Scan on each secondary index, and DescribeTable when the
+// schema is nil (keys are then read from the table).
+func IAMStatements(schema *SchemaDef, params IAMParams) ([]IAMStatement, error) {
+	if params.TableARN == "" {
+		return nil, NewArgError("Missing TableARN")
+	}
+	actions := []string{"dynamodb:GetItem", "dynamodb:Query", "dynamodb:Scan", "dynamodb:BatchGetItem"}
+	if !params.ReadOnly {
+		// writes, unique-field sentinels and transaction condition checks
+		actions = append(actions, "dynamodb:PutItem", "dynamodb:UpdateItem", "dynamodb:DeleteItem",
+			"dynamodb:BatchWriteItem", "dynamodb:ConditionCheckItem")
+	}
+	if schema == nil || params.DDL {
+		actions = append(actions, "dynamodb:DescribeTable")
+	}
+	if params.DDL && !params.ReadOnly {
+		actions = append(actions, "dynamodb:CreateTable", "dynamodb:DeleteTable", "dynamodb:UpdateTable")
+		if hasTTLField(schema) {
+			actions = append(actions, "dynamodb:UpdateTimeToLive")
+		}
+	}
+	slices.Sort(actions)
+	statements := []IAMStatement{{Actions: actions, Resources: []string{params.TableARN}}}
+
+	var indexes []string
+	if schema == nil {
+		indexes = []string{params.TableARN + "/index/*"}
+	} else {
+		for _, name := range slices.Sorted(maps.Keys(schema.Indexes)) {
+			if name != "primary" {
+				indexes = append(indexes, params.TableARN+"/index/"+name)
+			}
+		}
+	}
+	if len(indexes) > 0 {
+		statements = append(statements, IAMStatement{Actions: []string{"dynamodb:Query", "dynamodb:Scan"}, Resources: indexes})
+	}
+	if params.DDL {
+		statements = append(statements, IAMStatement{Actions: []string{"dynamodb:ListTables"}, Resources: []string{"*"}})
+	}
+	return statements, nil
+}
+
+// hasTTLField reports whether a model of schema has a TTL field.
+func hasTTLField(schema *SchemaDef) bool {
+	if schema == nil {
+		return false
+	}
+	for _, fields := range schema.Models {
+		for _, def := range fields {
+			if def != nil && def.TTL {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// IAMPolicy returns IAMStatements as an IAM policy document, ready to be
+// marshalled to JSON.
+func IAMPolicy(schema *SchemaDef, params IAMParams) (map[string]any, error) {
+	statements, err := IAMStatements(schema, params)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]map[string]any, 0, len(statements))
+	for _, s := range statements {
+		list = append(list, map[string]any{"Effect": "Allow", "Action": s.Actions, "Resource": s.Resources})
+	}
+	return map[string]any{"Version": "2012-10-17", "Statement": list}, nil
+}
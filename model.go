@@ -7,10 +7,13 @@ putItem / getItem / deleteItem / queryItems / scanItems / updateItem).
 package onetable
 
 import (
+	"cmp"
 	"context"
+	"errors"
 	"fmt"
 	"maps"
 	"math"
+	"reflect"
 	"regexp"
 	"slices"
 	"strconv"
@@ -21,6 +24,7 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 )
 
+// Defaults of TableParams.MaxQueryPages and TableParams.FollowConcurrency.
 const (
 	sanityPages   = 1000
 	followThreads = 10
@@ -46,6 +50,8 @@ type Model struct {
 	nulls        bool
 	nested       bool
 	partial      bool
+	hideExpired  bool
+	requireKey   bool
 
 	// prepared field block (top level)
 	block fieldBlock
@@ -61,43 +67,38 @@ type Model struct {
 	hasUniqueFields bool
 }
 
-// newModel constructs and prepares a Model. fields may be nil for generic/internal models.
+// newModel constructs and prepares a Model bound to the schema generation
+// opts.Schema. fields may be nil for generic/internal models.
 func newModel(table *Table, name string, opts modelOptions) *Model {
 	if table == nil {
 		panic("onetable: nil table")
 	}
+	sm := opts.Schema
 	m := &Model{
 		table:        table,
 		Name:         name,
-		typeField:    coalesce(opts.TypeField, table.typeField),
-		createdField: table.createdField,
-		updatedField: table.updatedField,
+		schema:       sm,
+		typeField:    coalesce(opts.TypeField, sm.params.TypeField),
+		createdField: sm.params.CreatedField,
+		updatedField: sm.params.UpdatedField,
 		tableName:    table.Name,
 		generic:      opts.Generic,
 		timestamps:   opts.Timestamps,
-		nulls:        table.nulls,
+		nulls:        sm.params.Nulls,
 		partial:      table.partial,
+		hideExpired:  table.hideExpired,
+		requireKey:   table.requireKey,
 		block:        fieldBlock{Fields: map[string]*preparedField{}, Deps: nil},
+		indexes:      sm.indexes,
 	}
 
 	if m.timestamps == nil {
-		m.timestamps = table.timestamps
+		m.timestamps = sm.params.Timestamps
 	}
-
-	// prefer explicitly-supplied indexes (needed during schema bootstrap)
-	if opts.Indexes != nil {
-		m.indexes = opts.Indexes
-	} else if table.schemaMgr != nil {
-		m.indexes = table.schemaMgr.indexes
-	}
-
 	if m.indexes == nil {
 		panic("onetable: indexes must be defined before creating models")
 	}
 
-	// schema manager may not be set yet during bootstrap – resolved lazily via m.schema property
-	m.schema = table.schemaMgr
-
 	m.indexProperties = getIndexProperties(m.indexes)
 
 	if opts.Fields != nil {
@@ -110,8 +111,8 @@ type modelOptions struct {
 	Fields     FieldMap
 	TypeField  string
 	Generic    bool
-	Timestamps any                  // override table timestamps
-	Indexes    map[string]*IndexDef // if non-nil, overrides table.schemaMgr.indexes
+	Timestamps any            // override schema timestamps
+	Schema     *schemaManager // generation the model belongs to
 }
 
 func coalesce(a, b string) string {
@@ -121,14 +122,6 @@ func coalesce(a, b string) string {
 	return b
 }
 
-// getSchemaMgr returns the schema manager, resolving lazily from table if needed.
-func (m *Model) getSchemaMgr() *schemaManager {
-	if m.schema != nil {
-		return m.schema
-	}
-	return m.table.schemaMgr
-}
-
 // ─── High-level CRUD ────────────────────────────────────────────────────────
 
 // Params holds optional operation modifiers (mirrors JS params objects).
@@ -140,9 +133,18 @@ type Params struct {
 	High    bool  // high-level API mode (adds type filter, etc.)
 	Hidden  *bool // override hidden field visibility
 	Partial *bool // override partial nested-update behavior
+	// HideExpired overrides TableParams.HideExpired: true → drop items whose
+	// TTL field is in the past from get/find/scan results.
+	HideExpired *bool
+	// RequireKeyCondition overrides TableParams.RequireKeyCondition: true →
+	// Find must build a key condition and Scan is refused.
+	RequireKeyCondition *bool
 
 	// Condition / exists
 	Exists *bool // true=must exist, false=must not exist, nil=don't care
+	// OnConflict selects what Create does when the item already exists;
+	// "" = ConflictFail. Cannot be combined with Exists.
+	OnConflict ConflictStrategy
 
 	// Pagination
 	Limit    int
@@ -150,6 +152,9 @@ type Params struct {
 	Prev     Item // exclusive start key for backward pagination
 	Reverse  bool
 	MaxPages int
+	// MaxCapacity stops a paginated find/scan once it has consumed this many
+	// capacity units (Result.CapacityExceeded)
+	MaxCapacity float64
 
 	// Index selection
 	Index string // index name; "" = primary
@@ -160,11 +165,12 @@ type Params struct {
 	// Read consistency
 	Consistent bool
 
-	// Write return value
-	Return any // true|false|"NONE"|"ALL_NEW"|"ALL_OLD"|"get"
+	// Write return value: a ReturnValue, its string, or true/false
+	Return any
 
 	// Filter / where / set expressions
 	Where         string
+	Condition     string // put/update/delete condition, AND-ed with Exists and Where
 	Set           map[string]string
 	Add           map[string]any
 	Remove        []string
@@ -172,17 +178,32 @@ type Params struct {
 	Push          map[string]any
 	Substitutions map[string]any
 
+	// Joins the Exists check with Where/Condition: "and" (default) | "or"
+	ConditionLogic string
+
+	// Split an update too large for one expression across sequential
+	// UpdateItem calls; only the first carries the conditions
+	ChunkUpdates bool
+
+	// Timestamps and dates
+	SkipTimestamps bool      // don't set the created/updated fields; caller values are written as given
+	Timestamps     time.Time // time written to the created/updated fields instead of now
+	IsoDates       *bool     // override the schema isoDates for dates written by this call
+
 	// Scan segments
 	Segments int
 	Segment  int
 
 	// Count only
 	Count  bool
-	Select string // "COUNT"|"ALL_ATTRIBUTES" etc.
+	Select string // "ALL_ATTRIBUTES"|"ALL_PROJECTED_ATTRIBUTES"|"SPECIFIC_ATTRIBUTES"|"COUNT"
 
 	// Stats
 	Stats    *Stats
 	Capacity string // "INDEXES"|"TOTAL"|"NONE"
+	// MetricTags are extra dimensions for this call. The MetricsCollector and
+	// MonitorFunc see them merged over the model's schema MetricTags.
+	MetricTags map[string]string
 
 	// Batch / transaction references (maps filled by caller)
 	Batch       map[string]any
@@ -190,19 +211,29 @@ type Params struct {
 
 	// Follow GSI to primary
 	Follow *bool
+	// Concurrent Gets when following; 0 = TableParams.FollowConcurrency
+	FollowConcurrency int
 
 	// Many items allowed on remove
 	Many bool
 
 	// Internal: mark already-cloned args
-	checked    bool
-	prepared   bool
-	fallback   bool
+	checked  bool
+	prepared bool
+	fallback bool
+	// nested structures an upsert creates with if_not_exists, by path
+	ifNotExists map[string]bool
+	// empty nested values of partial fields written whole, by path
+	replace    map[string]bool
 	expression *expression // stored during transact/batch for later parseResponse
 
 	// Custom post-format hook
 	PostFormat func(model *Model, cmd map[string]any) map[string]any
 
+	// Client-side find/scan filter: items it returns false for are dropped
+	// after the read (and follow); Next still continues after the last item read
+	PostFilter func(item Item) bool
+
 	// Low-level passthrough: custom DynamoDB client
 	Client DynamoClient
 
@@ -210,6 +241,96 @@ type Params struct {
 	Context context.Context
 }
 
+// ReturnValue selects what a write returns (Params.Return).
+type ReturnValue string
+
+// Params.Return values. Put and delete accept ReturnNone and ReturnAllOld;
+// update accepts all of them.
+const (
+	ReturnNone       ReturnValue = "NONE"
+	ReturnAllNew     ReturnValue = "ALL_NEW"
+	ReturnAllOld     ReturnValue = "ALL_OLD"
+	ReturnUpdatedNew ReturnValue = "UPDATED_NEW"
+	ReturnUpdatedOld ReturnValue = "UPDATED_OLD"
+	// ReturnGet reads the item back with a consistent Get after the update,
+	// e.g. for updates of unique fields, which run in a transaction.
+	ReturnGet ReturnValue = "get"
+)
+
+// returnValues lists the Params.Return values each write op accepts.
+var returnValues = map[string][]ReturnValue{
+	"put":    {ReturnNone, ReturnAllOld},
+	"delete": {ReturnNone, ReturnAllOld},
+	"update": {ReturnNone, ReturnAllNew, ReturnAllOld, ReturnUpdatedNew, ReturnUpdatedOld, ReturnGet},
+}
+
+// returnValue validates Params.Return for op and returns it normalized, or ""
+// for the op's default. true selects ALL_NEW for update, ALL_OLD for delete and
+// NONE for put, whose result is the written item; false selects NONE. Other ops
+// ignore Return.
+func returnValue(op string, value any) (ReturnValue, error) {
+	allowed, ok := returnValues[op]
+	if !ok || value == nil {
+		return "", nil
+	}
+	var rv ReturnValue
+	switch v := value.(type) {
+	case bool:
+		switch {
+		case !v || op == "put":
+			return ReturnNone, nil
+		case op == "delete":
+			return ReturnAllOld, nil
+		}
+		return ReturnAllNew, nil
+	case ReturnValue:
+		rv = v
+	case string:
+		rv = ReturnValue(v)
+	default:
+		return "", NewArgError(fmt.Sprintf("Invalid Params.Return type %T", value))
+	}
+	if !strings.EqualFold(string(rv), string(ReturnGet)) {
+		rv = ReturnValue(strings.ToUpper(string(rv)))
+	} else {
+		rv = ReturnGet
+	}
+	if !slices.Contains(allowed, rv) {
+		return "", NewArgError(fmt.Sprintf(`Params.Return "%s" is not valid for %s, expected one of %v`, value, op, allowed))
+	}
+	return rv, nil
+}
+
+// ConflictStrategy selects what Create does when an item with the same key
+// already exists (Params.OnConflict).
+type ConflictStrategy string
+
+const (
+	// ConflictFail fails the create, as with Exists: false.
+	ConflictFail ConflictStrategy = "fail"
+	// ConflictReplace overwrites the existing item with a Put. Sentinels of
+	// unique fields are moved in the same transaction.
+	ConflictReplace ConflictStrategy = "replace"
+	// ConflictMerge updates the existing item with the given properties, as
+	// Upsert does.
+	ConflictMerge ConflictStrategy = "merge"
+	// ConflictIgnore leaves the existing item alone; Create returns nil.
+	ConflictIgnore ConflictStrategy = "ignore"
+)
+
+// conflictStrategy validates Params.OnConflict and returns it normalized.
+func conflictStrategy(value ConflictStrategy) (ConflictStrategy, error) {
+	strategy := ConflictStrategy(strings.ToLower(string(value)))
+	switch strategy {
+	case "":
+		return ConflictFail, nil
+	case ConflictFail, ConflictReplace, ConflictMerge, ConflictIgnore:
+		return strategy, nil
+	}
+	return "", NewArgError(fmt.Sprintf(`Invalid Params.OnConflict "%s", expected one of %v`, value,
+		[]ConflictStrategy{ConflictFail, ConflictReplace, ConflictMerge, ConflictIgnore}))
+}
+
 // Item is a generic property map returned from / passed to model operations.
 type Item = map[string]any
 
@@ -218,6 +339,7 @@ type Stats struct {
 	Count    int
 	Scanned  int
 	Capacity float64
+	Retries  int // batch retry rounds caused by unprocessed items
 }
 
 // Result is the return type for find/scan operations (items + pagination cursors).
@@ -225,13 +347,54 @@ type Result struct {
 	Items []Item
 	Next  Item // non-nil when more pages exist
 	Prev  Item // non-nil when caller provided Next/Prev
-	Count int  // only set when params.Count==true
+	Count int  // only set when counting (Params.Count or Select "COUNT"); Items is nil then
+	// CapacityExceeded is set when Params.MaxCapacity stopped the pagination
+	// early: Items is partial and Next continues after it
+	CapacityExceeded bool
+
+	table *Table // decode settings
 }
 
 // Create creates a new item. Fails if an item with the same key already exists
-// (mirrors JS exists:false default for create).
+// (mirrors JS exists:false default for create), unless Params.OnConflict
+// selects another strategy.
 func (m *Model) Create(ctx context.Context, properties Item, params *Params) (Item, error) {
+	if params != nil && !params.checked && params.OnConflict != "" && params.Exists != nil {
+		return nil, NewArgError("Params.OnConflict cannot be combined with Params.Exists")
+	}
 	properties, params = m.checkArgs(ctx, properties, params, &Params{Parse: true, High: true, Exists: new(bool)})
+	strategy, err := conflictStrategy(params.OnConflict)
+	if err != nil {
+		return nil, err
+	}
+	switch strategy {
+	case ConflictReplace:
+		params.Exists = nil
+		if m.hasUniqueFields {
+			return m.replaceUnique(ctx, properties, params)
+		}
+		return m.putItem(ctx, properties, params)
+	case ConflictMerge:
+		params.Exists = nil
+		return m.Upsert(ctx, properties, params)
+	case ConflictIgnore:
+		if params.Batch != nil || params.Transaction != nil {
+			return nil, NewArgError("Params.OnConflict \"ignore\" cannot be used in a batch or transaction")
+		}
+		item, err := m.create(ctx, maps.Clone(properties), params)
+		if err == nil || (!isConditionalFailed(err) && errorCode(err) != ErrUnique) {
+			return item, err
+		}
+		// only a conflict on the key is ignored, not one on a unique value
+		if existing, _ := m.Get(ctx, properties, &Params{Consistent: true}); existing != nil {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return m.create(ctx, properties, params)
+}
+
+func (m *Model) create(ctx context.Context, properties Item, params *Params) (Item, error) {
 	if m.hasUniqueFields {
 		return m.createUnique(ctx, properties, params)
 	}
@@ -246,6 +409,9 @@ func (m *Model) Get(ctx context.Context, properties Item, params *Params) (Item,
 		return nil, err
 	}
 	if params.fallback {
+		if params.Batch != nil {
+			return m.followBatchGet(ctx, properties, params)
+		}
 		params.Limit = 2
 		result, err := m.Find(ctx, properties, params)
 		if err != nil {
@@ -315,6 +481,111 @@ func (m *Model) Upsert(ctx context.Context, properties Item, params *Params) (It
 	return m.updateItem(ctx, properties, params)
 }
 
+// Check adds a ConditionCheck on the item with the given key properties to
+// Params.Transaction. The transaction fails unless Params.Where,
+// Params.Condition and/or Params.Exists hold for the item.
+func (m *Model) Check(ctx context.Context, properties Item, params *Params) (Item, error) {
+	properties, params = m.checkArgs(ctx, properties, params, &Params{Parse: true, High: true})
+	if params.Transaction == nil {
+		return nil, NewArgError("Check requires Params.Transaction")
+	}
+	prepared, err := m.prepareProperties(ctx, "get", properties, params)
+	if err != nil {
+		return nil, err
+	}
+	if params.fallback {
+		return nil, NewArgError("Check must use the primary index")
+	}
+	expr, err := newExpression(m, "check", prepared, params)
+	if err != nil {
+		return nil, err
+	}
+	return m.run(ctx, "check", expr)
+}
+
+// UpdateOps are the update expression operations of UpdateByKey, with the
+// same meaning as the Params fields of the same name.
+type UpdateOps struct {
+	Set    map[string]string
+	Add    map[string]any
+	Remove []string
+	Delete map[string]any
+	Push   map[string]any
+}
+
+// UpdateByKey applies expression operations to an existing item addressed by
+// its raw primary key attributes, e.g. {"pk": "Account#42", "sk": "Account#"}.
+// No properties are written: value templates, defaults, validation and the
+// type attribute are skipped, so only ops (and the updated timestamp) change
+// the item. Params.Where / Params.Condition guard the update; Exists defaults
+// to true.
+func (m *Model) UpdateByKey(ctx context.Context, key Item, ops UpdateOps, params *Params) (Item, error) {
+	_, params = m.checkArgs(ctx, nil, params, &Params{Exists: truePtr(), Parse: true, High: true})
+	if len(ops.Set)+len(ops.Add)+len(ops.Remove)+len(ops.Delete)+len(ops.Push) == 0 {
+		return nil, NewArgError("UpdateByKey needs at least one update operation")
+	}
+	primary := m.indexes["primary"]
+	properties := Item{}
+	for _, att := range []string{primary.Hash, primary.Sort} {
+		if att == "" {
+			continue
+		}
+		value, ok := key[att]
+		if !ok || value == nil {
+			return nil, NewArgError(fmt.Sprintf(`Missing key attribute "%s"`, att))
+		}
+		field := m.keyField(att)
+		if field == nil {
+			return nil, NewArgError(fmt.Sprintf(`Model "%s" has no field for key attribute "%s"`, m.Name, att))
+		}
+		properties[field.Name] = value
+	}
+	if len(properties) != len(key) {
+		return nil, NewArgError(fmt.Sprintf(`Key for model "%s" must only hold the primary key attributes`, m.Name))
+	}
+
+	// sentinels of unique fields are only maintained by Update
+	touched := slices.Concat(slices.Collect(maps.Keys(ops.Set)), slices.Collect(maps.Keys(ops.Add)), ops.Remove,
+		slices.Collect(maps.Keys(ops.Delete)), slices.Collect(maps.Keys(ops.Push)))
+	for _, path := range touched {
+		if f, ok := m.block.Fields[splitPath(path)[0]]; ok && f.Def.Unique {
+			return nil, NewArgError(fmt.Sprintf(`Cannot change unique field "%s" with UpdateByKey, use Update`, f.Name))
+		}
+	}
+
+	// copy so the caller's maps are not modified
+	params.Set = maps.Clone(ops.Set)
+	params.Add, params.Remove, params.Delete, params.Push = ops.Add, ops.Remove, ops.Delete, ops.Push
+	if ts := m.schema.params.Timestamps; (ts == true || ts == "update") && !params.SkipTimestamps {
+		if _, ok := params.Set[m.updatedField]; !ok {
+			if params.Set == nil {
+				params.Set = map[string]string{}
+			}
+			now := m.timestampNow(params)
+			var when any = now.UnixMilli()
+			if m.isoDates(m.block.Fields[m.updatedField], params) {
+				when = now.UTC().Format(time.RFC3339Nano)
+			}
+			params.Set[m.updatedField] = fmt.Sprintf("{%v}", when)
+		}
+	}
+	expr, err := newExpression(m, "update", properties, params)
+	if err != nil {
+		return nil, err
+	}
+	return m.run(ctx, "update", expr)
+}
+
+// keyField returns the top-level field stored in attribute att.
+func (m *Model) keyField(att string) *preparedField {
+	for _, field := range m.block.Fields {
+		if len(field.Attribute) == 1 && field.Attribute[0] == att {
+			return field
+		}
+	}
+	return nil
+}
+
 // Remove deletes an item by its key properties.
 func (m *Model) Remove(ctx context.Context, properties Item, params *Params) (Item, error) {
 	properties, params = m.checkArgs(ctx, properties, params, &Params{Parse: true, High: true})
@@ -339,6 +610,135 @@ func (m *Model) Remove(ctx context.Context, properties Item, params *Params) (It
 	return item, nil
 }
 
+// ReKey rewrites the item identified by oldProps with newProps applied and
+// all value templates, index keys included, recomputed. Use it to change a
+// field that a key template depends on. If the primary key changes, the old
+// item is deleted and the new one created in one transaction. Sentinels of
+// changed unique fields are moved in the same transaction. The created
+// timestamp is kept. The writes fail with ErrUnique if the item was updated
+// since it was read; without update timestamps the last write wins. With
+// Params.Transaction the writes are only added to it. Params.Index finds the
+// old item on a secondary index.
+func (m *Model) ReKey(ctx context.Context, oldProps, newProps Item, params *Params) (Item, error) {
+	_, params = m.checkArgs(ctx, nil, params, &Params{Parse: true, High: true})
+	// secondary indexes do not support consistent reads
+	primary := params.Index == "" || params.Index == "primary"
+	prior, err := m.Get(ctx, oldProps, &Params{Hidden: truePtr(), Index: params.Index, Consistent: primary})
+	if err != nil {
+		return nil, err
+	}
+	if prior == nil {
+		return nil, NewError("Cannot find existing item to re-key", WithCode(ErrNotFound),
+			WithContext(map[string]any{"properties": oldProps}))
+	}
+	properties := Item{}
+	for name, value := range prior {
+		if field := m.block.Fields[name]; field == nil || field.ValueTemplate == "" {
+			properties[name] = value
+		}
+	}
+	maps.Copy(properties, newProps)
+	if ts := m.schema.params.Timestamps; (ts == true || ts == "update") && !params.SkipTimestamps {
+		properties[m.updatedField] = m.timestampNow(params)
+	}
+	return m.replaceItem(ctx, prior, properties, params)
+}
+
+// replaceItem writes properties in place of prior, an item read with hidden
+// fields. If the primary key changed, prior is deleted. The sentinels of
+// changed unique fields are moved. All writes run in one transaction, or are
+// added to Params.Transaction. Timestamps are written as given in properties.
+// The transaction fails if the updated timestamp of prior has changed; for
+// models without update timestamps the last write wins.
+func (m *Model) replaceItem(ctx context.Context, prior, properties Item, params *Params) (Item, error) {
+	transactHere := params.Transaction == nil
+	if transactHere {
+		params.Transaction = map[string]any{}
+	}
+	// the put keeps the caller's params (Hidden, Return, Log, ...)
+	put := *params
+	put.SkipTimestamps = true
+	put.Index = "" // Params.Index only locates prior, see ReKey
+	_, putParams := m.checkArgs(ctx, nil, &put, nil)
+	properties, err := m.prepareProperties(ctx, "put", properties, putParams)
+	if err != nil {
+		return nil, err
+	}
+	putParams.prepared = true
+	priorProps, err := m.prepareProperties(ctx, "update", prior, &Params{})
+	if err != nil {
+		return nil, err
+	}
+
+	primary := m.indexes["primary"]
+	oldKey := Item{primary.Hash: prior[primary.Hash]}
+	moved := fmt.Sprint(prior[primary.Hash]) != fmt.Sprint(properties[primary.Hash])
+	if primary.Sort != "" {
+		oldKey[primary.Sort] = prior[primary.Sort]
+		moved = moved || fmt.Sprint(prior[primary.Sort]) != fmt.Sprint(properties[primary.Sort])
+	}
+	// the write of prior is conditional on its updated timestamp, so a write
+	// made since prior was read fails the transaction
+	unchanged := &Params{Transaction: params.Transaction, Exists: truePtr()}
+	// updated may be stored in either date format, see Params.IsoDates
+	if field := m.block.Fields[m.updatedField]; field != nil && prior[m.updatedField] != nil {
+		unchanged.Where = fmt.Sprintf("${%[1]s} = @{_priorMs} or ${%[1]s} = @{_priorIso}", m.updatedField)
+		unchanged.Substitutions = map[string]any{
+			"_priorMs":  m.transformWriteDate(field, prior[m.updatedField], &Params{IsoDates: new(bool)}),
+			"_priorIso": m.transformWriteDate(field, prior[m.updatedField], &Params{IsoDates: truePtr()}),
+		}
+	}
+	if moved {
+		_, delParams := m.checkArgs(ctx, nil, unchanged, nil)
+		delParams.prepared = true
+		if _, err := m.deleteItem(ctx, oldKey, delParams); err != nil {
+			return nil, err
+		}
+		putParams.Exists = new(bool)
+	} else {
+		putParams.Exists = truePtr()
+		putParams.Where, putParams.Substitutions = unchanged.Where, unchanged.Substitutions
+	}
+
+	for _, field := range m.block.Fields {
+		if !field.Def.Unique || field.Attribute[0] == primary.Hash || field.Attribute[0] == primary.Sort {
+			continue
+		}
+		priorVal, newVal := priorProps[field.Name], properties[field.Name]
+		if fmt.Sprint(priorVal) == fmt.Sprint(newVal) {
+			continue
+		}
+		sk := "_unique#"
+		if priorVal != nil {
+			pk := fmt.Sprintf("_unique#%s#%s#%v", m.Name, field.Attribute[0], priorVal)
+			if _, err := m.schema.uniqueModel.Remove(ctx, Item{primary.Hash: pk, primary.Sort: sk},
+				&Params{Transaction: params.Transaction}); err != nil {
+				return nil, err
+			}
+		}
+		if newVal != nil {
+			pk := fmt.Sprintf("_unique#%s#%s#%v", m.Name, field.Attribute[0], newVal)
+			if _, err := m.schema.uniqueModel.Create(ctx, Item{primary.Hash: pk, primary.Sort: sk},
+				&Params{Transaction: params.Transaction, Exists: new(bool), Return: "NONE"}); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	item, err := m.putItem(ctx, properties, putParams)
+	if err != nil || !transactHere {
+		return item, err
+	}
+	if _, err := m.table.Transact(ctx, "write", params.Transaction, params); err != nil {
+		if isConditionalFailed(err) {
+			return nil, NewError(fmt.Sprintf(`Cannot replace "%s". The item has changed, or its new key or a unique value is taken.`, m.Name),
+				WithCode(ErrUnique), WithCause(err))
+		}
+		return nil, err
+	}
+	return item, nil
+}
+
 // Init initializes a local item with defaults and value templates without writing to DynamoDB.
 func (m *Model) Init(ctx context.Context, properties Item, params *Params) (Item, error) {
 	properties, params = m.checkArgs(ctx, properties, params, &Params{Parse: true, High: true})
@@ -350,18 +750,9 @@ func (m *Model) Init(ctx context.Context, properties Item, params *Params) (Item
 func (m *Model) putItem(ctx context.Context, properties Item, params *Params) (Item, error) {
 	properties, params = m.checkArgs(ctx, properties, params, nil)
 	if !params.prepared {
-		if params.Transaction == nil || params.Transaction["timestamp"] == nil {
-			now := time.Now()
-			ts := m.table.timestamps
-			if ts == true || ts == "create" {
-				properties[m.createdField] = now
-			}
-			if ts == true || ts == "update" {
-				properties[m.updatedField] = now
-			}
-		} else {
-			ts := m.table.timestamps
-			now := params.Transaction["timestamp"].(time.Time)
+		if !params.SkipTimestamps {
+			now := m.timestampNow(params)
+			ts := m.schema.params.Timestamps
 			if ts == true || ts == "create" {
 				properties[m.createdField] = now
 			}
@@ -421,11 +812,19 @@ func (m *Model) queryItems(ctx context.Context, properties Item, params *Params)
 	if err != nil {
 		return nil, err
 	}
+	if len(expr.keys) == 0 && m.getRequireKeyCondition(params) {
+		return nil, NewError(fmt.Sprintf(`Cannot find "%s" without a key condition`, m.Name),
+			WithCode(ErrArgument), WithContext(map[string]any{"properties": properties}))
+	}
 	return m.runMulti(ctx, "find", expr)
 }
 
 func (m *Model) scanItems(ctx context.Context, properties Item, params *Params) (*Result, error) {
 	properties, params = m.checkArgs(ctx, properties, params, nil)
+	if m.getRequireKeyCondition(params) {
+		return nil, NewError(fmt.Sprintf(`Scan of "%s" refused: key conditions are required. Set Params.RequireKeyCondition to false to scan.`, m.Name),
+			WithCode(ErrArgument), WithContext(map[string]any{"properties": properties}))
+	}
 	prepared, err := m.prepareProperties(ctx, "scan", properties, params)
 	if err != nil {
 		return nil, err
@@ -439,25 +838,17 @@ func (m *Model) scanItems(ctx context.Context, properties Item, params *Params)
 
 func (m *Model) updateItem(ctx context.Context, properties Item, params *Params) (Item, error) {
 	properties, params = m.checkArgs(ctx, properties, params, nil)
-	ts := m.table.timestamps
-	if ts == true || ts == "update" {
-		var now time.Time
+	ts := m.schema.params.Timestamps
+	if (ts == true || ts == "update") && !params.SkipTimestamps {
+		now := m.timestampNow(params)
 		if params.Transaction != nil {
-			if t, ok := params.Transaction["timestamp"]; ok {
-				now = t.(time.Time)
-			} else {
-				now = time.Now()
-				params.Transaction["timestamp"] = now
-			}
-		} else {
-			now = time.Now()
+			params.Transaction["timestamp"] = now
 		}
 		properties[m.updatedField] = now
 		// if_not_exists for createdField when upserting
 		if params.Exists == nil && (ts == true) {
-			isoDates := m.table.isoDates
 			var when any
-			if isoDates {
+			if m.isoDates(m.block.Fields[m.createdField], params) {
 				when = now.UTC().Format(time.RFC3339Nano)
 			} else {
 				when = now.UnixMilli()
@@ -479,6 +870,18 @@ func (m *Model) updateItem(ctx context.Context, properties Item, params *Params)
 	return m.run(ctx, "update", expr)
 }
 
+// timestampNow returns the time for the created/updated fields: Params.Timestamps,
+// else the timestamp shared by the operations of a transaction, else now.
+func (m *Model) timestampNow(params *Params) time.Time {
+	if !params.Timestamps.IsZero() {
+		return params.Timestamps
+	}
+	if t, ok := params.Transaction["timestamp"].(time.Time); ok {
+		return t
+	}
+	return time.Now()
+}
+
 func (m *Model) initItem(ctx context.Context, properties Item, params *Params) (Item, error) {
 	fields := m.block.Fields
 	m.setDefaults("init", fields, properties, params)
@@ -522,11 +925,29 @@ func (m *Model) run(ctx context.Context, op string, expr *expression) (Item, err
 		return m.accumulateTransaction(op, cmd, expr)
 	}
 
-	result, err := m.table.execute(ctx, m.Name, op, cmd, expr.properties, params)
+	// chunked update: write all but the last chunk, then fall through with it
+	if op == "update" && params.ChunkUpdates {
+		cmds, err := expr.chunkUpdates(cmd)
+		if err != nil {
+			return nil, err
+		}
+		for _, chunk := range cmds[:len(cmds)-1] {
+			if _, err := m.table.execute(ctx, m.schema, m.Name, op, chunk, expr.properties, params); err != nil {
+				return nil, err
+			}
+		}
+		cmd = cmds[len(cmds)-1]
+	}
+
+	result, err := m.table.execute(ctx, m.schema, m.Name, op, cmd, expr.properties, params)
 	if err != nil {
 		return nil, err
 	}
 
+	if rv, _ := returnValue(op, params.Return); rv == ReturnGet {
+		return m.Get(ctx, m.keyProperties(expr.key), &Params{Hidden: params.Hidden, Consistent: true})
+	}
+
 	if !params.Parse {
 		return result, nil
 	}
@@ -566,22 +987,36 @@ func (m *Model) runMulti(ctx context.Context, op string, expr *expression) (*Res
 		return nil, err
 	}
 
+	if expr.count && params.PostFilter != nil {
+		return nil, NewArgError("Params.PostFilter cannot be used when counting")
+	}
+	for field := range expr.elements {
+		if expr.count {
+			return nil, NewArgError("AnyElement filters cannot be used when counting")
+		}
+		if params.Fields != nil && !slices.Contains(params.Fields, field.Name) {
+			return nil, NewArgError(fmt.Sprintf(`Params.Fields must include "%s" to filter its elements`, field.Name))
+		}
+	}
+
 	if !expr.execute {
 		return &Result{Items: []Item{cmd}}, nil
 	}
 
 	maxPages := params.MaxPages
 	if maxPages == 0 {
-		maxPages = sanityPages
+		maxPages = m.table.maxQueryPages
 	}
 
 	var rawItems []Item
 	var lastKey Item
 	var totalCount int
+	var capacity float64
+	exceeded := false
 	pages := 0
 
 	for {
-		result, err := m.table.execute(ctx, m.Name, op, cmd, expr.properties, params)
+		result, err := m.table.execute(ctx, m.schema, m.Name, op, cmd, expr.properties, params)
 		if err != nil {
 			return nil, err
 		}
@@ -594,6 +1029,11 @@ func (m *Model) runMulti(ctx context.Context, op string, expr *expression) (*Res
 			totalCount += toInt(result["Count"])
 		}
 
+		var units float64
+		if consumed, ok := result["ConsumedCapacity"].(map[string]any); ok {
+			units, _ = consumed["CapacityUnits"].(float64)
+		}
+		capacity += units
 		if params.Stats != nil {
 			if c := toInt(result["Count"]); c > 0 {
 				params.Stats.Count += c
@@ -601,18 +1041,18 @@ func (m *Model) runMulti(ctx context.Context, op string, expr *expression) (*Res
 			if s := toInt(result["ScannedCount"]); s > 0 {
 				params.Stats.Scanned += s
 			}
-			if consumed, ok := result["ConsumedCapacity"].(map[string]any); ok {
-				if u, ok := consumed["CapacityUnits"].(float64); ok {
-					params.Stats.Capacity += u
-				}
-			}
+			params.Stats.Capacity += units
 		}
 
 		lk, hasMore := result["LastEvaluatedKey"].(Item)
 		if hasMore {
-			cmd["ExclusiveStartKey"] = lk
-			lastKey = lk
+			esk, err := marshallForDynamo(lk)
+			if err != nil {
+				return nil, NewError("Cannot marshal LastEvaluatedKey", WithCode(ErrRuntime), WithCause(err))
+			}
+			cmd["ExclusiveStartKey"] = esk
 		}
+		lastKey = lk
 
 		if params.Limit > 0 && len(rawItems) >= params.Limit {
 			break
@@ -621,6 +1061,10 @@ func (m *Model) runMulti(ctx context.Context, op string, expr *expression) (*Res
 		if !hasMore || pages >= maxPages {
 			break
 		}
+		if params.MaxCapacity > 0 && capacity >= params.MaxCapacity {
+			exceeded = true
+			break
+		}
 	}
 
 	// compute prev cursor (first item keys)
@@ -642,9 +1086,9 @@ func (m *Model) runMulti(ctx context.Context, op string, expr *expression) (*Res
 		}
 	}
 
-	// parse response
+	// parse response; followed keys are parsed by the follow gets
 	var items []Item
-	if params.Parse {
+	if params.Parse && !expr.follow {
 		items, err = m.parseResponse(ctx, op, expr, rawItems)
 		if err != nil {
 			return nil, err
@@ -653,7 +1097,7 @@ func (m *Model) runMulti(ctx context.Context, op string, expr *expression) (*Res
 		items = rawItems
 	}
 
-	result := &Result{Items: items}
+	result := &Result{Items: items, CapacityExceeded: exceeded, table: m.table}
 
 	if lastKey != nil {
 		result.Next = m.table.unmarshallItem(lastKey)
@@ -661,7 +1105,8 @@ func (m *Model) runMulti(ctx context.Context, op string, expr *expression) (*Res
 	if prev != nil {
 		result.Prev = m.table.unmarshallItem(prev)
 	}
-	if params.Count || params.Select == "COUNT" {
+	if expr.count {
+		result.Items = nil
 		result.Count = totalCount
 	}
 
@@ -672,16 +1117,54 @@ func (m *Model) runMulti(ctx context.Context, op string, expr *expression) (*Res
 	}
 
 	// follow: resolve GSI items to primary via get
-	if shouldFollow(params, m.selectIndex(params)) {
-		result.Items, err = m.followItems(ctx, op, result.Items, params)
+	if expr.follow {
+		result.Items, err = m.followItems(ctx, result.Items, params)
 		if err != nil {
 			return nil, err
 		}
 	}
 
+	// AnyElement filters DynamoDB cannot evaluate
+	for field, filter := range expr.elements {
+		name := field.Name
+		if !params.Parse {
+			name = field.Attribute[0]
+		}
+		result.Items = slices.DeleteFunc(result.Items, func(item Item) bool {
+			return !filter.matches(item[name])
+		})
+	}
+	if params.PostFilter != nil {
+		result.Items = slices.DeleteFunc(result.Items, func(item Item) bool {
+			return !params.PostFilter(item)
+		})
+	}
+
 	return result, nil
 }
 
+// fullRead reports whether op read whole items, so a missing required field
+// is a schema mismatch rather than an effect of the read. Projected, counted
+// and followed reads, reads of index items that are not fully projected, and
+// batch or transaction items (which carry only the given properties) are not.
+func (m *Model) fullRead(op string, params *Params, expr *expression) bool {
+	if expr == nil || params == nil || params.Batch != nil || params.Transaction != nil {
+		return false
+	}
+	switch op {
+	case "get", "find", "scan":
+	default:
+		return false
+	}
+	if len(params.Fields) > 0 || expr.count || expr.follow {
+		return false
+	}
+	if expr.selects != "" && expr.selects != "ALL_ATTRIBUTES" {
+		return false
+	}
+	return expr.index == nil || expr.index == m.indexes["primary"] || m.getProjection(expr.index) == nil
+}
+
 // ─── parseResponse ──────────────────────────────────────────────────────────
 
 func (m *Model) parseResponse(ctx context.Context, op string, expr *expression, raw []Item) ([]Item, error) {
@@ -693,16 +1176,22 @@ func (m *Model) parseResponse(ctx context.Context, op string, expr *expression,
 	}
 	// raw is already unmarshaled by execute() – no extra conversion needed
 
+	hideExpired := (op == "get" || op == "find" || op == "scan") && m.getHideExpired(expr.params)
+	now := time.Now()
+
 	for _, item := range raw {
 		typeName, _ := item[m.typeField].(string)
 		if typeName == "" {
 			typeName = m.Name
 		}
-		mod := m.getSchemaMgr().models[typeName]
+		mod := m.schema.models[typeName]
 		if mod == nil {
 			mod = m
 		}
-		if mod == m.getSchemaMgr().uniqueModel {
+		if mod == m.schema.uniqueModel {
+			continue
+		}
+		if hideExpired && mod.isExpired(item, now) {
 			continue
 		}
 		transformed := mod.transformReadItem(op, item, expr.properties, expr.params, expr)
@@ -719,10 +1208,11 @@ func (m *Model) transformReadItem(op string, raw Item, properties Item, params *
 	if raw == nil {
 		return nil
 	}
-	return m.transformReadBlock(op, raw, properties, params, m.block.Fields, expr)
+	aad := m.cryptAAD(raw, op == "put")
+	return m.transformReadBlock(op, raw, properties, params, m.block.Fields, expr, aad)
 }
 
-func (m *Model) transformReadBlock(op string, raw Item, properties Item, params *Params, fields map[string]*preparedField, expr *expression) Item {
+func (m *Model) transformReadBlock(op string, raw Item, properties Item, params *Params, fields map[string]*preparedField, expr *expression, aad []byte) Item {
 	rec := Item{}
 	showHidden := params != nil && params.Hidden != nil && *params.Hidden
 
@@ -775,9 +1265,9 @@ func (m *Model) transformReadBlock(op string, raw Item, properties Item, params
 		}
 
 		// decrypt
-		if field.Def.Crypt && value != nil {
+		if field.Crypt && value != nil {
 			if s, ok := value.(string); ok {
-				dec, err := m.table.decrypt(s)
+				dec, err := m.table.decrypt(s, aad)
 				if err == nil {
 					value = dec
 				}
@@ -789,14 +1279,14 @@ func (m *Model) transformReadBlock(op string, raw Item, properties Item, params
 				if params == nil || params.Fields == nil || containsStr(params.Fields, name) {
 					rec[name] = field.Def.Default
 				}
-			} else if field.Required {
-				// warn if required field missing (skip for transactions/batch/projections)
-				if params != nil && !params.checked /* batch */ && params.Transaction == nil &&
-					params.Batch == nil && params.Fields == nil {
-					if m.table.warn {
-						logError(m.table.log, fmt.Sprintf(`Required field "%s" in model "%s" not in item`, name, m.Name), nil)
-					}
-				}
+			} else if field.Required && m.schema.params.Warn && m.fullRead(op, params, expr) {
+				m.table.warn(Warning{
+					Code:    WarnMissingRequired,
+					Model:   m.Name,
+					Field:   name,
+					Op:      op,
+					Message: fmt.Sprintf(`Required field "%s" in model "%s" not in item`, name, m.Name),
+				})
 			}
 			continue
 		}
@@ -816,7 +1306,7 @@ func (m *Model) transformReadBlock(op string, raw Item, properties Item, params
 						propElem, _ = propArr[i].(Item)
 					}
 					if em, ok := elem.(map[string]any); ok {
-						arr = append(arr, m.transformReadBlock(op, em, propElem, params, field.Block.Fields, expr))
+						arr = append(arr, m.transformReadBlock(op, em, propElem, params, field.Block.Fields, expr, aad))
 					}
 				}
 				rec[name] = arr
@@ -825,7 +1315,7 @@ func (m *Model) transformReadBlock(op string, raw Item, properties Item, params
 				if properties != nil {
 					propNested, _ = properties[name].(Item)
 				}
-				rec[name] = m.transformReadBlock(op, v, propNested, params, field.Block.Fields, expr)
+				rec[name] = m.transformReadBlock(op, v, propNested, params, field.Block.Fields, expr, aad)
 			}
 			continue
 		}
@@ -905,13 +1395,16 @@ func (m *Model) prepareProperties(ctx context.Context, op string, properties Ite
 		return properties, nil
 	}
 
-	rec, err := m.collectProperties(ctx, op, "", &m.block, index, properties, params, nil)
+	rec, err := m.collectProperties(ctx, op, "", &m.block, index, properties, params, nil, op == "put")
 	if err != nil {
 		return nil, err
 	}
 	if params.fallback {
 		return properties, nil
 	}
+	if op == "put" || op == "update" {
+		m.encryptProperties(m.block.Fields, rec, m.cryptAAD(rec, true))
+	}
 
 	// ensure hash key is present for non-scan ops
 	if op != "scan" && m.getHashValue(rec, m.block.Fields, index) == nil {
@@ -922,9 +1415,10 @@ func (m *Model) prepareProperties(ctx context.Context, op string, properties Ite
 	return rec, nil
 }
 
-// collectProperties processes one schema level recursively.
+// collectProperties processes one schema level recursively. create is set when
+// the level is written as a new structure rather than merged into the item.
 func (m *Model) collectProperties(ctx context.Context, op, pathname string, block *fieldBlock,
-	index *IndexDef, properties Item, params *Params, context Item) (Item, error) {
+	index *IndexDef, properties Item, params *Params, context Item, create bool) (Item, error) {
 
 	fields := block.Fields
 	rec := Item{}
@@ -935,45 +1429,65 @@ func (m *Model) collectProperties(ctx context.Context, op, pathname string, bloc
 
 	// nested schemas first
 	if m.nested && !keysOnlyOp(op) {
-		if err := m.collectNested(ctx, op, pathname, fields, index, properties, params, context, rec); err != nil {
+		if err := m.collectNested(ctx, op, pathname, fields, index, properties, params, context, rec, create); err != nil {
 			return nil, err
 		}
 	}
 
 	m.addContext(op, fields, index, properties, params, context)
-	m.setDefaults(op, fields, properties, params)
+	// an upsert must not default the fields of a nested level it merges into
+	// an existing structure
+	if pathname == "" || create || op != "update" {
+		m.setDefaults(op, fields, properties, params)
+	}
 	if err := m.runTemplates(op, pathname, index, block.Deps, properties, params); err != nil {
 		return nil, err
 	}
 	m.convertNulls(op, pathname, fields, properties, params)
-	if err := m.validateProperties(op, fields, properties, params); err != nil {
+	if err := m.validateProperties(op, pathname, fields, properties, params); err != nil {
 		return nil, err
 	}
 	m.selectProperties(op, block, index, properties, params, rec)
-	m.transformProperties(op, fields, properties, params, rec)
+	if err := m.transformProperties(op, fields, properties, params, rec); err != nil {
+		return nil, err
+	}
 
 	return rec, nil
 }
 
 func (m *Model) collectNested(ctx context.Context, op, pathname string, fields map[string]*preparedField,
-	index *IndexDef, properties Item, params *Params, context Item, rec Item) error {
+	index *IndexDef, properties Item, params *Params, context Item, rec Item, create bool) error {
 
+	upsert := op == "update" && params.Exists == nil
 	for _, field := range fields {
 		if field.Block == nil {
 			continue
 		}
 		name := field.Name
+		path := name
+		if pathname != "" {
+			path = pathname + "." + name
+		}
 		value := properties[name]
-		if op == "put" && value == nil {
+		if isFilterValue(value) && (op == "find" || op == "scan") {
+			rec[name] = value
+			continue
+		}
+		created := false
+		if (op == "put" || upsert) && value == nil {
 			if field.Required {
 				if field.Type == FieldTypeArray {
 					value = []any{}
 				} else {
 					value = Item{}
 				}
+			} else if def, ok := field.Def.Default.(Item); ok {
+				// collecting modifies the value, keep the schema's default intact
+				value = maps.Clone(def)
 			} else if field.Def.Default != nil {
 				value = field.Def.Default
 			}
+			created = value != nil
 		}
 		ctx2, _ := context[name].(Item)
 		partial := m.getPartial(field, params)
@@ -981,17 +1495,33 @@ func (m *Model) collectNested(ctx context.Context, op, pathname string, fields m
 		if value == nil {
 			continue
 		}
+		if created && upsert && !create {
+			// the existing item may hold the structure already: only write
+			// it if missing
+			if params.ifNotExists == nil {
+				params.ifNotExists = map[string]bool{}
+			}
+			params.ifNotExists[path] = true
+		}
+		// a supplied empty object or list has nothing to merge: it replaces
+		// the stored value like it does on create
+		if partial && !created && isEmptyValue(value) {
+			if params.replace == nil {
+				params.replace = map[string]bool{}
+			}
+			params.replace[path] = true
+			partial = false
+		}
+		// a supplied nested value not merged field by field replaces the
+		// stored one and is written as a new structure
+		childCreate := create || created || !partial
 
 		if field.IsArray {
-			if arr, ok := value.([]any); ok {
+			if arr := nestedElements(value); arr != nil {
 				result := make([]any, 0, len(arr))
 				for i, elem := range arr {
-					path := fmt.Sprintf("%s[%d]", name, i)
-					if pathname != "" {
-						path = pathname + "." + path
-					}
 					elemMap, _ := elem.(Item)
-					obj, err := m.collectProperties(ctx, op, path, field.Block, index, elemMap, params, ctx2)
+					obj, err := m.collectProperties(ctx, op, fmt.Sprintf("%s[%d]", path, i), field.Block, index, elemMap, params, ctx2, childCreate)
 					if err != nil {
 						return err
 					}
@@ -999,18 +1529,19 @@ func (m *Model) collectNested(ctx context.Context, op, pathname string, fields m
 						result = append(result, obj)
 					}
 				}
-				rec[name] = result
+				if len(result) > 0 || !field.Def.OmitEmpty {
+					rec[name] = result
+				}
 			}
 		} else {
 			valMap, _ := value.(Item)
-			path := name
-			if pathname != "" {
-				path = pathname + "." + name
-			}
-			obj, err := m.collectProperties(ctx, op, path, field.Block, index, valMap, params, ctx2)
+			obj, err := m.collectProperties(ctx, op, path, field.Block, index, valMap, params, ctx2, childCreate)
 			if err != nil {
 				return err
 			}
+			if len(obj) == 0 && field.Def.OmitEmpty {
+				continue
+			}
 			if !partial || len(obj) > 0 || field.Def.Default != nil {
 				rec[name] = obj
 			}
@@ -1093,23 +1624,34 @@ func (m *Model) runTemplates(op, pathname string, index *IndexDef, deps []*prepa
 	return nil
 }
 
-// runTemplate expands a single value template string.
+// runTemplate expands a single value template string. "$${" stands for a
+// literal "${".
 func (m *Model) runTemplate(op string, index *IndexDef, field *preparedField, properties Item, params *Params, tmpl string) (any, error) {
-	re := regexp.MustCompile(`\$\{(.*?)\}`)
-	result := re.ReplaceAllStringFunc(tmpl, func(match string) string {
-		inner := match[2 : len(match)-1] // strip ${ and }
-		parts := strings.SplitN(inner, ":", 3)
+	tmpl = strings.ReplaceAll(tmpl, templateEscape, escapeMark)
+	var b strings.Builder
+	prefix, unresolved := "", false
+	last := 0
+	for _, loc := range reTemplateVar.FindAllStringSubmatchIndex(tmpl, -1) {
+		b.WriteString(tmpl[last:loc[0]])
+		last = loc[1]
+		match := tmpl[loc[0]:loc[1]]
+		parts := strings.SplitN(tmpl[loc[2]:loc[3]], ":", 3)
 		varName := parts[0]
 
-		v := getPropValue(properties, varName)
+		v := m.templateValue(properties, params, varName)
 		if v == nil {
-			return match // unresolved – keep placeholder
+			// unresolved – keep placeholder
+			if !unresolved {
+				prefix, unresolved = b.String(), true
+			}
+			b.WriteString(match)
+			continue
 		}
 
 		var s string
 		switch tv := v.(type) {
 		case time.Time:
-			if field.IsoDates || m.table.isoDates {
+			if field.IsoDates || m.schema.params.IsoDates {
 				s = tv.UTC().Format(time.RFC3339Nano)
 			} else {
 				s = strconv.FormatInt(tv.UnixMilli(), 10)
@@ -1129,20 +1671,19 @@ func (m *Model) runTemplate(op string, index *IndexDef, field *preparedField, pr
 				s = pad + s
 			}
 		}
-		return s
-	})
+		b.WriteString(s)
+	}
+	b.WriteString(tmpl[last:])
 
 	// unresolved variables remain?
-	if strings.Contains(result, "${") {
-		if index != nil && field.Attribute[0] == index.Sort && op == "find" {
-			// strip from first unresolved ${ onward, use prefix for begins_with
-			if prefix, _, ok := strings.Cut(result, "${"); ok && prefix != "" {
-				return map[string]any{"begins": prefix}, nil
-			}
+	if unresolved {
+		if index != nil && field.Attribute[0] == index.Sort && op == "find" && prefix != "" {
+			// use the prefix before the first unresolved variable for begins_with
+			return map[string]any{"begins": strings.ReplaceAll(prefix, escapeMark, "${")}, nil
 		}
 		return nil, nil // not yet resolvable
 	}
-	return result, nil
+	return strings.ReplaceAll(b.String(), escapeMark, "${"), nil
 }
 
 // convertNulls removes null properties unless nulls==true; adds to params.Remove.
@@ -1162,53 +1703,140 @@ func (m *Model) convertNulls(op, pathname string, fields map[string]*preparedFie
 				path = pathname + "." + name
 			}
 			params.Remove = append(params.Remove, path)
+		} else if field.Def.OmitEmpty && isEmptyValue(value) {
+			delete(properties, name)
 		}
 	}
 }
 
-// validateProperties checks required fields, regex, enum constraints.
-func (m *Model) validateProperties(op string, fields map[string]*preparedField, properties Item, params *Params) error {
-	if op != "put" && op != "update" {
+// isEmptyValue reports whether value is an empty object or list.
+func isEmptyValue(value any) bool {
+	if value == nil {
+		return false
+	}
+	switch v := reflect.ValueOf(value); v.Kind() {
+	case reflect.Map, reflect.Slice:
+		return v.Len() == 0
+	}
+	return false
+}
+
+// validateProperties validates the properties of the top-level block,
+// including nested schemas. Nested blocks are validated as part of the top-level
+// walk so that all failures are reported together under path-qualified keys
+// like "location.zip" or "items[1].sku".
+func (m *Model) validateProperties(op, pathname string, fields map[string]*preparedField, properties Item, params *Params) error {
+	if (op != "put" && op != "update") || pathname != "" {
 		return nil
 	}
 	validation := map[string]string{}
+	if err := m.validateBlock(op, "", fields, properties, validation, params); err != nil {
+		return err
+	}
+	if len(validation) > 0 {
+		keys := make([]string, 0, len(validation))
+		for k := range validation {
+			keys = append(keys, k)
+		}
+		slices.Sort(keys)
+		return NewError(fmt.Sprintf(`Validation Error in "%s" for "%s"`, m.Name, strings.Join(keys, ", ")),
+			WithCode(ErrValidation), WithContext(map[string]any{"validation": validation}))
+	}
+	return nil
+}
 
+func (m *Model) validateBlock(op, pathname string, fields map[string]*preparedField, properties Item, validation map[string]string, params *Params) error {
+	key := func(name string) string {
+		if pathname == "" {
+			return name
+		}
+		return pathname + "." + name
+	}
 	for name, value := range properties {
 		field := fields[name]
-		if field == nil || field.Block != nil {
+		if field == nil {
+			continue
+		}
+		if field.Block != nil {
+			if err := m.validateNested(op, key(name), field, value, validation, params); err != nil {
+				return err
+			}
 			continue
 		}
 		if field.Def.Validate != "" || field.Def.Enum != nil {
-			if err := m.validateProperty(field, value, validation, params); err != nil {
+			if err := m.validateProperty(key(name), field, value, validation, params); err != nil {
 				return err
 			}
-			properties[name] = value
 		}
 	}
 	// required check
 	for _, field := range fields {
-		if field.Required && field.Block == nil {
-			v, exists := properties[field.Name]
-			if op == "put" && (!exists || v == nil) {
-				validation[field.Name] = fmt.Sprintf(`Value not defined for required field "%s"`, field.Name)
-			} else if op == "update" && v == nil && exists {
-				validation[field.Name] = fmt.Sprintf(`Value not defined for required field "%s"`, field.Name)
+		if !field.Required {
+			continue
+		}
+		v, exists := properties[field.Name]
+		if field.Block != nil {
+			// a missing required object is created empty on put; check its leaves
+			if op == "put" && v == nil && !field.IsArray {
+				obj := Item{}
+				m.setDefaults(op, field.Block.Fields, obj, params)
+				if err := m.validateBlock(op, key(field.Name), field.Block.Fields, obj, validation, params); err != nil {
+					return err
+				}
 			}
+			continue
+		}
+		if op == "put" && (!exists || v == nil) {
+			validation[key(field.Name)] = fmt.Sprintf(`Value not defined for required field "%s"`, key(field.Name))
+		} else if op == "update" && v == nil && exists {
+			validation[key(field.Name)] = fmt.Sprintf(`Value not defined for required field "%s"`, key(field.Name))
 		}
 	}
-	if len(validation) > 0 {
-		keys := make([]string, 0, len(validation))
-		for k := range validation {
-			keys = append(keys, k)
+	return nil
+}
+
+// validateNested validates an object or the elements of an array against the
+// field's nested schema.
+func (m *Model) validateNested(op, path string, field *preparedField, value any, validation map[string]string, params *Params) error {
+	if value == nil {
+		return nil
+	}
+	if !field.IsArray {
+		obj, _ := value.(Item)
+		if obj == nil {
+			return nil
 		}
-		return NewError(fmt.Sprintf(`Validation Error in "%s" for "%s"`, m.Name, strings.Join(keys, ", ")),
-			WithCode(ErrValidation), WithContext(map[string]any{"validation": validation}))
+		return m.validateBlock(op, path, field.Block.Fields, obj, validation, params)
+	}
+	for i, elem := range nestedElements(value) {
+		obj, _ := elem.(Item)
+		if obj == nil {
+			continue
+		}
+		if err := m.validateBlock(op, fmt.Sprintf("%s[%d]", path, i), field.Block.Fields, obj, validation, params); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// nestedElements returns the elements of a nested array value, accepting
+// []Item as well as []any.
+func nestedElements(value any) []any {
+	switch v := value.(type) {
+	case []any:
+		return v
+	case []Item:
+		arr := make([]any, len(v))
+		for i, elem := range v {
+			arr[i] = elem
+		}
+		return arr
 	}
 	return nil
 }
 
-func (m *Model) validateProperty(field *preparedField, value any, details map[string]string, params *Params) error {
-	name := field.Name
+func (m *Model) validateProperty(name string, field *preparedField, value any, details map[string]string, params *Params) error {
 	if field.Def.Validate != "" {
 		pat := field.Def.Validate
 		s, _ := value.(string)
@@ -1335,7 +1963,7 @@ func (m *Model) addProjectedProperties(op string, properties Item, params *Param
 			continue
 		}
 		if t, ok := value.(time.Time); ok {
-			if m.table.isoDates {
+			if m.isoDates(nil, params) {
 				rec[name] = t.UTC().Format(time.RFC3339Nano)
 			} else {
 				rec[name] = t.UnixMilli()
@@ -1347,7 +1975,8 @@ func (m *Model) addProjectedProperties(op string, properties Item, params *Param
 }
 
 // transformProperties converts Go values to DynamoDB-compatible types before writing.
-func (m *Model) transformProperties(op string, fields map[string]*preparedField, properties Item, params *Params, rec Item) {
+// Invalid key operators in the properties of a find return an ErrArgument error.
+func (m *Model) transformProperties(op string, fields map[string]*preparedField, properties Item, params *Params, rec Item) error {
 	for name, field := range fields {
 		if field.Block != nil {
 			continue
@@ -1356,105 +1985,287 @@ func (m *Model) transformProperties(op string, fields map[string]*preparedField,
 		if !ok {
 			continue
 		}
-		rec[name] = m.transformWriteAttribute(op, field, v, properties, params)
+		value, err := m.transformWriteAttribute(op, field, v, properties, params)
+		if err != nil {
+			return err
+		}
+		rec[name] = value
 	}
+	return nil
 }
 
-func (m *Model) transformWriteAttribute(op string, field *preparedField, value any, properties Item, params *Params) any {
+func (m *Model) transformWriteAttribute(op string, field *preparedField, value any, properties Item, params *Params) (any, error) {
 	if value == nil && field.Nulls {
-		return nil
+		return nil, nil
+	}
+	if isFilterValue(value) && (op == "find" || op == "scan") {
+		return value, nil
+	}
+	if ops, ok := value.(map[string]any); ok && isKeyOperatorMap(ops) && keyOperatorTypes[field.Type] {
+		return m.transformKeyOperators(op, field, ops, properties, params)
 	}
 	switch field.Type {
 	case FieldTypeDate:
 		if value != nil {
-			return m.transformWriteDate(field, value)
+			return m.transformWriteDate(field, value, params), nil
 		}
 	case FieldTypeNumber:
 		switch v := value.(type) {
 		case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
-			return v
+			return v, nil
 		case string:
 			f, err := strconv.ParseFloat(v, 64)
 			if err != nil {
 				panic(fmt.Sprintf("invalid number value %q for field %s", v, field.Name))
 			}
-			return f
+			return f, nil
 		}
 	case FieldTypeBoolean:
 		switch v := value.(type) {
 		case bool:
-			return v
+			return v, nil
 		case string:
-			return v != "false" && v != "null" && v != "undefined" && v != ""
+			return v != "false" && v != "null" && v != "undefined" && v != "", nil
 		}
-		return value != nil
+		return value != nil, nil
 	case FieldTypeString:
 		if value != nil {
 			// operator map (e.g. {begins: "prefix"}) — pass through for key conditions
 			if _, ok := value.(map[string]any); ok {
-				return value
+				return value, nil
 			}
-			return fmt.Sprintf("%v", value)
+			value = fmt.Sprintf("%v", value)
 		}
 	case FieldTypeBuffer, FieldTypeArrayBuffer, FieldTypeBinary:
 		if b, ok := value.([]byte); ok {
-			return b
+			return b, nil
 		}
 	case FieldTypeArray:
 		if value != nil {
 			if arr, ok := value.([]any); ok {
-				return m.transformNestedWriteFields(field, arr)
+				return m.transformNestedWriteFields(field, arr, params), nil
 			}
 		}
 	case FieldTypeObject:
 		if value != nil {
 			if obj, ok := value.(map[string]any); ok {
-				return m.transformNestedWriteFieldsMap(field, obj)
+				return m.transformNestedWriteFieldsMap(field, obj, params), nil
 			}
 		}
 	case FieldTypeSet:
-		return value
+		return value, nil
 	}
+	return value, nil
+}
 
-	if field.Def.Crypt && value != nil {
-		if s, ok := value.(string); ok {
-			enc, err := m.table.encrypt(s)
-			if err == nil {
-				return enc
+// encryptProperties encrypts the Crypt fields of a collected record, after
+// the key templates have run so the primary key is known for aad.
+func (m *Model) encryptProperties(fields map[string]*preparedField, rec Item, aad []byte) {
+	for name, field := range fields {
+		switch value := rec[name].(type) {
+		case string:
+			if field.Crypt {
+				if enc, err := m.table.encrypt(value, field.Def.CryptKey, aad); err == nil {
+					rec[name] = enc
+				}
+			}
+		case Item:
+			if field.Block != nil {
+				m.encryptProperties(field.Block.Fields, value, aad)
+			}
+		case []any:
+			if field.Block != nil {
+				for _, elem := range value {
+					if obj, ok := elem.(Item); ok {
+						m.encryptProperties(field.Block.Fields, obj, aad)
+					}
+				}
 			}
 		}
 	}
-	return value
 }
 
-func (m *Model) transformNestedWriteFields(field *preparedField, arr []any) []any {
+// cryptAAD returns the associated data binding encrypted values to the item
+// holding them: the model name and primary key. rec is keyed by attribute
+// name, or by field name if byField is set. nil if TableParams.CryptBind is off.
+func (m *Model) cryptAAD(rec Item, byField bool) []byte {
+	if !m.table.cryptBind {
+		return nil
+	}
+	primary := m.indexes["primary"]
+	hash, sort := rec[primary.Hash], rec[primary.Sort]
+	if byField && !m.generic {
+		for _, field := range m.block.Fields {
+			if !field.IsPrimary {
+				continue
+			}
+			switch field.Attribute[0] {
+			case primary.Hash:
+				hash = rec[field.Name]
+			case primary.Sort:
+				sort = rec[field.Name]
+			}
+		}
+	}
+	return fmt.Appendf(nil, "%s\x00%s\x00%s", m.Name, cryptKeyText(hash), cryptKeyText(sort))
+}
+
+// cryptKeyText returns the canonical text of a key value, as DynamoDB stores
+// it, so a number written as an int and read back as a float64 binds alike.
+func cryptKeyText(v any) string {
+	av, _ := attributevalue.Marshal(v)
+	switch av := av.(type) {
+	case *types.AttributeValueMemberS:
+		return av.Value
+	case *types.AttributeValueMemberN:
+		return av.Value
+	}
+	return fmt.Sprint(v)
+}
+
+// keyOperatorTypes are the field types that may be used in key conditions.
+var keyOperatorTypes = map[FieldType]bool{
+	FieldTypeString: true, FieldTypeNumber: true, FieldTypeDate: true,
+	FieldTypeBinary: true, FieldTypeBuffer: true, FieldTypeArrayBuffer: true,
+}
+
+// isKeyOperatorMap reports whether obj is a sort-key condition such as
+// {"between": [1, 5]} rather than a plain map value.
+func isKeyOperatorMap(obj map[string]any) bool {
+	if len(obj) == 0 {
+		return false
+	}
+	for k := range obj {
+		if !KeyOperators[k] {
+			return false
+		}
+	}
+	return true
+}
+
+// transformKeyOperators transforms each operand of a key operator map with the
+// field type so number and date sort keys compare against correctly typed values.
+// Unsupported operators and operands of the wrong type return an ErrArgument
+// error.
+func (m *Model) transformKeyOperators(op string, field *preparedField, ops map[string]any, properties Item, params *Params) (map[string]any, error) {
+	result := make(map[string]any, len(ops))
+	for action, operand := range ops {
+		switch action {
+		case "begins", "begins_with":
+			if field.Type == FieldTypeNumber {
+				return nil, NewArgError(fmt.Sprintf(`Operator "%s" is not supported for number field "%s"`, action, field.Name))
+			}
+		case "between":
+			rv := reflect.ValueOf(operand)
+			if operand == nil || (rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array) || rv.Len() != 2 {
+				return nil, NewArgError(fmt.Sprintf(`Operator "between" for field "%s" requires two values`, field.Name))
+			}
+			low, err := m.transformKeyOperand(op, field, action, rv.Index(0).Interface(), properties, params)
+			if err != nil {
+				return nil, err
+			}
+			high, err := m.transformKeyOperand(op, field, action, rv.Index(1).Interface(), properties, params)
+			if err != nil {
+				return nil, err
+			}
+			result[action] = []any{low, high}
+			continue
+		}
+		value, err := m.transformKeyOperand(op, field, action, operand, properties, params)
+		if err != nil {
+			return nil, err
+		}
+		result[action] = value
+	}
+	return result, nil
+}
+
+func (m *Model) transformKeyOperand(op string, field *preparedField, action string, operand any, properties Item, params *Params) (any, error) {
+	invalid := NewArgError(fmt.Sprintf(`Invalid %T value for operator "%s" on %s field "%s"`, operand, action, field.Type, field.Name))
+	switch operand.(type) {
+	case nil, map[string]any, []any:
+		return nil, invalid
+	}
+	switch field.Type {
+	case FieldTypeNumber:
+		switch v := operand.(type) {
+		case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+			return v, nil
+		case string:
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return nil, invalid
+			}
+			return f, nil
+		}
+		return nil, invalid
+	case FieldTypeBinary, FieldTypeBuffer, FieldTypeArrayBuffer:
+		if _, ok := operand.([]byte); !ok {
+			return nil, invalid
+		}
+		return operand, nil
+	case FieldTypeDate:
+		switch operand.(type) {
+		case time.Time, string, int, int64, float64:
+		default:
+			return nil, invalid
+		}
+	}
+	return m.transformWriteAttribute(op, field, operand, properties, params)
+}
+
+func (m *Model) transformNestedWriteFields(field *preparedField, arr []any, params *Params) []any {
 	for i, v := range arr {
 		switch tv := v.(type) {
 		case time.Time:
-			arr[i] = m.transformWriteDate(field, tv)
+			arr[i] = m.transformWriteDate(field, tv, params)
 		case map[string]any:
-			arr[i] = m.transformNestedWriteFieldsMap(field, tv)
+			arr[i] = m.transformNestedWriteFieldsMap(field, tv, params)
 		}
 	}
 	return arr
 }
 
-func (m *Model) transformNestedWriteFieldsMap(field *preparedField, obj map[string]any) map[string]any {
+func (m *Model) transformNestedWriteFieldsMap(field *preparedField, obj map[string]any, params *Params) map[string]any {
 	for k, v := range obj {
 		switch tv := v.(type) {
 		case time.Time:
-			obj[k] = m.transformWriteDate(field, tv)
+			obj[k] = m.transformWriteDate(field, tv, params)
 		case map[string]any:
-			obj[k] = m.transformNestedWriteFieldsMap(field, tv)
+			obj[k] = m.transformNestedWriteFieldsMap(field, tv, params)
 		case []any:
-			obj[k] = m.transformNestedWriteFields(field, tv)
+			obj[k] = m.transformNestedWriteFields(field, tv, params)
 		}
 	}
 	return obj
 }
 
-func (m *Model) transformWriteDate(field *preparedField, value any) any {
-	isoDates := field.IsoDates
+// ExpiresIn is a date value resolved to now plus the duration when written.
+// Use it for TTL fields, either as a property value or as a schema Default:
+//
+//	"expires": {Type: "date", TTL: true, Default: onetable.ExpiresIn(24 * time.Hour)}
+type ExpiresIn time.Duration
+
+// isoDates reports whether dates are written as ISO strings: the field's own
+// isoDates setting, else Params.IsoDates, else the schema default.
+func (m *Model) isoDates(field *preparedField, params *Params) bool {
+	if field != nil && field.Def.IsoDates != nil {
+		return *field.Def.IsoDates
+	}
+	if params != nil && params.IsoDates != nil {
+		return *params.IsoDates
+	}
+	if field != nil {
+		return field.IsoDates
+	}
+	return m.schema.params.IsoDates
+}
+
+func (m *Model) transformWriteDate(field *preparedField, value any, params *Params) any {
+	if d, ok := value.(ExpiresIn); ok {
+		value = time.Now().Add(time.Duration(d))
+	}
+	isoDates := m.isoDates(field, params)
 	if field.Def.TTL {
 		switch v := value.(type) {
 		case time.Time:
@@ -1507,14 +2318,14 @@ func (m *Model) createUnique(ctx context.Context, properties Item, params *Param
 	if params.Transaction == nil {
 		params.Transaction = map[string]any{}
 	}
-	now := time.Now()
+	now := m.timestampNow(params)
 	params.Transaction["timestamp"] = now
 
-	ts := m.table.timestamps
-	if ts == true || ts == "create" {
+	ts := m.schema.params.Timestamps
+	if (ts == true || ts == "create") && !params.SkipTimestamps {
 		properties[m.createdField] = now
 	}
-	if ts == true || ts == "update" {
+	if (ts == true || ts == "update") && !params.SkipTimestamps {
 		properties[m.updatedField] = now
 	}
 
@@ -1540,7 +2351,7 @@ func (m *Model) createUnique(ctx context.Context, properties Item, params *Param
 			pk := fmt.Sprintf("_unique#%s#%s#%v", m.Name, field.Attribute[0], v)
 			sk := "_unique#"
 			up := Item{primary.Hash: pk, primary.Sort: sk}
-			_, err := m.getSchemaMgr().uniqueModel.Create(ctx, up, &Params{Transaction: params.Transaction, Exists: new(bool), Return: "NONE"})
+			_, err := m.schema.uniqueModel.Create(ctx, up, &Params{Transaction: params.Transaction, Exists: new(bool), Return: "NONE"})
 			if err != nil {
 				return nil, err
 			}
@@ -1577,6 +2388,44 @@ func (m *Model) createUnique(ctx context.Context, properties Item, params *Param
 	return items[0], nil
 }
 
+// replaceUnique puts properties in place of an existing item with the same
+// key, moving the sentinels of changed unique fields, or creates the item.
+func (m *Model) replaceUnique(ctx context.Context, properties Item, params *Params) (Item, error) {
+	if !params.SkipTimestamps {
+		now := m.timestampNow(params)
+		ts := m.schema.params.Timestamps
+		if ts == true || ts == "create" {
+			properties[m.createdField] = now
+		}
+		if ts == true || ts == "update" {
+			properties[m.updatedField] = now
+		}
+	}
+	// createUnique and replaceItem prepare the properties themselves, so only
+	// the key is derived here. Generated values are fixed first so the key
+	// matches the item written.
+	m.setDefaults("put", m.block.Fields, properties, params)
+	keyed, err := m.prepareProperties(ctx, "put", maps.Clone(properties), params)
+	if err != nil {
+		return nil, err
+	}
+	primary := m.indexes["primary"]
+	keys := Item{primary.Hash: keyed[primary.Hash]}
+	if primary.Sort != "" {
+		keys[primary.Sort] = keyed[primary.Sort]
+	}
+	prior, err := m.Get(ctx, keys, &Params{Hidden: truePtr(), Consistent: true})
+	if err != nil {
+		return nil, err
+	}
+	if prior == nil {
+		params.Exists = new(bool)
+		params.SkipTimestamps = true
+		return m.createUnique(ctx, properties, params)
+	}
+	return m.replaceItem(ctx, prior, properties, params)
+}
+
 func (m *Model) removeUnique(ctx context.Context, properties Item, params *Params) (Item, error) {
 	transactHere := params.Transaction == nil
 	if params.Transaction == nil {
@@ -1627,7 +2476,7 @@ func (m *Model) removeUnique(ctx context.Context, properties Item, params *Param
 		if prior != nil {
 			if v, ok := prior[field.Name]; ok && v != nil {
 				pk := fmt.Sprintf("_unique#%s#%s#%v", m.Name, field.Attribute[0], v)
-				_, err := m.getSchemaMgr().uniqueModel.Remove(ctx, Item{primary.Hash: pk, primary.Sort: sk},
+				_, err := m.schema.uniqueModel.Remove(ctx, Item{primary.Hash: pk, primary.Sort: sk},
 					&Params{Transaction: params.Transaction})
 				if err != nil {
 					return nil, err
@@ -1714,13 +2563,13 @@ func (m *Model) updateUnique(ctx context.Context, properties Item, params *Param
 					continue
 				}
 			}
-			m.getSchemaMgr().uniqueModel.Remove(ctx, Item{primary.Hash: priorPk, primary.Sort: sk}, //nolint:errcheck
+			m.schema.uniqueModel.Remove(ctx, Item{primary.Hash: priorPk, primary.Sort: sk}, //nolint:errcheck
 				&Params{Transaction: params.Transaction})
 		}
 		if newVal != nil && !toBeRemoved {
 			pk := fmt.Sprintf("_unique#%s#%s#%v", m.Name, field.Attribute[0], newVal)
 			up := Item{primary.Hash: pk, primary.Sort: sk}
-			m.getSchemaMgr().uniqueModel.Create(ctx, up, &Params{Transaction: params.Transaction, Exists: new(bool), Return: "NONE"}) //nolint:errcheck
+			m.schema.uniqueModel.Create(ctx, up, &Params{Transaction: params.Transaction, Exists: new(bool), Return: "NONE"}) //nolint:errcheck
 		}
 	}
 
@@ -1738,6 +2587,9 @@ func (m *Model) updateUnique(ctx context.Context, properties Item, params *Param
 		}
 		return nil, err
 	}
+	if rv, _ := returnValue("update", params.Return); rv == ReturnGet {
+		return m.Get(ctx, keys, &Params{Hidden: params.Hidden, Consistent: true})
+	}
 	return item, nil
 }
 
@@ -1829,15 +2681,35 @@ func shouldFollow(params *Params, index *IndexDef) bool {
 	return index.Follow
 }
 
-func (m *Model) followItems(ctx context.Context, op string, items []Item, params *Params) ([]Item, error) {
-	if op != "find" {
-		return items, nil
+// keyProperties maps raw primary key attributes to Get properties.
+func (m *Model) keyProperties(raw Item) Item {
+	primary := m.indexes["primary"]
+	properties := Item{}
+	for _, att := range []string{primary.Hash, primary.Sort} {
+		if att == "" {
+			continue
+		}
+		name := att
+		if field := m.keyField(att); field != nil {
+			name = field.Name
+		}
+		properties[name] = raw[att]
+	}
+	return properties
+}
+
+// followItems gets the primary-index items for the raw keys of a followed
+// query, applying params (e.g. Fields) to the gets.
+func (m *Model) followItems(ctx context.Context, keys []Item, params *Params) ([]Item, error) {
+	items := make([]Item, len(keys))
+	for i, raw := range keys {
+		items[i] = m.keyProperties(raw)
 	}
 	p2 := *params
 	p2.Follow = nil
 	p2.Index = ""
 	results := make([]Item, 0, len(items))
-	sem := make(chan struct{}, followThreads)
+	sem := make(chan struct{}, cmp.Or(params.FollowConcurrency, m.table.followConcurrency))
 	errs := make(chan error, len(items))
 	out := make([]Item, len(items))
 	for i, item := range items {
@@ -1869,6 +2741,176 @@ func (m *Model) followItems(ctx context.Context, op string, items []Item, params
 	return results, nil
 }
 
+// followBatchGet resolves a batched Get on a secondary index. BatchGetItem can
+// only read the primary index, so the item key is looked up on the GSI first and
+// a primary-key get is added to the batch instead.
+func (m *Model) followBatchGet(ctx context.Context, properties Item, params *Params) (Item, error) {
+	if !shouldFollow(params, m.selectIndex(params)) {
+		return nil, NewArgError(fmt.Sprintf(`Cannot batch get from index "%s" without follow`, params.Index))
+	}
+	p2 := *params
+	p2.Batch = nil
+	p2.Follow = new(bool)
+	p2.Hidden = truePtr() // keep the primary key attributes
+	p2.Fields = nil
+	p2.Limit = 2
+	result, err := m.Find(ctx, properties, &p2)
+	if err != nil {
+		return nil, err
+	}
+	if len(result.Items) > 1 {
+		return nil, NewError("Get without sort key returns more than one result",
+			WithCode(ErrNonUnique), WithContext(map[string]any{"properties": properties}))
+	}
+	if len(result.Items) == 0 {
+		return nil, nil
+	}
+	p3 := *params
+	p3.Follow = nil
+	p3.Index = ""
+	return m.Get(ctx, result.Items[0], &p3)
+}
+
+// ─── re-encryption ───────────────────────────────────────────────────────────
+
+// ReEncrypt scans the items of the model and rewrites encrypted fields that
+// are not in the current format, sealed with their current crypto key and
+// bound as set by TableParams.CryptBind, e.g. after a key rotation. Plain
+// values of Crypt fields are encrypted. The timestamps are not changed.
+//
+// Each update is conditional on the values read, so an item written
+// concurrently is left to that write. params applies to the scan (Where,
+// Limit, Segment, ...). Returns the number of items rewritten; items whose
+// values do not decrypt are skipped and reported in the error.
+func (m *Model) ReEncrypt(ctx context.Context, params *Params) (int, error) {
+	p := Params{}
+	if params != nil {
+		p = *params
+	}
+	p.Parse = false
+	p.RequireKeyCondition = new(bool)
+	where := fmt.Sprintf("${%s} = {%s}", m.typeField, m.Name)
+	if p.Where != "" {
+		where = fmt.Sprintf("(%s) and (%s)", p.Where, where)
+	}
+	p.Where = where
+
+	count := 0
+	var errs []error
+	for {
+		result, err := m.table.ScanItems(ctx, nil, &p)
+		if err != nil {
+			return count, err
+		}
+		for _, raw := range result.Items {
+			done, err := m.reEncryptItem(ctx, raw)
+			if err != nil {
+				errs = append(errs, err)
+			} else if done {
+				count++
+			}
+		}
+		if result.Next == nil {
+			break
+		}
+		p.Next = result.Next
+	}
+	if len(errs) > 0 {
+		return count, NewError(fmt.Sprintf(`Cannot re-encrypt %d items of "%s"`, len(errs), m.Name),
+			WithCode(ErrRuntime), WithCause(errors.Join(errs...)))
+	}
+	return count, nil
+}
+
+// reEncryptItem rewrites the top-level fields of raw holding stale encrypted
+// values. Reports whether the item was updated.
+func (m *Model) reEncryptItem(ctx context.Context, raw Item) (bool, error) {
+	aad := m.cryptAAD(raw, false)
+	var stale []*preparedField
+	for _, name := range slices.Sorted(maps.Keys(m.block.Fields)) {
+		field := m.block.Fields[name]
+		found, err := m.staleCrypt(field, attributeValue(raw, field), aad)
+		if err != nil {
+			return false, fmt.Errorf("%v: %s: %w", m.keyProperties(raw), name, err)
+		}
+		if found {
+			stale = append(stale, field)
+		}
+	}
+	if len(stale) == 0 {
+		return false, nil
+	}
+	item := m.transformReadItem("get", raw, nil, &Params{Hidden: truePtr()}, nil)
+	properties := m.keyProperties(raw)
+	conditions := make([]string, len(stale))
+	subs := map[string]any{}
+	for i, field := range stale {
+		properties[field.Name] = item[field.Name]
+		sub := fmt.Sprintf("_reencrypt%d", i)
+		subs[sub] = attributeValue(raw, field)
+		conditions[i] = fmt.Sprintf("${%s} = @{%s}", field.Name, sub)
+	}
+	_, err := m.Update(ctx, properties, &Params{
+		Where:          strings.Join(conditions, " and "),
+		Substitutions:  subs,
+		SkipTimestamps: true,
+		Partial:        new(bool),
+		Return:         ReturnNone,
+	})
+	if isConditionalFailed(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// staleCrypt reports whether the raw value of field holds an encrypted value,
+// directly or nested, that cryptCurrent rejects. It fails if such a value does
+// not decrypt, so it is not encrypted a second time.
+func (m *Model) staleCrypt(field *preparedField, value any, aad []byte) (bool, error) {
+	if field.Block != nil {
+		var elems []any
+		switch v := value.(type) {
+		case map[string]any:
+			elems = []any{v}
+		case []any:
+			elems = v
+		}
+		stale := false
+		for _, elem := range elems {
+			obj, _ := elem.(map[string]any)
+			for _, child := range field.Block.Fields {
+				found, err := m.staleCrypt(child, attributeValue(obj, child), aad)
+				if err != nil {
+					return false, err
+				}
+				stale = stale || found
+			}
+		}
+		return stale, nil
+	}
+	s, ok := value.(string)
+	if !ok || !field.Crypt || s == "" || m.table.cryptCurrent(s, field.Def.CryptKey) {
+		return false, nil
+	}
+	if _, err := m.table.decrypt(s, aad); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// attributeValue returns the value of field in the raw record rec.
+func attributeValue(rec map[string]any, field *preparedField) any {
+	value := rec[field.Attribute[0]]
+	if len(field.Attribute) > 1 {
+		obj, _ := value.(map[string]any)
+		return obj[field.Attribute[1]]
+	}
+	return value
+}
+
 // ─── helpers ─────────────────────────────────────────────────────────────────
 
 func (m *Model) checkArgs(ctx context.Context, properties Item, params *Params, overrides *Params) (Item, *Params) {
@@ -1896,12 +2938,21 @@ func (m *Model) checkArgs(ctx context.Context, properties Item, params *Params,
 		if params.Exists != nil {
 			merged.Exists = params.Exists
 		}
+		if params.OnConflict != "" {
+			merged.OnConflict = params.OnConflict
+		}
 		if params.Hidden != nil {
 			merged.Hidden = params.Hidden
 		}
 		if params.Partial != nil {
 			merged.Partial = params.Partial
 		}
+		if params.HideExpired != nil {
+			merged.HideExpired = params.HideExpired
+		}
+		if params.RequireKeyCondition != nil {
+			merged.RequireKeyCondition = params.RequireKeyCondition
+		}
 		if params.Limit > 0 {
 			merged.Limit = params.Limit
 		}
@@ -1917,6 +2968,9 @@ func (m *Model) checkArgs(ctx context.Context, properties Item, params *Params,
 		if params.MaxPages > 0 {
 			merged.MaxPages = params.MaxPages
 		}
+		if params.MaxCapacity != 0 {
+			merged.MaxCapacity = params.MaxCapacity
+		}
 		if params.Index != "" {
 			merged.Index = params.Index
 		}
@@ -1932,6 +2986,27 @@ func (m *Model) checkArgs(ctx context.Context, properties Item, params *Params,
 		if params.Where != "" {
 			merged.Where = params.Where
 		}
+		if params.Condition != "" {
+			merged.Condition = params.Condition
+		}
+		if params.ConditionLogic != "" {
+			merged.ConditionLogic = params.ConditionLogic
+		}
+		if params.ChunkUpdates {
+			merged.ChunkUpdates = params.ChunkUpdates
+		}
+		if params.FollowConcurrency > 0 {
+			merged.FollowConcurrency = params.FollowConcurrency
+		}
+		if params.SkipTimestamps {
+			merged.SkipTimestamps = params.SkipTimestamps
+		}
+		if !params.Timestamps.IsZero() {
+			merged.Timestamps = params.Timestamps
+		}
+		if params.IsoDates != nil {
+			merged.IsoDates = params.IsoDates
+		}
 		if params.Set != nil {
 			merged.Set = params.Set
 		}
@@ -1962,6 +3037,9 @@ func (m *Model) checkArgs(ctx context.Context, properties Item, params *Params,
 		if params.Capacity != "" {
 			merged.Capacity = params.Capacity
 		}
+		if params.MetricTags != nil {
+			merged.MetricTags = params.MetricTags
+		}
 		if params.Batch != nil {
 			merged.Batch = params.Batch
 		}
@@ -1983,6 +3061,9 @@ func (m *Model) checkArgs(ctx context.Context, properties Item, params *Params,
 		if params.PostFormat != nil {
 			merged.PostFormat = params.PostFormat
 		}
+		if params.PostFilter != nil {
+			merged.PostFilter = params.PostFilter
+		}
 		if params.Client != nil {
 			merged.Client = params.Client
 		}
@@ -2027,6 +3108,50 @@ func (m *Model) getHashValue(rec Item, fields map[string]*preparedField, index *
 	return nil
 }
 
+func (m *Model) getHideExpired(params *Params) bool {
+	if params != nil && params.HideExpired != nil {
+		return *params.HideExpired
+	}
+	return m.hideExpired
+}
+
+func (m *Model) getRequireKeyCondition(params *Params) bool {
+	if params != nil && params.RequireKeyCondition != nil {
+		return *params.RequireKeyCondition
+	}
+	return m.requireKey
+}
+
+// isExpired reports whether the TTL attribute of a raw item is in the past.
+// Items without a TTL field or value never expire.
+func (m *Model) isExpired(raw Item, now time.Time) bool {
+	for _, field := range m.block.Fields {
+		if !field.Def.TTL {
+			continue
+		}
+		var secs float64
+		switch v := raw[field.Attribute[0]].(type) {
+		case float64:
+			secs = v
+		case int64:
+			secs = float64(v)
+		case int:
+			secs = float64(v)
+		case string:
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return false
+			}
+			secs = f
+		default:
+			return false
+		}
+		// DynamoDB ignores zero and non-epoch-second TTL values
+		return secs > 0 && secs < float64(now.Unix())
+	}
+	return false
+}
+
 func (m *Model) getPartial(field *preparedField, params *Params) bool {
 	if params != nil && params.Partial != nil {
 		return *params.Partial
@@ -2106,9 +3231,51 @@ func toSlice(v any) ([]any, bool) {
 	return nil, false
 }
 
+// templateParams are the Params values a value template may reference via
+// ${param.name}. Names are validated when the model is prepared.
+var templateParams = map[string]func(*Params) any{
+	"index": func(p *Params) any {
+		if p.Index == "" {
+			return "primary"
+		}
+		return p.Index
+	},
+	"substitutions": func(p *Params) any { return p.Substitutions },
+}
+
+// templateValue resolves a value template variable. ${ctx.name} reads the table
+// context, ${param.name} a select Params value, anything else the properties.
+func (m *Model) templateValue(properties Item, params *Params, path string) any {
+	ns, rest, ok := strings.Cut(path, ".")
+	if ok {
+		switch ns {
+		case "ctx":
+			return getPropValue(m.table.context, rest)
+		case "param":
+			if params == nil {
+				return nil
+			}
+			name, sub, _ := strings.Cut(rest, ".")
+			get := templateParams[name]
+			if get == nil {
+				return nil
+			}
+			v := get(params)
+			if sub == "" || v == nil {
+				return v
+			}
+			if mv, ok := v.(map[string]any); ok {
+				return getPropValue(mv, sub)
+			}
+			return nil
+		}
+	}
+	return getPropValue(properties, path)
+}
+
 func getPropValue(m map[string]any, path string) any {
 	v := any(m)
-	for part := range strings.SplitSeq(path, ".") {
+	for _, part := range splitPath(path) {
 		if cur, ok := v.(map[string]any); ok {
 			v = cur[part]
 		} else {
@@ -2119,7 +3286,7 @@ func getPropValue(m map[string]any, path string) any {
 }
 
 func isConditionalFailed(err error) bool {
-	if err == nil {
+	if err == nil || IsRetryable(err) {
 		return false
 	}
 	msg := err.Error()
@@ -2150,3 +3317,31 @@ func unmarshallFromDynamo(av map[string]types.AttributeValue) (Item, error) {
 	}
 	return item, nil
 }
+
+// matchesKey reports whether the primary key attributes of key could have been
+// produced by the model's key value templates. The type field variable stands
+// for the model name; key fields without a template match any value.
+func (m *Model) matchesKey(key Item) bool {
+	typeVar := "${" + m.typeField + "}"
+	for _, att := range []string{m.hash, m.sort} {
+		if att == "" {
+			continue
+		}
+		value, ok := key[att]
+		if !ok {
+			return false
+		}
+		for _, field := range m.block.Fields {
+			if field.Attribute[0] != att || field.Def == nil || field.Def.Value == "" {
+				continue
+			}
+			tmpl := strings.ReplaceAll(field.Def.Value, templateEscape, escapeMark)
+			tmpl = strings.ReplaceAll(tmpl, typeVar, m.Name)
+			tmpl = strings.ReplaceAll(tmpl, escapeMark, templateEscape)
+			if !templatePattern(tmpl).MatchString(fmt.Sprint(value)) {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -6,6 +6,7 @@ Mirrors the JS Model.prepModel / orderFields / checkType / getIndexProperties lo
 package onetable
 
 import (
+	"fmt"
 	"regexp"
 	"strings"
 )
@@ -25,7 +26,7 @@ func (m *Model) prepModel(schemaFields FieldMap, block *fieldBlock, parent *prep
 				schemaFields[m.typeField].Required = true
 			}
 		}
-		ts := m.table.timestamps
+		ts := m.schema.params.Timestamps
 		if ts == true || ts == "create" {
 			if _, ok := schemaFields[m.createdField]; !ok {
 				schemaFields[m.createdField] = &FieldDef{Type: FieldTypeDate}
@@ -54,12 +55,16 @@ func (m *Model) prepModel(schemaFields FieldMap, block *fieldBlock, parent *prep
 			panic(err.Error())
 		}
 		def.Type = ft
+		if def.CryptKey != "" && m.table.cryptoConfigs[def.CryptKey] == nil {
+			panic(NewArgError(fmt.Sprintf(`Unknown crypto config "%s" for field "%s" in model "%s"`, def.CryptKey, name, m.Name)).Error())
+		}
 
 		pf := &preparedField{
 			Name:          name,
 			Def:           def,
 			Type:          ft,
 			Required:      def.Required,
+			Crypt:         def.Crypt || def.CryptKey != "",
 			ValueTemplate: def.Value,
 		}
 
@@ -67,14 +72,14 @@ func (m *Model) prepModel(schemaFields FieldMap, block *fieldBlock, parent *prep
 		if def.IsoDates != nil {
 			pf.IsoDates = *def.IsoDates
 		} else {
-			pf.IsoDates = m.table.isoDates
+			pf.IsoDates = m.schema.params.IsoDates
 		}
 
 		// nulls
 		if def.Nulls != nil {
 			pf.Nulls = *def.Nulls
 		} else {
-			pf.Nulls = m.table.nulls
+			pf.Nulls = m.schema.params.Nulls
 		}
 
 		// partial – keep as pointer so we can detect "not set"
@@ -180,7 +185,8 @@ func (m *Model) orderFields(block *fieldBlock, field *preparedField) {
 	if field.ValueTemplate != "" {
 		vars := getTemplateVars(field.ValueTemplate)
 		for _, path := range vars {
-			name := strings.Split(path, ".")[0]
+			m.checkTemplateVar(field, path)
+			name := splitPath(path)[0]
 			name = strings.Split(name, "[")[0]
 			if ref, ok := block.Fields[name]; ok && ref != field {
 				if ref.Block != nil {
@@ -194,10 +200,32 @@ func (m *Model) orderFields(block *fieldBlock, field *preparedField) {
 	block.Deps = append(block.Deps, field)
 }
 
+// checkTemplateVar validates ${ctx.name} and ${param.name} template references.
+func (m *Model) checkTemplateVar(field *preparedField, path string) {
+	path, _, _ = strings.Cut(path, ":")
+	ns, rest, ok := strings.Cut(path, ".")
+	if !ok || (ns != "ctx" && ns != "param") {
+		return
+	}
+	name, _, _ := strings.Cut(rest, ".")
+	if name == "" || (ns == "param" && templateParams[name] == nil) {
+		panic(NewArgError(fmt.Sprintf(`Unknown template variable "${%s}" for field "%s" in model "%s"`,
+			path, field.Name, m.Name)).Error())
+	}
+}
+
+// Value templates and where expressions write a literal "${" as "$${".
+const (
+	templateEscape = "$${"
+	escapeMark     = "\x00" // stands in for templateEscape during expansion
+)
+
+var reTemplateVar = regexp.MustCompile(`\$\{(.*?)\}`)
+
 // getTemplateVars extracts all ${varName} references from a value template.
 func getTemplateVars(tmpl string) []string {
-	re := regexp.MustCompile(`\$\{(.*?)\}`)
-	matches := re.FindAllStringSubmatch(tmpl, -1)
+	tmpl = strings.ReplaceAll(tmpl, templateEscape, escapeMark)
+	matches := reTemplateVar.FindAllStringSubmatch(tmpl, -1)
 	vars := make([]string, 0, len(matches))
 	for _, m := range matches {
 		vars = append(vars, m[1])
@@ -205,6 +233,26 @@ func getTemplateVars(tmpl string) []string {
 	return vars
 }
 
+// templatePattern returns a regexp matching the values a value template can
+// produce: literal text as given and any text for each variable.
+func templatePattern(tmpl string) *regexp.Regexp {
+	tmpl = strings.ReplaceAll(tmpl, templateEscape, escapeMark)
+	literal := func(s string) string {
+		return regexp.QuoteMeta(strings.ReplaceAll(s, escapeMark, "${"))
+	}
+	var b strings.Builder
+	b.WriteString("^")
+	last := 0
+	for _, loc := range reTemplateVar.FindAllStringIndex(tmpl, -1) {
+		b.WriteString(literal(tmpl[last:loc[0]]))
+		b.WriteString("(?s:.*)")
+		last = loc[1]
+	}
+	b.WriteString(literal(tmpl[last:]))
+	b.WriteString("$")
+	return regexp.MustCompile(b.String())
+}
+
 // getIndexProperties builds a map of attribute-name → index-name for all indexes.
 // Primary takes precedence over GSI/LSI when an attribute appears in both.
 func getIndexProperties(indexes map[string]*IndexDef) map[string]string {
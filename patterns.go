@@ -0,0 +1,167 @@
+/*
+Package onetable – access-pattern registry.
+
+Access patterns are declared in SchemaDef.Queries next to the saved queries of
+the OneTable schema format. CheckAccessPatterns verifies that every declared
+pattern can still be served by a key condition on its index, so a schema
+change that breaks a pattern fails in tests rather than in production.
+*/
+package onetable
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// AccessPattern is a named query shape: the properties a caller supplies to
+// Find a model on an index. Declare it in SchemaDef.Queries, either as an
+// AccessPattern or as a map with "model", "index" and "keys" entries.
+type AccessPattern struct {
+	Model string   `json:"model"`
+	Index string   `json:"index,omitempty"` // "" = primary
+	Keys  []string `json:"keys"`
+}
+
+// AccessPatterns returns the access patterns declared in schema.Queries.
+// Entries without keys (e.g. saved queries) are skipped.
+func AccessPatterns(schema *SchemaDef) map[string]AccessPattern {
+	patterns := map[string]AccessPattern{}
+	if schema == nil {
+		return patterns
+	}
+	for name, q := range schema.Queries {
+		switch v := q.(type) {
+		case AccessPattern:
+			patterns[name] = v
+		case *AccessPattern:
+			if v != nil {
+				patterns[name] = *v
+			}
+		case map[string]any:
+			if v["keys"] == nil {
+				continue
+			}
+			p := AccessPattern{}
+			p.Model, _ = v["model"].(string)
+			p.Index, _ = v["index"].(string)
+			switch keys := v["keys"].(type) {
+			case []string:
+				p.Keys = keys
+			case []any:
+				for _, k := range keys {
+					p.Keys = append(p.Keys, fmt.Sprint(k))
+				}
+			}
+			patterns[name] = p
+		}
+	}
+	return patterns
+}
+
+// CheckAccessPatterns verifies that every access pattern declared in
+// schema.Queries is satisfiable by a key condition with the schema's indexes
+// and value templates. It returns an ErrValidation error whose context maps
+// each broken pattern name to the reason under "patterns".
+func CheckAccessPatterns(schema *SchemaDef) error {
+	if schema == nil {
+		return NewArgError("Missing schema")
+	}
+	problems := map[string]string{}
+	for name, p := range AccessPatterns(schema) {
+		if msg := checkAccessPattern(schema, p); msg != "" {
+			problems[name] = msg
+		}
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	msgs := make([]string, 0, len(problems))
+	for _, name := range slices.Sorted(maps.Keys(problems)) {
+		msgs = append(msgs, fmt.Sprintf(`"%s": %s`, name, problems[name]))
+	}
+	return NewError("Access patterns not satisfiable: "+strings.Join(msgs, "; "),
+		WithCode(ErrValidation), WithContext(map[string]any{"patterns": problems}))
+}
+
+// checkAccessPattern returns why p cannot be served by a key condition, or "".
+func checkAccessPattern(schema *SchemaDef, p AccessPattern) string {
+	fields, ok := schema.Models[p.Model]
+	if !ok {
+		return fmt.Sprintf(`unknown model "%s"`, p.Model)
+	}
+	indexName := coalesce(p.Index, "primary")
+	idx := schema.Indexes[indexName]
+	primary := schema.Indexes["primary"]
+	if idx == nil || primary == nil {
+		return fmt.Sprintf(`unknown index "%s"`, indexName)
+	}
+	if len(p.Keys) == 0 {
+		return "no keys"
+	}
+	keys := map[string]bool{}
+	for _, key := range p.Keys {
+		keys[key] = true
+	}
+
+	hashAtt := idx.Hash
+	local := indexName != "primary" && (idx.Type == "local" || idx.Hash == "")
+	if local {
+		hashAtt = primary.Hash
+	}
+	typeField := "_type"
+	if schema.Params != nil && schema.Params.TypeField != "" {
+		typeField = schema.Params.TypeField
+	}
+	hashName, hashDef := keyField(fields, hashAtt)
+	sortName, sortDef := keyField(fields, idx.Sort)
+	if hashDef == nil || (local && sortDef == nil) {
+		return fmt.Sprintf(`model "%s" does not populate index "%s"`, p.Model, indexName)
+	}
+
+	// every hash variable must be supplied
+	used := map[string]bool{hashName: true}
+	for _, v := range patternVars(hashName, hashDef, typeField) {
+		if !keys[v] {
+			return fmt.Sprintf(`hash key of index "%s" needs "%s"`, indexName, v)
+		}
+		used[v] = true
+	}
+
+	// the remaining keys must form a prefix of the sort key variables
+	prefix := map[string]bool{}
+	if sortDef != nil {
+		used[sortName] = true
+		for _, v := range patternVars(sortName, sortDef, typeField) {
+			if !keys[v] {
+				break
+			}
+			prefix[v] = true
+		}
+	}
+	for _, key := range p.Keys {
+		if !used[key] && !prefix[key] {
+			return fmt.Sprintf(`"%s" is not part of the key of index "%s" and would need a filter`, key, indexName)
+		}
+	}
+	return ""
+}
+
+// patternVars lists the property variables of a key field in template order.
+// Variables resolved without properties (the type field, ctx.*, param.*) are
+// skipped.
+func patternVars(name string, def *FieldDef, typeField string) []string {
+	if def.Value == "" {
+		return []string{name}
+	}
+	var vars []string
+	for _, v := range getTemplateVars(def.Value) {
+		v, _, _ = strings.Cut(v, ":")
+		if v == typeField || strings.HasPrefix(v, "ctx.") || strings.HasPrefix(v, "param.") {
+			continue
+		}
+		vars = append(vars, v)
+	}
+	return vars
+}
@@ -0,0 +1,80 @@
+/*
+Package onetable – retry policy.
+
+Back-off used when DynamoDB returns unprocessed items from batch operations.
+*/
+package onetable
+
+import (
+	"context"
+	"math/rand/v2"
+	"time"
+)
+
+// RetryPolicy controls how batch operations retry unprocessed items.
+// Zero fields fall back to the values of DefaultRetryPolicy.
+type RetryPolicy struct {
+	MaxRetries int           // retry rounds after the first attempt
+	BaseDelay  time.Duration // delay before the first retry, doubled every round
+	MaxDelay   time.Duration // upper bound for a single delay
+	NoJitter   bool          // true → use the exact exponential delay
+}
+
+// DefaultRetryPolicy is used when TableParams.Retry is nil.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxRetries: 12,
+	BaseDelay:  10 * time.Millisecond,
+	MaxDelay:   5 * time.Second,
+}
+
+// resolve returns a copy of the policy with defaults applied.
+func (p *RetryPolicy) resolve() RetryPolicy {
+	r := DefaultRetryPolicy
+	if p == nil {
+		return r
+	}
+	if p.MaxRetries > 0 {
+		r.MaxRetries = p.MaxRetries
+	}
+	if p.BaseDelay > 0 {
+		r.BaseDelay = p.BaseDelay
+	}
+	if p.MaxDelay > 0 {
+		r.MaxDelay = p.MaxDelay
+	}
+	r.NoJitter = p.NoJitter
+	return r
+}
+
+// delay returns the back-off before retry number attempt (0-based).
+func (p RetryPolicy) delay(attempt int) time.Duration {
+	d := p.MaxDelay
+	if attempt < 32 {
+		if exp := p.BaseDelay << attempt; exp > 0 && exp < d {
+			d = exp
+		}
+	}
+	if !p.NoJitter && d > 0 {
+		// "equal jitter": keep at least half of the delay
+		d = d/2 + rand.N(d/2+1)
+	}
+	return d
+}
+
+// wait sleeps before retry number attempt. It returns early with the context
+// error when ctx is done, or when the ctx deadline would pass before the
+// delay elapses.
+func (p RetryPolicy) wait(ctx context.Context, attempt int) error {
+	d := p.delay(attempt)
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < d {
+		return context.DeadlineExceeded
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
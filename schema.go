@@ -59,9 +59,15 @@ type FieldDef struct {
 	TTL      bool      `json:"ttl,omitempty"`
 	Fixed    bool      `json:"fixed,omitempty"`
 	Partial  *bool     `json:"partial,omitempty"`
-	Filter   *bool     `json:"filter,omitempty"` // false disables field from filter expressions
-	Schema   FieldMap  `json:"schema,omitempty"` // nested schema
-	Items    *ItemsDef `json:"items,omitempty"`  // for array element schema
+	// CryptKey names the TableParams.Crypto config that encrypts the field
+	// instead of TableParams.CryptKey. Implies Crypt.
+	CryptKey string `json:"cryptKey,omitempty"`
+	// OmitEmpty never writes an empty object or list: it is left out on
+	// create and leaves the stored value untouched on update.
+	OmitEmpty bool      `json:"omitEmpty,omitempty"`
+	Filter    *bool     `json:"filter,omitempty"` // false disables field from filter expressions
+	Schema    FieldMap  `json:"schema,omitempty"` // nested schema
+	Items     *ItemsDef `json:"items,omitempty"`  // for array element schema
 }
 
 // ItemsDef describes the schema of array elements.
@@ -97,6 +103,8 @@ type SchemaDef struct {
 	Process map[string]any       `json:"process,omitempty"`
 	Queries map[string]any       `json:"queries,omitempty"`
 	Name    string               `json:"name,omitempty"`
+	// MetricTags maps model name → tags attached to that model's metrics.
+	MetricTags map[string]map[string]string `json:"metricTags,omitempty"`
 }
 
 // prepared field (internal, built from FieldDef during model prep)
@@ -123,6 +131,7 @@ type preparedField struct {
 	Required bool
 	Nulls    bool
 	IsoDates bool
+	Crypt    bool  // Def.Crypt or Def.CryptKey
 	Partial  *bool // nil = use table default
 
 	// value template (non-empty means computed)
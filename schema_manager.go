@@ -6,9 +6,13 @@ Mirrors JS: Schema.js – lifecycle, standard models, DynamoDB key discovery.
 package onetable
 
 import (
+	"cmp"
 	"context"
 	"errors"
 	"fmt"
+	"maps"
+	"slices"
+	"strconv"
 	"strings"
 )
 
@@ -20,9 +24,14 @@ const (
 	migrationKey       = "_migration"
 	schemaKey          = "_schema"
 	schemaFormat       = "onetable:1.1.0"
+	maxGSIs            = 20 // default DynamoDB quota per table
 )
 
-// schemaManager holds the active schema state for a Table.
+// schemaManager holds one generation of the schema state of a Table. A
+// generation is immutable once published: SetSchema, GetKeys, AddModel and
+// RemoveModel build a new generation and swap it in atomically. Models are
+// bound to the generation that created them, so a *Model obtained before a
+// reload keeps using the indexes, params and models it was built with.
 type schemaManager struct {
 	table      *Table
 	indexes    map[string]*IndexDef
@@ -30,6 +39,7 @@ type schemaManager struct {
 	definition *SchemaDef
 	params     SchemaParams
 	keyTypes   map[string]string // attrName → "string"|"number"
+	discovered map[string]string // key types read with DescribeTable
 	process    map[string]any
 
 	// internal models (not in schema.models directly)
@@ -39,17 +49,84 @@ type schemaManager struct {
 	migrationModel *Model
 }
 
-func newSchemaManager(table *Table, schema *SchemaDef) *schemaManager {
+// newSchemaManager builds a generation for schema. Schema params and the key
+// types discovered from DynamoDB carry over from the previous generation prev,
+// if any.
+func newSchemaManager(table *Table, prev *schemaManager, schema *SchemaDef) *schemaManager {
 	sm := &schemaManager{
-		table:    table,
-		models:   map[string]*Model{},
-		keyTypes: map[string]string{},
+		table:      table,
+		models:     map[string]*Model{},
+		keyTypes:   map[string]string{},
+		discovered: map[string]string{},
+		params:     defaultSchemaParams(table.params.Warn),
+	}
+	if prev != nil {
+		sm.params = prev.params
+		maps.Copy(sm.discovered, prev.discovered)
 	}
-	sm.params = table.getSchemaParams()
 	sm.setSchemaInner(schema)
 	return sm
 }
 
+func defaultSchemaParams(warn bool) SchemaParams {
+	return SchemaParams{
+		CreatedField: "created",
+		UpdatedField: "updated",
+		TypeField:    "_type",
+		Separator:    "#",
+		Timestamps:   false,
+		Warn:         warn,
+	}
+}
+
+// mergeSchemaParams overlays the fields set in p on params. IsoDates, Nulls
+// and Warn are always taken from p.
+func mergeSchemaParams(params SchemaParams, p *SchemaParams) SchemaParams {
+	if p == nil {
+		return params
+	}
+	params.CreatedField = cmp.Or(p.CreatedField, params.CreatedField)
+	params.UpdatedField = cmp.Or(p.UpdatedField, params.UpdatedField)
+	params.TypeField = cmp.Or(p.TypeField, params.TypeField)
+	params.Separator = cmp.Or(p.Separator, params.Separator)
+	params.IsoDates = p.IsoDates
+	params.Nulls = p.Nulls
+	if p.Timestamps != nil {
+		params.Timestamps = p.Timestamps
+	}
+	params.Warn = p.Warn
+	return params
+}
+
+// clone returns a copy of sm whose models are rebound to the copy.
+func (sm *schemaManager) clone() *schemaManager {
+	next := *sm
+	next.keyTypes = maps.Clone(sm.keyTypes)
+	next.discovered = maps.Clone(sm.discovered)
+	next.models = make(map[string]*Model, len(sm.models))
+	rebound := map[*Model]*Model{}
+	rebind := func(m *Model) *Model {
+		if m == nil {
+			return nil
+		}
+		if cp := rebound[m]; cp != nil {
+			return cp
+		}
+		cp := *m
+		cp.schema = &next
+		rebound[m] = &cp
+		return &cp
+	}
+	for name, m := range sm.models {
+		next.models[name] = rebind(m)
+	}
+	next.uniqueModel = rebind(sm.uniqueModel)
+	next.genericModel = rebind(sm.genericModel)
+	next.schemaModel = rebind(sm.schemaModel)
+	next.migrationModel = rebind(sm.migrationModel)
+	return &next
+}
+
 func (sm *schemaManager) setSchemaInner(schema *SchemaDef) {
 	sm.models = map[string]*Model{}
 	sm.indexes = nil
@@ -59,17 +136,14 @@ func (sm *schemaManager) setSchemaInner(schema *SchemaDef) {
 	sm.validateSchema(schema)
 	sm.definition = schema
 	sm.indexes = schema.Indexes
-
-	if schema.Params != nil {
-		sm.table.setSchemaParams(schema.Params)
-	}
-	sm.params = sm.table.getSchemaParams()
+	sm.schemaKeyTypes(schema)
+	sm.params = mergeSchemaParams(sm.params, schema.Params)
 
 	for name, modelDef := range schema.Models {
 		if name == schemaModelName || name == migrationModelName {
 			continue
 		}
-		sm.models[name] = newModel(sm.table, name, modelOptions{Fields: modelDef, Indexes: sm.indexes})
+		sm.models[name] = newModel(sm.table, name, modelOptions{Fields: modelDef, Schema: sm})
 	}
 	sm.createStandardModels()
 	sm.process = schema.Process
@@ -86,6 +160,21 @@ func (sm *schemaManager) validateSchema(schema *SchemaDef) {
 	if !ok {
 		panic("schema is missing a primary index")
 	}
+	for name := range schema.MetricTags {
+		if _, ok := schema.Models[name]; !ok {
+			panic(fmt.Sprintf(`schema metricTags reference unknown model "%s"`, name))
+		}
+	}
+	// ${ctx.x} and ${param.x} in value templates read the table context and
+	// the call params, never a property
+	for _, model := range slices.Sorted(maps.Keys(schema.Models)) {
+		for _, name := range []string{"ctx", "param"} {
+			if _, ok := schema.Models[model][name]; ok {
+				panic(fmt.Sprintf(`field "%s" of model "%s" uses the reserved template namespace "%s"; rename the field`,
+					name, model, name))
+			}
+		}
+	}
 	var lsiCount int
 	for name, idx := range schema.Indexes {
 		if name == "primary" {
@@ -110,6 +199,20 @@ func (sm *schemaManager) validateSchema(schema *SchemaDef) {
 	if lsiCount > 5 {
 		panic("schema has too many LSIs (max 5)")
 	}
+	if gsiCount := len(schema.Indexes) - 1 - lsiCount; gsiCount > maxGSIs {
+		panic(fmt.Sprintf("schema has %d GSIs, more than the default DynamoDB quota of %d per table; remove indexes or request a quota increase and overload generic gsN attributes", gsiCount, maxGSIs))
+	}
+	// two indexes over the same key attributes store the same data twice
+	seen := map[[2]string]string{}
+	for _, name := range slices.Sorted(maps.Keys(schema.Indexes)) {
+		idx := schema.Indexes[name]
+		key := [2]string{idx.Hash, idx.Sort}
+		if other, ok := seen[key]; ok {
+			panic(fmt.Sprintf(`indexes "%s" and "%s" both use hash "%s" and sort "%s"; remove one or give it different key attributes`,
+				other, name, idx.Hash, idx.Sort))
+		}
+		seen[key] = name
+	}
 }
 
 func (sm *schemaManager) createStandardModels() {
@@ -120,52 +223,127 @@ func (sm *schemaManager) createStandardModels() {
 }
 
 func (sm *schemaManager) createUniqueModel() {
-	primary := sm.indexes["primary"]
-	t := sm.keyTypes[primary.Hash]
-	if t == "" {
-		t = "string"
-	}
-	fields := FieldMap{
-		primary.Hash: {Type: FieldType(t)},
-	}
-	if primary.Sort != "" {
-		ts := sm.keyTypes[primary.Sort]
-		if ts == "" {
-			ts = "string"
-		}
-		fields[primary.Sort] = &FieldDef{Type: FieldType(ts)}
-	}
 	sm.uniqueModel = newModel(sm.table, uniqueModelName, modelOptions{
-		Fields:     fields,
+		Fields:     sm.keyFields(false),
 		Timestamps: false,
-		Indexes:    sm.indexes,
+		Schema:     sm,
 	})
 }
 
 func (sm *schemaManager) createGenericModel() {
-	primary := sm.indexes["primary"]
-	t := sm.keyTypes[primary.Hash]
-	if t == "" {
-		t = "string"
-	}
-	fields := FieldMap{
-		primary.Hash: {Type: FieldType(t)},
-	}
-	if primary.Sort != "" {
-		ts := sm.keyTypes[primary.Sort]
-		if ts == "" {
-			ts = "string"
-		}
-		fields[primary.Sort] = &FieldDef{Type: FieldType(ts)}
-	}
 	sm.genericModel = newModel(sm.table, genericModelName, modelOptions{
-		Fields:     fields,
+		Fields:     sm.keyFields(true),
 		Timestamps: false,
 		Generic:    true,
-		Indexes:    sm.indexes,
+		Schema:     sm,
 	})
 }
 
+// keyFields returns typed fields for the primary key attributes, and with
+// secondary set, for secondary index keys whose attribute type is known.
+// Primary keys of unknown type default to string.
+func (sm *schemaManager) keyFields(secondary bool) FieldMap {
+	fields := FieldMap{}
+	for name, idx := range sm.indexes {
+		if name != "primary" && !secondary {
+			continue
+		}
+		for _, att := range []string{idx.Hash, idx.Sort} {
+			if att == "" || fields[att] != nil {
+				continue
+			}
+			t := sm.keyTypes[att]
+			if t == "" {
+				if name != "primary" {
+					continue
+				}
+				t = "string"
+			}
+			fields[att] = &FieldDef{Type: FieldType(t)}
+		}
+	}
+	return fields
+}
+
+// schemaKeyTypes records the types of index key attributes declared by the
+// schema models. Attributes the schema does not declare keep the type
+// discovered from DynamoDB by an earlier GetKeys.
+func (sm *schemaManager) schemaKeyTypes(schema *SchemaDef) {
+	for _, idx := range schema.Indexes {
+		for _, att := range []string{idx.Hash, idx.Sort} {
+			if att == "" || sm.keyTypes[att] != "" {
+				continue
+			}
+			for _, fields := range schema.Models {
+				if t := keyFieldType(fields, att); t != "" {
+					sm.keyTypes[att] = t
+					break
+				}
+			}
+		}
+	}
+	for att, t := range sm.discovered {
+		if sm.keyTypes[att] == "" {
+			sm.keyTypes[att] = t
+		}
+	}
+}
+
+// keyFieldType returns the key type of the model field stored in attribute att.
+func keyFieldType(fields FieldMap, att string) string {
+	for name, def := range fields {
+		if def == nil || (def.Map != att && (def.Map != "" || name != att)) {
+			continue
+		}
+		switch FieldType(strings.ToLower(string(def.Type))) {
+		case FieldTypeNumber:
+			return "number"
+		case FieldTypeBinary, FieldTypeBuffer, FieldTypeArrayBuffer:
+			return "binary"
+		case FieldTypeString:
+			return "string"
+		}
+	}
+	return ""
+}
+
+// coerceKey converts a key value to the attribute type of att. Cursors that
+// made a round trip through JSON may carry numeric keys as strings.
+func (sm *schemaManager) coerceKey(att string, v any) any {
+	switch sv := v.(type) {
+	case string:
+		if sm.keyTypes[att] == "number" {
+			if f, err := strconv.ParseFloat(sv, 64); err == nil {
+				return f
+			}
+		}
+	case float64, int, int64:
+		if sm.keyTypes[att] == "string" {
+			return fmt.Sprintf("%v", sv)
+		}
+	}
+	return v
+}
+
+// getGenericModel returns the generic model, discovering the table keys
+// from DynamoDB first when no schema has been defined.
+func (sm *schemaManager) getGenericModel(ctx context.Context) (*Model, error) {
+	sm, err := sm.withKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return sm.genericModel, nil
+}
+
+// withKeys returns sm if it has indexes, or else the generation published by
+// discovering the table keys from DynamoDB.
+func (sm *schemaManager) withKeys(ctx context.Context) (*schemaManager, error) {
+	if sm.indexes != nil {
+		return sm, nil
+	}
+	return sm.table.getKeys(ctx, false)
+}
+
 func (sm *schemaManager) createSchemaModel() {
 	primary := sm.indexes["primary"]
 	hidden := true
@@ -188,7 +366,7 @@ func (sm *schemaManager) createSchemaModel() {
 			Hidden:   &hidden,
 		}
 	}
-	sm.schemaModel = newModel(sm.table, schemaModelName, modelOptions{Fields: fields, Indexes: sm.indexes})
+	sm.schemaModel = newModel(sm.table, schemaModelName, modelOptions{Fields: fields, Schema: sm})
 	sm.models[schemaModelName] = sm.schemaModel
 }
 
@@ -208,47 +386,42 @@ func (sm *schemaManager) createMigrationModel() {
 			Value: migrationKey + ":${version}:${date}",
 		}
 	}
-	sm.migrationModel = newModel(sm.table, migrationModelName, modelOptions{Fields: fields, Indexes: sm.indexes})
+	sm.migrationModel = newModel(sm.table, migrationModelName, modelOptions{Fields: fields, Schema: sm})
 	sm.models[migrationModelName] = sm.migrationModel
 }
 
-// SetSchema replaces the active schema. When schema is nil the current schema
-// is cleared and index keys are re-discovered from DynamoDB (mirrors JS behavior).
-func (sm *schemaManager) SetSchema(ctx context.Context, schema *SchemaDef) (map[string]*IndexDef, error) {
-	if schema != nil {
-		sm.setSchemaInner(schema)
-		return sm.indexes, nil
-	}
-	// nil → clear schema, then auto-discover indexes from DDB
-	sm.setSchemaInner(nil)
-	return sm.GetKeys(ctx, true)
-}
-
-// GetKeys reads the DynamoDB table description to discover index keys when no
-// schema was provided.
-func (sm *schemaManager) GetKeys(ctx context.Context, refresh bool) (map[string]*IndexDef, error) {
-	if sm.indexes != nil && !refresh {
-		return sm.indexes, nil
-	}
+// discoverKeys returns a new generation with the index keys read from the
+// DynamoDB table description.
+func (sm *schemaManager) discoverKeys(ctx context.Context) (*schemaManager, error) {
 	info, err := sm.table.DescribeTable(ctx)
 	if err != nil {
 		return nil, err
 	}
-	tbl := info["Table"].(map[string]any)
+	tbl, ok := info["Table"].(map[string]any)
+	if !ok {
+		return nil, NewError(fmt.Sprintf(`Cannot discover keys for table "%s"`, sm.table.Name), WithCode(ErrMissing))
+	}
 
-	for _, def := range tbl["AttributeDefinitions"].([]any) {
+	sm = sm.clone()
+	defs, _ := tbl["AttributeDefinitions"].([]any)
+	for _, def := range defs {
 		d := def.(map[string]any)
 		name := d["AttributeName"].(string)
 		at := d["AttributeType"].(string)
-		if at == "N" {
+		switch at {
+		case "N":
 			sm.keyTypes[name] = "number"
-		} else {
+		case "B":
+			sm.keyTypes[name] = "binary"
+		default:
 			sm.keyTypes[name] = "string"
 		}
+		sm.discovered[name] = sm.keyTypes[name]
 	}
 
 	indexes := map[string]*IndexDef{"primary": {}}
-	for _, ks := range tbl["KeySchema"].([]any) {
+	keySchema, _ := tbl["KeySchema"].([]any)
+	for _, ks := range keySchema {
 		k := ks.(map[string]any)
 		if strings.ToLower(k["KeyType"].(string)) == "hash" {
 			indexes["primary"].Hash = k["AttributeName"].(string)
@@ -274,12 +447,14 @@ func (sm *schemaManager) GetKeys(ctx context.Context, refresh bool) (map[string]
 	}
 	sm.indexes = indexes
 	sm.createStandardModels()
-	return indexes, nil
+	return sm, nil
 }
 
-// AddModel adds a model to the schema at runtime.
-func (sm *schemaManager) AddModel(name string, fields FieldMap) {
-	sm.models[name] = newModel(sm.table, name, modelOptions{Fields: fields})
+// withModel returns a new generation with the model name added or replaced.
+func (sm *schemaManager) withModel(name string, fields FieldMap) *schemaManager {
+	sm = sm.clone()
+	sm.models[name] = newModel(sm.table, name, modelOptions{Fields: fields, Schema: sm})
+	return sm
 }
 
 // ListModels returns all model names.
@@ -312,13 +487,14 @@ func (sm *schemaManager) GetModel(name string, nothrow bool) (*Model, error) {
 	return m, nil
 }
 
-// RemoveModel deletes a model from the registry.
-func (sm *schemaManager) RemoveModel(name string) error {
+// withoutModel returns a new generation without the model name.
+func (sm *schemaManager) withoutModel(name string) (*schemaManager, error) {
 	if _, ok := sm.models[name]; !ok {
-		return fmt.Errorf("cannot find model %s", name)
+		return nil, fmt.Errorf("cannot find model %s", name)
 	}
+	sm = sm.clone()
 	delete(sm.models, name)
-	return nil
+	return sm, nil
 }
 
 // GetCurrentSchema returns the current schema definition with resolved params.
@@ -335,10 +511,9 @@ func (sm *schemaManager) GetCurrentSchema() *SchemaDef {
 
 // SaveSchema persists the schema to the DynamoDB table.
 func (sm *schemaManager) SaveSchema(ctx context.Context, schema *SchemaDef) error {
-	if sm.indexes == nil {
-		if _, err := sm.GetKeys(ctx, false); err != nil {
-			return err
-		}
+	sm, err := sm.withKeys(ctx)
+	if err != nil {
+		return err
 	}
 	if schema == nil {
 		schema = sm.GetCurrentSchema()
@@ -360,7 +535,7 @@ func (sm *schemaManager) SaveSchema(ctx context.Context, schema *SchemaDef) erro
 		schema.Queries = map[string]any{}
 	}
 
-	_, err := sm.schemaModel.Create(ctx, Item{
+	_, err = sm.schemaModel.Create(ctx, Item{
 		"name":    schema.Name,
 		"version": schema.Version,
 		"format":  schema.Format,
@@ -375,10 +550,9 @@ func (sm *schemaManager) SaveSchema(ctx context.Context, schema *SchemaDef) erro
 
 // ReadSchema reads the current schema from the table.
 func (sm *schemaManager) ReadSchema(ctx context.Context) (*SchemaDef, error) {
-	if sm.indexes == nil {
-		if _, err := sm.GetKeys(ctx, false); err != nil {
-			return nil, err
-		}
+	sm, err := sm.withKeys(ctx)
+	if err != nil {
+		return nil, err
 	}
 	primary := sm.indexes["primary"]
 	props := Item{primary.Hash: schemaKey}
@@ -397,10 +571,9 @@ func (sm *schemaManager) ReadSchema(ctx context.Context) (*SchemaDef, error) {
 
 // ReadSchemas returns all schema items previously stored in the table (all versions).
 func (sm *schemaManager) ReadSchemas(ctx context.Context) ([]*SchemaDef, error) {
-	if sm.indexes == nil {
-		if _, err := sm.GetKeys(ctx, false); err != nil {
-			return nil, err
-		}
+	sm, err := sm.withKeys(ctx)
+	if err != nil {
+		return nil, err
 	}
 	primary := sm.indexes["primary"]
 	props := Item{primary.Hash: schemaKey}
@@ -418,15 +591,14 @@ func (sm *schemaManager) ReadSchemas(ctx context.Context) ([]*SchemaDef, error)
 // RemoveSchema deletes a previously saved schema item from the table.
 // schema must contain a Name field that matches the saved schema's name.
 func (sm *schemaManager) RemoveSchema(ctx context.Context, schema *SchemaDef) error {
-	if sm.indexes == nil {
-		if _, err := sm.GetKeys(ctx, false); err != nil {
-			return err
-		}
+	sm, err := sm.withKeys(ctx)
+	if err != nil {
+		return err
 	}
 	if schema == nil || schema.Name == "" {
 		return errors.New("schema must have a Name to remove")
 	}
-	_, err := sm.schemaModel.Remove(ctx, Item{"name": schema.Name}, nil)
+	_, err = sm.schemaModel.Remove(ctx, Item{"name": schema.Name}, nil)
 	return err
 }
 
@@ -444,3 +616,19 @@ func itemToSchemaDef(item Item) *SchemaDef {
 	}
 	return s
 }
+
+// keyModel returns the name of the only schema model whose primary key value
+// templates match key, or "" when no model or several models match.
+func (sm *schemaManager) keyModel(key Item) string {
+	found := ""
+	for _, name := range slices.Sorted(maps.Keys(sm.models)) {
+		if !sm.models[name].matchesKey(key) {
+			continue
+		}
+		if found != "" {
+			return ""
+		}
+		found = name
+	}
+	return found
+}
@@ -6,6 +6,7 @@ Mirrors JS: Table.js – top-level DynamoDB table wrapper.
 package onetable
 
 import (
+	"cmp"
 	"context"
 	"crypto/aes"
 	"crypto/cipher"
@@ -19,6 +20,8 @@ import (
 	"maps"
 	"slices"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -59,8 +62,8 @@ type DynamoClient interface {
 
 // CryptoConfig configures field-level encryption.
 type CryptoConfig struct {
-	Password string // plaintext password → hashed to AES-256 key
-	Cipher   string // e.g. "aes-256-gcm"
+	Password string // plaintext password → hashed to the AES key
+	Cipher   string // "aes-256-gcm" (default), "aes-192-gcm" or "aes-128-gcm"
 }
 
 // TableParams configures a Table.
@@ -77,10 +80,44 @@ type TableParams struct {
 	Context Item // table-level context (injected into every write)
 	Metrics MetricsCollector
 	Monitor MonitorFunc
+	Retry   *RetryPolicy // nil → DefaultRetryPolicy
+	// CryptKey names the Crypto config that encrypts Crypt fields without a
+	// FieldDef.CryptKey. "" → "primary". Values are always decrypted with the
+	// config named in the ciphertext, so rotating to a new key only needs the
+	// new config added here; the old one is kept until all values are rewritten.
+	CryptKey string
+	// CryptBind binds encrypted values to their item: the model name and
+	// primary key are authenticated as GCM associated data, so a value copied
+	// to another item fails to decrypt. Values written without it still decrypt.
+	CryptBind bool
+	// CryptStrict, with CryptBind, rejects encrypted values that are not bound
+	// to their item, so an unbound value moved to another item fails to decrypt
+	// too. Enable it once ReEncrypt has bound the existing values.
+	CryptStrict bool
+	// DecodeTag is the struct tag read by Decode/GetAs in addition to `dynamodbav`.
+	// "" → "onetable".
+	DecodeTag string
+	// HideExpired drops items whose TTL field is in the past from get/find/scan
+	// results, as DynamoDB may take a while to delete them.
+	HideExpired bool
+	// RequireKeyCondition makes Find fail unless it has a key condition and
+	// refuses Scan unless Params.RequireKeyCondition is explicitly false.
+	RequireKeyCondition bool
 	// Transform is called for every read/write to allow custom field transformations.
 	Transform TransformFunc
 	// Value is called when a field has value: true to compute a custom value.
 	Value ValueFunc
+	// TestHooks intercepts commands and results, for assertions in tests.
+	TestHooks *TestHooks
+	// MaxQueryPages caps the pages a find or scan reads when Params.MaxPages
+	// is not set. 0 → 1000.
+	MaxQueryPages int
+	// FollowConcurrency is the number of Gets run concurrently to follow
+	// index items to the primary index. 0 → 10.
+	FollowConcurrency int
+	// OnWarning receives the schema warnings enabled by Warn. nil → warnings
+	// are logged at info level.
+	OnWarning WarningFunc
 }
 
 // MetricsCollector is called after every DynamoDB operation.
@@ -92,6 +129,34 @@ type MetricsCollector interface {
 // MonitorFunc is an optional hook called after each DynamoDB operation.
 type MonitorFunc func(model, op string, result Item, params *Params, start time.Time) error
 
+// WarnMissingRequired is the Warning code for a full-item read that lacks a
+// required field.
+const WarnMissingRequired = "MissingRequired"
+
+// Warning describes a schema mismatch that does not fail the operation.
+type Warning struct {
+	Code    string
+	Model   string
+	Field   string
+	Op      string
+	Message string
+}
+
+// WarningFunc receives warnings when the schema's Warn param is set.
+type WarningFunc func(w Warning)
+
+// TestHooks lets tests observe what the table sends and receives without
+// parsing log output. Either function may be nil. They are called
+// synchronously on the calling goroutine.
+type TestHooks struct {
+	// Command receives the final command map of each executed operation, with
+	// values still marshalled as DynamoDB AttributeValues.
+	Command func(model, op string, cmd Item)
+	// Result receives the normalized result of each successful operation
+	// (e.g. "Item", "Items", "Attributes", "Count").
+	Result func(model, op string, result Item)
+}
+
 // TransformFunc is called for read/write to allow field-level transformations.
 type TransformFunc func(model *Model, op, name string, value any, properties Item) any
 
@@ -106,37 +171,66 @@ type Table struct {
 	log    Logger
 	params *TableParams
 
-	// schema-derived settings (set via setSchemaParams)
-	typeField    string
-	createdField string
-	updatedField string
-	separator    string
-	isoDates     bool
-	nulls        bool
-	timestamps   any // bool | "create" | "update"
-	warn         bool
-
-	hidden  bool
-	partial bool
+	hidden      bool
+	partial     bool
+	hideExpired bool
+	requireKey  bool
 
 	// crypto
 	cryptoConfigs map[string]*cryptoEntry
+	cryptKey      string
+	cryptBind     bool
+	cryptStrict   bool
 
 	// table-level context applied to every write
 	context Item
 
-	// schema manager
-	schemaMgr *schemaManager
+	// current schema generation; schemaMu serializes writers
+	schema   atomic.Pointer[schemaManager]
+	schemaMu sync.Mutex
 
 	// optional metrics / monitoring
-	metrics MetricsCollector
-	monitor MonitorFunc
+	metrics      MetricsCollector
+	monitor      MonitorFunc
+	hookFailures atomic.Int64
+	onWarning    WarningFunc
+
+	// back-off for unprocessed batch items
+	retry RetryPolicy
+
+	// struct tag for Decode / GetAs
+	decodeTag string
+
+	// pagination and follow limits
+	maxQueryPages     int
+	followConcurrency int
+
+	testHooks *TestHooks
 }
 
 type cryptoEntry struct {
 	name   string
 	cipher string
-	key    []byte // sha256 of password
+	key    []byte // sha256 of password, truncated to the cipher key size
+}
+
+// Crypt payload format bytes. A value is stored as "name:base64(payload)",
+// where the payload is the format byte, the nonce and the sealed text with its
+// GCM tag. The format byte is authenticated along with any item binding.
+const (
+	cryptFormatV1    byte = 0x01
+	cryptFormatBound byte = 0x80 // flag: sealed with item associated data
+
+	// cryptLegacyBound marks bound values of the legacy
+	// "name:mode:hex-nonce:base64" format.
+	cryptLegacyBound = "item"
+)
+
+// cipherKeySizes maps the supported ciphers to their AES key size.
+var cipherKeySizes = map[string]int{
+	"aes-256-gcm": 32,
+	"aes-192-gcm": 24,
+	"aes-128-gcm": 16,
 }
 
 // NewTable creates and initializes a Table instance.
@@ -144,24 +238,30 @@ func NewTable(params TableParams) (*Table, error) {
 	if params.Name == "" {
 		return nil, NewArgError("Missing \"name\" property")
 	}
+	if params.MaxQueryPages < 0 || params.FollowConcurrency < 0 {
+		return nil, NewArgError("MaxQueryPages and FollowConcurrency must not be negative")
+	}
 
 	t := &Table{
-		Name:         params.Name,
-		params:       &params,
-		context:      Item{},
-		hidden:       params.Hidden,
-		partial:      params.Partial,
-		warn:         params.Warn,
-		typeField:    "_type",
-		createdField: "created",
-		updatedField: "updated",
-		separator:    "#",
-		isoDates:     false,
-		nulls:        false,
-		timestamps:   false,
-		metrics:      params.Metrics,
-		monitor:      params.Monitor,
-	}
+		Name:        params.Name,
+		params:      &params,
+		context:     Item{},
+		hidden:      params.Hidden,
+		hideExpired: params.HideExpired,
+		requireKey:  params.RequireKeyCondition,
+		partial:     params.Partial,
+		metrics:     params.Metrics,
+		monitor:     params.Monitor,
+		retry:       params.Retry.resolve(),
+		decodeTag:   params.DecodeTag,
+		testHooks:   params.TestHooks,
+		onWarning:   params.OnWarning,
+	}
+	if t.decodeTag == "" {
+		t.decodeTag = defaultDecodeTag
+	}
+	t.maxQueryPages = cmp.Or(params.MaxQueryPages, sanityPages)
+	t.followConcurrency = cmp.Or(params.FollowConcurrency, followThreads)
 
 	// logging
 	switch {
@@ -179,56 +279,54 @@ func NewTable(params TableParams) (*Table, error) {
 	}
 
 	// crypto
-	if params.Crypto != nil {
+	t.cryptKey = cmp.Or(params.CryptKey, "primary")
+	t.cryptBind = params.CryptBind
+	t.cryptStrict = params.CryptBind && params.CryptStrict
+	if params.Crypto != nil || params.CryptKey != "" {
 		if err := t.initCrypto(params.Crypto); err != nil {
 			return nil, err
 		}
 	}
 
 	// schema manager (may be nil schema)
-	t.schemaMgr = newSchemaManager(t, params.Schema)
+	t.schema.Store(newSchemaManager(t, nil, params.Schema))
 
 	logTrace(t.log, "Loading OneTable", nil)
 	return t, nil
 }
 
-// ─── Schema params ────────────────────────────────────────────────────────────
+// ─── Schema generations ───────────────────────────────────────────────────────
 
-func (t *Table) setSchemaParams(p *SchemaParams) {
-	if p == nil {
-		return
-	}
-	if p.CreatedField != "" {
-		t.createdField = p.CreatedField
-	}
-	if p.UpdatedField != "" {
-		t.updatedField = p.UpdatedField
-	}
-	if p.TypeField != "" {
-		t.typeField = p.TypeField
-	}
-	if p.Separator != "" {
-		t.separator = p.Separator
-	}
-	t.isoDates = p.IsoDates
-	t.nulls = p.Nulls
-	if p.Timestamps != nil {
-		t.timestamps = p.Timestamps
+// schemaMgr returns the current schema generation.
+func (t *Table) schemaMgr() *schemaManager {
+	return t.schema.Load()
+}
+
+// updateSchema publishes the generation built by fn from the current one.
+// Writers are serialized; readers keep the generation they loaded.
+func (t *Table) updateSchema(fn func(cur *schemaManager) (*schemaManager, error)) (*schemaManager, error) {
+	t.schemaMu.Lock()
+	defer t.schemaMu.Unlock()
+	next, err := fn(t.schemaMgr())
+	if err != nil {
+		return nil, err
 	}
-	t.warn = p.Warn
+	t.schema.Store(next)
+	return next, nil
 }
 
-func (t *Table) getSchemaParams() SchemaParams {
-	return SchemaParams{
-		CreatedField: t.createdField,
-		UpdatedField: t.updatedField,
-		TypeField:    t.typeField,
-		Separator:    t.separator,
-		IsoDates:     t.isoDates,
-		Nulls:        t.nulls,
-		Timestamps:   t.timestamps,
-		Warn:         t.warn,
+// getKeys returns the generation with the table index keys, discovering them
+// from DynamoDB when the schema defines none or refresh is set.
+func (t *Table) getKeys(ctx context.Context, refresh bool) (*schemaManager, error) {
+	if sm := t.schemaMgr(); sm.indexes != nil && !refresh {
+		return sm, nil
 	}
+	return t.updateSchema(func(cur *schemaManager) (*schemaManager, error) {
+		if cur.indexes != nil && !refresh {
+			return cur, nil
+		}
+		return cur.discoverKeys(ctx)
+	})
 }
 
 // ─── Public schema API ────────────────────────────────────────────────────────
@@ -236,38 +334,62 @@ func (t *Table) getSchemaParams() SchemaParams {
 // SetSchema replaces the active schema. When schema is nil the current schema
 // is cleared and the table index definitions are re-discovered from DynamoDB
 // (equivalent to calling GetKeys). Returns the resolved index map.
+//
+// The new schema is swapped in atomically once fully built. Models returned
+// by GetModel before the call keep working against the schema they came from,
+// so in-flight requests complete unchanged; call GetModel again to use the
+// new schema.
 func (t *Table) SetSchema(ctx context.Context, schema *SchemaDef) (map[string]*IndexDef, error) {
-	return t.schemaMgr.SetSchema(ctx, schema)
+	sm, err := t.updateSchema(func(cur *schemaManager) (*schemaManager, error) {
+		next := newSchemaManager(t, cur, schema)
+		if schema == nil {
+			return next.discoverKeys(ctx)
+		}
+		return next, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return sm.indexes, nil
 }
 
 // GetCurrentSchema returns the active schema definition.
 func (t *Table) GetCurrentSchema() *SchemaDef {
-	return t.schemaMgr.GetCurrentSchema()
+	return t.schemaMgr().GetCurrentSchema()
 }
 
 // GetKeys returns index definitions discovered from DynamoDB.
 func (t *Table) GetKeys(ctx context.Context) (map[string]*IndexDef, error) {
-	return t.schemaMgr.GetKeys(ctx, false)
+	sm, err := t.getKeys(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	return sm.indexes, nil
 }
 
 // GetModel returns a registered model by name.
 func (t *Table) GetModel(name string) (*Model, error) {
-	return t.schemaMgr.GetModel(name, false)
+	return t.schemaMgr().GetModel(name, false)
 }
 
 // AddModel registers a new model definition.
 func (t *Table) AddModel(name string, fields FieldMap) {
-	t.schemaMgr.AddModel(name, fields)
+	t.updateSchema(func(cur *schemaManager) (*schemaManager, error) { //nolint:errcheck
+		return cur.withModel(name, fields), nil
+	})
 }
 
 // RemoveModel deletes a model definition.
 func (t *Table) RemoveModel(name string) error {
-	return t.schemaMgr.RemoveModel(name)
+	_, err := t.updateSchema(func(cur *schemaManager) (*schemaManager, error) {
+		return cur.withoutModel(name)
+	})
+	return err
 }
 
 // ListModels returns registered model names.
 func (t *Table) ListModels() []string {
-	return t.schemaMgr.ListModels()
+	return t.schemaMgr().ListModels()
 }
 
 // SetClient replaces the DynamoDB client used by the table after construction.
@@ -275,6 +397,11 @@ func (t *Table) SetClient(client DynamoClient) {
 	t.client = client
 }
 
+// SetTestHooks replaces the TestHooks after construction; nil removes them.
+func (t *Table) SetTestHooks(hooks *TestHooks) {
+	t.testHooks = hooks
+}
+
 // GetLog returns the Logger currently in use by the table.
 func (t *Table) GetLog() Logger {
 	return t.log
@@ -288,24 +415,24 @@ func (t *Table) SetLog(logger Logger) {
 // SaveSchema persists the current (or supplied) schema to the DynamoDB table.
 // If schema is nil the current in-memory schema is saved.
 func (t *Table) SaveSchema(ctx context.Context, schema *SchemaDef) error {
-	return t.schemaMgr.SaveSchema(ctx, schema)
+	return t.schemaMgr().SaveSchema(ctx, schema)
 }
 
 // ReadSchema reads the "Current" schema item previously stored by SaveSchema.
 // Returns nil if no schema has been saved.
 func (t *Table) ReadSchema(ctx context.Context) (*SchemaDef, error) {
-	return t.schemaMgr.ReadSchema(ctx)
+	return t.schemaMgr().ReadSchema(ctx)
 }
 
 // ReadSchemas returns all schema items stored in the table (all versions).
 func (t *Table) ReadSchemas(ctx context.Context) ([]*SchemaDef, error) {
-	return t.schemaMgr.ReadSchemas(ctx)
+	return t.schemaMgr().ReadSchemas(ctx)
 }
 
 // RemoveSchema deletes a previously saved schema item from the table.
 // The schema argument must contain at least a Name field.
 func (t *Table) RemoveSchema(ctx context.Context, schema *SchemaDef) error {
-	return t.schemaMgr.RemoveSchema(ctx, schema)
+	return t.schemaMgr().RemoveSchema(ctx, schema)
 }
 
 // ─── Context ──────────────────────────────────────────────────────────────────
@@ -364,6 +491,15 @@ func (t *Table) Get(ctx context.Context, modelName string, properties Item, para
 	return m.Get(ctx, properties, params)
 }
 
+// Check adds a condition check on a model item to Params.Transaction.
+func (t *Table) Check(ctx context.Context, modelName string, properties Item, params *Params) (Item, error) {
+	m, err := t.GetModel(modelName)
+	if err != nil {
+		return nil, err
+	}
+	return m.Check(ctx, properties, params)
+}
+
 // Remove deletes a model item.
 func (t *Table) Remove(ctx context.Context, modelName string, properties Item, params *Params) (Item, error) {
 	m, err := t.GetModel(modelName)
@@ -404,41 +540,103 @@ func (t *Table) Upsert(ctx context.Context, modelName string, properties Item, p
 
 // GetItem reads a raw item (generic model).
 func (t *Table) GetItem(ctx context.Context, properties Item, params *Params) (Item, error) {
-	return t.schemaMgr.genericModel.getItem(ctx, properties, params)
+	m, err := t.schemaMgr().getGenericModel(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return m.getItem(ctx, properties, params)
 }
 
 // PutItem writes a raw item (generic model).
 func (t *Table) PutItem(ctx context.Context, properties Item, params *Params) (Item, error) {
-	return t.schemaMgr.genericModel.putItem(ctx, properties, params)
+	m, err := t.schemaMgr().getGenericModel(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return m.putItem(ctx, properties, params)
 }
 
 // DeleteItem deletes a raw item (generic model).
 func (t *Table) DeleteItem(ctx context.Context, properties Item, params *Params) (Item, error) {
-	return t.schemaMgr.genericModel.deleteItem(ctx, properties, params)
+	m, err := t.schemaMgr().getGenericModel(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return m.deleteItem(ctx, properties, params)
 }
 
 // QueryItems queries raw items (generic model).
 func (t *Table) QueryItems(ctx context.Context, properties Item, params *Params) (*Result, error) {
-	return t.schemaMgr.genericModel.queryItems(ctx, properties, params)
+	m, err := t.schemaMgr().getGenericModel(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return m.queryItems(ctx, properties, params)
+}
+
+// QueryIndex queries an index by its key attribute values without naming the
+// attributes: hashValue is matched against the index hash attribute and
+// sortCondition, if not nil, against its sort attribute, either as a value or
+// as an operator map such as {"begins": "order#"} or {"between": []any{a, b}}.
+// indexName "" is the primary index. Items are returned as raw attributes
+// unless params.Parse is set.
+func (t *Table) QueryIndex(ctx context.Context, indexName string, hashValue any, sortCondition any, params *Params) (*Result, error) {
+	m, err := t.schemaMgr().getGenericModel(ctx)
+	if err != nil {
+		return nil, err
+	}
+	indexName = coalesce(indexName, "primary")
+	idx := m.schema.indexes[indexName]
+	if idx == nil {
+		return nil, NewArgError(fmt.Sprintf(`Unknown index "%s"`, indexName))
+	}
+	if hashValue == nil {
+		return nil, NewArgError(fmt.Sprintf(`Missing hash value for index "%s"`, indexName))
+	}
+	properties := Item{idx.Hash: hashValue}
+	if sortCondition != nil {
+		if idx.Sort == "" {
+			return nil, NewArgError(fmt.Sprintf(`Index "%s" has no sort key`, indexName))
+		}
+		properties[idx.Sort] = sortCondition
+	}
+	p := Params{}
+	if params != nil {
+		p = *params
+	}
+	p.Index = indexName
+	return m.queryItems(ctx, properties, &p)
 }
 
 // ScanItems scans raw items (generic model).
 func (t *Table) ScanItems(ctx context.Context, properties Item, params *Params) (*Result, error) {
-	return t.schemaMgr.genericModel.scanItems(ctx, properties, params)
+	m, err := t.schemaMgr().getGenericModel(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return m.scanItems(ctx, properties, params)
 }
 
 // UpdateItem updates a raw item (generic model).
 func (t *Table) UpdateItem(ctx context.Context, properties Item, params *Params) (Item, error) {
-	return t.schemaMgr.genericModel.updateItem(ctx, properties, params)
+	m, err := t.schemaMgr().getGenericModel(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return m.updateItem(ctx, properties, params)
 }
 
 // ─── Batch operations ─────────────────────────────────────────────────────────
 
-// BatchGet executes a BatchGetItem request.
+// BatchGet executes a BatchGetItem request. Unprocessed keys are retried
+// according to the table RetryPolicy, unless Params.Batch is set: the keys are
+// then left in batch for the caller to retry. Either way unprocessed keys
+// return the items read so far with an *UnprocessedError.
 func (t *Table) BatchGet(ctx context.Context, batch map[string]any, params *Params) (any, error) {
 	if len(batch) == 0 {
 		return []Item{}, nil
 	}
+	sm := t.schemaMgr()
 	if params == nil {
 		params = &Params{}
 	}
@@ -448,7 +646,7 @@ func (t *Table) BatchGet(ctx context.Context, batch map[string]any, params *Para
 
 	if params.Fields != nil {
 		// build projection expression
-		expr, err := newExpression(t.schemaMgr.genericModel, "batchGet", Item{}, params)
+		expr, err := newExpression(sm.genericModel, "batchGet", Item{}, params)
 		if err == nil {
 			cmd, _ := expr.command()
 			if pe, ok := cmd["ProjectionExpression"]; ok {
@@ -470,9 +668,12 @@ func (t *Table) BatchGet(ctx context.Context, batch map[string]any, params *Para
 		result = map[string]any{"Responses": map[string]any{}}
 	}
 
+	if ctx == nil {
+		ctx = context.Background()
+	}
 	retries := 0
 	for {
-		data, err := t.execute(ctx, genericModelName, "batchGet", batch, Item{}, params)
+		data, err := t.execute(ctx, t.schemaMgr(), genericModelName, "batchGet", batch, Item{}, params)
 		if err != nil {
 			return nil, err
 		}
@@ -483,11 +684,11 @@ func (t *Table) BatchGet(ctx context.Context, batch map[string]any, params *Para
 						itemMap, _ := rawItem.(map[string]any)
 						if params.Parse {
 							item := t.unmarshallItem(itemMap)
-							typeName, _ := item[t.typeField].(string)
+							typeName, _ := item[sm.params.TypeField].(string)
 							if typeName == "" {
 								typeName = "_unknown"
 							}
-							if m := t.schemaMgr.models[typeName]; m != nil && m != t.schemaMgr.uniqueModel {
+							if m := sm.models[typeName]; m != nil && m != sm.uniqueModel {
 								result = append(result.([]Item), m.transformReadItem("get", item, Item{}, params, nil))
 							}
 						} else {
@@ -498,16 +699,24 @@ func (t *Table) BatchGet(ctx context.Context, batch map[string]any, params *Para
 					}
 				}
 			}
-			if unprocessed, ok := data["UnprocessedItems"].(map[string]any); ok && len(unprocessed) > 0 {
-				batch["RequestItems"] = unprocessed
+			if unprocessed, ok := data["UnprocessedItems"].(map[string]types.KeysAndAttributes); ok && len(unprocessed) > 0 {
+				batch["RequestItems"] = keysToBatch(unprocessed)
 				if params.Batch != nil {
-					return nil, nil
+					// the caller retries with the remaining keys left in batch
+					return result, newUnprocessedError("Unprocessed keys left in the batch", nil,
+						t.parseUnprocessedKeys(unprocessed))
+				}
+				if retries >= t.retry.MaxRetries {
+					return result, newUnprocessedError("Too many unprocessed items after retries", nil,
+						t.parseUnprocessedKeys(unprocessed))
 				}
-				if retries > 11 {
-					return nil, errors.New("too many unprocessed items after retries")
+				if err := t.retry.wait(ctx, retries); err != nil {
+					return result, newUnprocessedError("Batch get retry aborted", err, t.parseUnprocessedKeys(unprocessed))
 				}
-				time.Sleep(time.Duration(10*(1<<retries)) * time.Millisecond)
 				retries++
+				if params.Stats != nil {
+					params.Stats.Retries++
+				}
 				continue
 			}
 		}
@@ -516,7 +725,9 @@ func (t *Table) BatchGet(ctx context.Context, batch map[string]any, params *Para
 	return result, nil
 }
 
-// BatchWrite executes a BatchWriteItem request.
+// BatchWrite executes a BatchWriteItem request. Unprocessed items are retried
+// according to the table RetryPolicy. When the retries are exhausted, the
+// returned error is an *UnprocessedError holding the remaining requests.
 func (t *Table) BatchWrite(ctx context.Context, batch map[string]any, params *Params) (bool, error) {
 	if len(batch) == 0 {
 		return true, nil
@@ -524,20 +735,29 @@ func (t *Table) BatchWrite(ctx context.Context, batch map[string]any, params *Pa
 	if params == nil {
 		params = &Params{}
 	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
 	retries := 0
 	for {
-		data, err := t.execute(ctx, genericModelName, "batchWrite", batch, Item{}, params)
+		data, err := t.execute(ctx, t.schemaMgr(), genericModelName, "batchWrite", batch, Item{}, params)
 		if err != nil {
 			return false, err
 		}
 		if data != nil {
-			if unprocessed, ok := data["UnprocessedItems"].(map[string]any); ok && len(unprocessed) > 0 {
-				batch["RequestItems"] = unprocessed
-				if retries > 11 {
-					return false, errors.New("too many unprocessed items after retries")
+			if unprocessed, ok := data["UnprocessedItems"].(map[string][]types.WriteRequest); ok && len(unprocessed) > 0 {
+				batch["RequestItems"] = writeRequestsToBatch(unprocessed)
+				if retries >= t.retry.MaxRetries {
+					return false, newUnprocessedError("Too many unprocessed items after retries", nil,
+						t.parseUnprocessed(unprocessed))
+				}
+				if err := t.retry.wait(ctx, retries); err != nil {
+					return false, newUnprocessedError("Batch write retry aborted", err, t.parseUnprocessed(unprocessed))
 				}
-				time.Sleep(time.Duration(10*(1<<retries)) * time.Millisecond)
 				retries++
+				if params.Stats != nil {
+					params.Stats.Retries++
+				}
 				continue
 			}
 		}
@@ -546,10 +766,153 @@ func (t *Table) BatchWrite(ctx context.Context, batch map[string]any, params *Pa
 	return true, nil
 }
 
+// UnprocessedRequest describes a batch request that DynamoDB did not process.
+type UnprocessedRequest struct {
+	Model string // from the type field, else the model whose key templates match ("" when unknown)
+	Op    string // "put", "delete" or "get"
+	Key   Item   // primary key attributes
+	Item  Item   // full item for put requests (nil otherwise)
+}
+
+// UnprocessedError is returned by BatchWrite and BatchGet when DynamoDB left
+// requests unprocessed after the retries, or when BatchGet is called with
+// Params.Batch and does not retry. It unwraps to a OneTableError with code
+// ErrRuntime.
+type UnprocessedError struct {
+	*OneTableError
+	Requests []UnprocessedRequest
+}
+
+func (e *UnprocessedError) Unwrap() error { return e.OneTableError }
+
+func newUnprocessedError(msg string, cause error, requests []UnprocessedRequest) *UnprocessedError {
+	return &UnprocessedError{OneTableError: NewError(msg, WithCode(ErrRuntime), WithCause(cause)), Requests: requests}
+}
+
+// GetUnprocessed returns the requests of an *UnprocessedError in the chain of
+// err, or nil. Only puts carry the type field, so the Model of deletes and
+// gets is resolved from the key value templates and is "" when no model or
+// several models match the key.
+func GetUnprocessed(err error) []UnprocessedRequest {
+	var ue *UnprocessedError
+	if !errors.As(err, &ue) {
+		return nil
+	}
+	return ue.Requests
+}
+
+// parseUnprocessed converts SDK write requests into UnprocessedRequest values.
+func (t *Table) parseUnprocessed(unprocessed map[string][]types.WriteRequest) []UnprocessedRequest {
+	sm := t.schemaMgr()
+	var primary *IndexDef
+	if sm.indexes != nil {
+		primary = sm.indexes["primary"]
+	}
+	var list []UnprocessedRequest
+	for _, reqs := range unprocessed {
+		for _, wr := range reqs {
+			var req UnprocessedRequest
+			switch {
+			case wr.PutRequest != nil:
+				item, err := unmarshallFromDynamo(wr.PutRequest.Item)
+				if err != nil {
+					continue
+				}
+				req.Op = "put"
+				req.Item = item
+				req.Key = Item{}
+				if primary != nil {
+					req.Key[primary.Hash] = item[primary.Hash]
+					if primary.Sort != "" {
+						req.Key[primary.Sort] = item[primary.Sort]
+					}
+				}
+				req.Model, _ = item[sm.params.TypeField].(string)
+				if req.Model == "" {
+					req.Model = sm.keyModel(req.Key)
+				}
+			case wr.DeleteRequest != nil:
+				key, err := unmarshallFromDynamo(wr.DeleteRequest.Key)
+				if err != nil {
+					continue
+				}
+				req.Op = "delete"
+				req.Key = key
+				req.Model = sm.keyModel(key)
+			default:
+				continue
+			}
+			list = append(list, req)
+		}
+	}
+	return list
+}
+
+// parseUnprocessedKeys converts the unprocessed keys of a BatchGetItem into
+// UnprocessedRequest values.
+func (t *Table) parseUnprocessedKeys(unprocessed map[string]types.KeysAndAttributes) []UnprocessedRequest {
+	sm := t.schemaMgr()
+	var list []UnprocessedRequest
+	for _, ka := range unprocessed {
+		for _, k := range ka.Keys {
+			key, err := unmarshallFromDynamo(k)
+			if err != nil {
+				continue
+			}
+			list = append(list, UnprocessedRequest{Model: sm.keyModel(key), Op: "get", Key: key})
+		}
+	}
+	return list
+}
+
+// keysToBatch converts SDK keys-and-attributes back into the generic
+// RequestItems shape understood by buildBatchGetInput.
+func keysToBatch(unprocessed map[string]types.KeysAndAttributes) map[string]any {
+	ritems := map[string]any{}
+	for tbl, ka := range unprocessed {
+		keys := make([]any, 0, len(ka.Keys))
+		for _, k := range ka.Keys {
+			keys = append(keys, k)
+		}
+		def := map[string]any{"Keys": keys}
+		if ka.ConsistentRead != nil {
+			def["ConsistentRead"] = *ka.ConsistentRead
+		}
+		if ka.ProjectionExpression != nil {
+			def["ProjectionExpression"] = *ka.ProjectionExpression
+		}
+		if ka.ExpressionAttributeNames != nil {
+			def["ExpressionAttributeNames"] = ka.ExpressionAttributeNames
+		}
+		ritems[tbl] = def
+	}
+	return ritems
+}
+
+// writeRequestsToBatch converts SDK write requests back into the generic
+// RequestItems shape understood by buildBatchWriteInput.
+func writeRequestsToBatch(unprocessed map[string][]types.WriteRequest) map[string]any {
+	ritems := map[string]any{}
+	for tbl, reqs := range unprocessed {
+		list := make([]any, 0, len(reqs))
+		for _, wr := range reqs {
+			switch {
+			case wr.PutRequest != nil:
+				list = append(list, map[string]any{"PutRequest": Item{"Item": wr.PutRequest.Item}})
+			case wr.DeleteRequest != nil:
+				list = append(list, map[string]any{"DeleteRequest": Item{"Key": wr.DeleteRequest.Key}})
+			}
+		}
+		ritems[tbl] = list
+	}
+	return ritems
+}
+
 // ─── Transact ─────────────────────────────────────────────────────────────────
 
 // Transact executes a transaction (write/get).
 func (t *Table) Transact(ctx context.Context, op string, transaction map[string]any, params *Params) (any, error) {
+	sm := t.schemaMgr()
 	if params == nil {
 		params = &Params{}
 	}
@@ -564,7 +927,7 @@ func (t *Table) Transact(ctx context.Context, op string, transaction map[string]
 		dynOp = "transactGet"
 	}
 
-	result, err := t.execute(ctx, genericModelName, dynOp, transaction, Item{}, params)
+	result, err := t.execute(ctx, t.schemaMgr(), genericModelName, dynOp, transaction, Item{}, params)
 	if err != nil {
 		return nil, err
 	}
@@ -576,11 +939,11 @@ func (t *Table) Transact(ctx context.Context, op string, transaction map[string]
 				if rm, ok := r.(map[string]any); ok {
 					if rawItem, ok := rm["Item"].(map[string]any); ok {
 						item := t.unmarshallItem(rawItem)
-						typeName, _ := item[t.typeField].(string)
+						typeName, _ := item[sm.params.TypeField].(string)
 						if typeName == "" {
 							typeName = "_unknown"
 						}
-						if m := t.schemaMgr.models[typeName]; m != nil && m != t.schemaMgr.uniqueModel {
+						if m := sm.models[typeName]; m != nil && m != sm.uniqueModel {
 							items = append(items, m.transformReadItem("get", item, Item{}, params, nil))
 						}
 					}
@@ -596,16 +959,17 @@ func (t *Table) Transact(ctx context.Context, op string, transaction map[string]
 
 // GroupByType groups items by type field.
 func (t *Table) GroupByType(items []Item, params *Params) map[string][]Item {
+	sm := t.schemaMgr()
 	if params == nil {
 		params = &Params{}
 	}
 	result := map[string][]Item{}
 	for _, item := range items {
-		typeName, _ := item[t.typeField].(string)
+		typeName, _ := item[sm.params.TypeField].(string)
 		if typeName == "" {
 			typeName = "_unknown"
 		}
-		m := t.schemaMgr.models[typeName]
+		m := sm.models[typeName]
 		var prepared Item
 		if params.Hidden != nil && !*params.Hidden && m != nil {
 			prepared = Item{}
@@ -622,6 +986,32 @@ func (t *Table) GroupByType(items []Item, params *Params) map[string][]Item {
 	return result
 }
 
+// ScanAll scans the whole table and returns the items grouped by model type.
+// Each item is parsed by its own model (field mapping, dates, hidden fields);
+// items of unknown type are returned raw under their type name or "_unknown".
+// Schema and migration records are omitted.
+func (t *Table) ScanAll(ctx context.Context, params *Params) (map[string][]Item, error) {
+	p := Params{}
+	if params != nil {
+		p = *params
+	}
+	p.Parse = true
+	// keep the type field for grouping; hidden fields are dropped afterwards
+	p.Hidden = truePtr()
+	result, err := t.ScanItems(ctx, nil, &p)
+	if err != nil {
+		return nil, err
+	}
+	hidden := new(bool)
+	if params != nil && params.Hidden != nil {
+		hidden = params.Hidden
+	}
+	groups := t.GroupByType(result.Items, &Params{Hidden: hidden})
+	delete(groups, schemaModelName)
+	delete(groups, migrationModelName)
+	return groups, nil
+}
+
 // ─── Fetch ────────────────────────────────────────────────────────────────────
 
 // Fetch retrieves an item-collection of different model types that share the
@@ -638,6 +1028,7 @@ func (t *Table) Fetch(ctx context.Context, models []string, properties Item, par
 	if len(models) == 0 {
 		return map[string][]Item{}, nil
 	}
+	sm := t.schemaMgr()
 	if params == nil {
 		params = &Params{}
 	}
@@ -645,7 +1036,7 @@ func (t *Table) Fetch(ctx context.Context, models []string, properties Item, par
 	// build a where clause that matches any of the requested model types
 	where := make([]string, 0, len(models))
 	for _, name := range models {
-		where = append(where, fmt.Sprintf("${%s} = {%s}", t.typeField, name))
+		where = append(where, fmt.Sprintf("${%s} = {%s}", sm.params.TypeField, name))
 	}
 	combined := strings.Join(where, " or ")
 	if params.Where != "" {
@@ -659,13 +1050,211 @@ func (t *Table) Fetch(ctx context.Context, models []string, properties Item, par
 	hidden := true
 	p.Hidden = &hidden
 
-	result, err := t.schemaMgr.genericModel.queryItems(ctx, properties, &p)
+	m, err := sm.getGenericModel(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result, err := m.queryItems(ctx, properties, &p)
 	if err != nil {
 		return nil, err
 	}
 	return t.GroupByType(result.Items, params), nil
 }
 
+// ─── Execute ──────────────────────────────────────────────────────────────────
+
+// executeOp returns the operation name for an SDK input accepted by Execute.
+func executeOp(input any) string {
+	switch in := input.(type) {
+	case *ddb.GetItemInput:
+		if in != nil {
+			return "get"
+		}
+	case *ddb.PutItemInput:
+		if in != nil {
+			return "put"
+		}
+	case *ddb.DeleteItemInput:
+		if in != nil {
+			return "delete"
+		}
+	case *ddb.UpdateItemInput:
+		if in != nil {
+			return "update"
+		}
+	case *ddb.QueryInput:
+		if in != nil {
+			return "find"
+		}
+	case *ddb.ScanInput:
+		if in != nil {
+			return "scan"
+		}
+	}
+	return ""
+}
+
+// executeCommand converts an Execute input to the command map built by the
+// model API, for TestHooks.Command and the snapshot of a failure.
+func executeCommand(input any) Item {
+	cmd := Item{}
+	str := func(name string, v *string) {
+		if v != nil {
+			cmd[name] = *v
+		}
+	}
+	attrs := func(name string, v map[string]types.AttributeValue) {
+		if v != nil {
+			cmd[name] = v
+		}
+	}
+	expr := func(names map[string]string, values map[string]types.AttributeValue) {
+		if names != nil {
+			cmd["ExpressionAttributeNames"] = names
+		}
+		attrs("ExpressionAttributeValues", values)
+	}
+	switch in := input.(type) {
+	case *ddb.GetItemInput:
+		str("TableName", in.TableName)
+		attrs("Key", in.Key)
+		str("ProjectionExpression", in.ProjectionExpression)
+		expr(in.ExpressionAttributeNames, nil)
+	case *ddb.PutItemInput:
+		str("TableName", in.TableName)
+		attrs("Item", in.Item)
+		str("ConditionExpression", in.ConditionExpression)
+		expr(in.ExpressionAttributeNames, in.ExpressionAttributeValues)
+	case *ddb.DeleteItemInput:
+		str("TableName", in.TableName)
+		attrs("Key", in.Key)
+		str("ConditionExpression", in.ConditionExpression)
+		expr(in.ExpressionAttributeNames, in.ExpressionAttributeValues)
+	case *ddb.UpdateItemInput:
+		str("TableName", in.TableName)
+		attrs("Key", in.Key)
+		str("UpdateExpression", in.UpdateExpression)
+		str("ConditionExpression", in.ConditionExpression)
+		expr(in.ExpressionAttributeNames, in.ExpressionAttributeValues)
+	case *ddb.QueryInput:
+		str("TableName", in.TableName)
+		str("IndexName", in.IndexName)
+		str("KeyConditionExpression", in.KeyConditionExpression)
+		str("FilterExpression", in.FilterExpression)
+		str("ProjectionExpression", in.ProjectionExpression)
+		expr(in.ExpressionAttributeNames, in.ExpressionAttributeValues)
+		attrs("ExclusiveStartKey", in.ExclusiveStartKey)
+	case *ddb.ScanInput:
+		str("TableName", in.TableName)
+		str("IndexName", in.IndexName)
+		str("FilterExpression", in.FilterExpression)
+		str("ProjectionExpression", in.ProjectionExpression)
+		expr(in.ExpressionAttributeNames, in.ExpressionAttributeValues)
+		attrs("ExclusiveStartKey", in.ExclusiveStartKey)
+	}
+	return cmd
+}
+
+// Execute sends a pre-built SDK input ("get" *GetItemInput, "put"
+// *PutItemInput, "delete" *DeleteItemInput, "update" *UpdateItemInput, "find"
+// *QueryInput or "scan" *ScanInput) unchanged and parses the returned items
+// like the model API: each item is read by the model named in its type field,
+// items of unknown type are returned as unmarshalled. Result.Next holds the
+// LastEvaluatedKey of a query or scan.
+func (t *Table) Execute(ctx context.Context, op string, input any, params *Params) (*Result, error) {
+	sm := t.schemaMgr()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if params == nil {
+		params = &Params{}
+	}
+	if expected := executeOp(input); expected == "" {
+		return nil, NewArgError(fmt.Sprintf("Unsupported Execute input %T", input))
+	} else if op != expected {
+		return nil, NewArgError(fmt.Sprintf(`Execute op "%s" does not match %T, expected "%s"`, op, input, expected))
+	}
+	client := t.client
+	if params.Client != nil {
+		client = params.Client
+	}
+	if client == nil {
+		return nil, NewArgError("Table has no DynamoDB client configured")
+	}
+	start := time.Now()
+	cmd := executeCommand(input)
+	logInfo(t.log, fmt.Sprintf(`OneTable "%s" "%s"`, op, genericModelName), map[string]any{"cmd": input, "op": op})
+	if t.testHooks != nil && t.testHooks.Command != nil {
+		t.testHooks.Command(genericModelName, op, cmd)
+	}
+
+	var raw []map[string]types.AttributeValue
+	var lastKey map[string]types.AttributeValue
+	var count int
+	var execErr error
+	switch in := input.(type) {
+	case *ddb.GetItemInput:
+		out, err := client.GetItem(ctx, in)
+		if execErr = err; err == nil && out.Item != nil {
+			raw = append(raw, out.Item)
+		}
+	case *ddb.PutItemInput:
+		out, err := client.PutItem(ctx, in)
+		if execErr = err; err == nil && out.Attributes != nil {
+			raw = append(raw, out.Attributes)
+		}
+	case *ddb.DeleteItemInput:
+		out, err := client.DeleteItem(ctx, in)
+		if execErr = err; err == nil && out.Attributes != nil {
+			raw = append(raw, out.Attributes)
+		}
+	case *ddb.UpdateItemInput:
+		out, err := client.UpdateItem(ctx, in)
+		if execErr = err; err == nil && out.Attributes != nil {
+			raw = append(raw, out.Attributes)
+		}
+	case *ddb.QueryInput:
+		out, err := client.Query(ctx, in)
+		if execErr = err; err == nil {
+			raw, lastKey, count = out.Items, out.LastEvaluatedKey, int(out.Count)
+		}
+	case *ddb.ScanInput:
+		out, err := client.Scan(ctx, in)
+		if execErr = err; err == nil {
+			raw, lastKey, count = out.Items, out.LastEvaluatedKey, int(out.Count)
+		}
+	}
+
+	items, err := unmarshalListOfMaps(raw)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := t.executeResult(t.schemaMgr(), genericModelName, op, cmd, Item{"Items": items, "Count": count}, execErr, params, start); err != nil {
+		return nil, err
+	}
+
+	result := &Result{Items: []Item{}, Count: count, table: t}
+	if lastKey != nil {
+		if result.Next, err = unmarshallFromDynamo(lastKey); err != nil {
+			return nil, err
+		}
+	}
+	now := time.Now()
+	for _, item := range items {
+		typeName, _ := item[sm.params.TypeField].(string)
+		m := sm.models[typeName]
+		switch {
+		case m == nil:
+			result.Items = append(result.Items, item)
+		case m == sm.uniqueModel:
+		case m.getHideExpired(params) && op != "put" && m.isExpired(item, now):
+		default:
+			result.Items = append(result.Items, m.transformReadItem(op, item, Item{}, params, nil))
+		}
+	}
+	return result, nil
+}
+
 // ─── DDL ──────────────────────────────────────────────────────────────────────
 
 const confirmRemoveTable = "DeleteTableForever"
@@ -760,76 +1349,66 @@ func (t *Table) GetTableDefinition(provisioned *types.ProvisionedThroughput) *Ta
 	}
 
 	attributes := map[string]bool{}
-	indexes := t.schemaMgr.indexes
+	indexes := t.schemaMgr().indexes
 	if indexes == nil {
 		panic("cannot create table without schema indexes")
 	}
 
-	for name, idx := range indexes {
-		var keys []types.KeySchemaElement
+	addAttribute := func(att string) {
+		if att != "" && !attributes[att] {
+			def.AttributeDefinitions = append(def.AttributeDefinitions,
+				types.AttributeDefinition{AttributeName: aws.String(att), AttributeType: t.scalarAttributeType(att)})
+			attributes[att] = true
+		}
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(indexes)) {
+		idx := indexes[name]
+		keys := []types.KeySchemaElement{{AttributeName: aws.String(idx.Hash), KeyType: types.KeyTypeHash}}
+		if idx.Sort != "" {
+			keys = append(keys, types.KeySchemaElement{AttributeName: aws.String(idx.Sort), KeyType: types.KeyTypeRange})
+		}
+		addAttribute(idx.Hash)
+		addAttribute(idx.Sort)
+
 		if name == "primary" {
-			def.KeySchema = keys[:0]
-			keys = def.KeySchema
-		} else {
-			projType := types.ProjectionTypeAll
-			var nonKeyAttrs []string
-			switch p := idx.Project.(type) {
-			case []string:
-				projType = types.ProjectionTypeInclude
-				nonKeyAttrs = p
-			case string:
-				if p == "keys" {
-					projType = types.ProjectionTypeKeysOnly
-				}
-			}
+			def.KeySchema = keys
+			continue
+		}
 
-			proj := types.Projection{ProjectionType: projType}
-			if len(nonKeyAttrs) > 0 {
-				proj.NonKeyAttributes = nonKeyAttrs
-			}
-			gsi := types.GlobalSecondaryIndex{
-				IndexName:  aws.String(name),
-				Projection: &proj,
-			}
-			if provisioned != nil {
-				gsi.ProvisionedThroughput = provisioned
+		projType := types.ProjectionTypeAll
+		var nonKeyAttrs []string
+		switch p := idx.Project.(type) {
+		case []string:
+			projType = types.ProjectionTypeInclude
+			nonKeyAttrs = p
+		case string:
+			if p == "keys" {
+				projType = types.ProjectionTypeKeysOnly
 			}
-			def.GlobalSecondaryIndexes = append(def.GlobalSecondaryIndexes, gsi)
-			// keys slice points into the GSI
-			keys = gsi.KeySchema
+		}
+		proj := types.Projection{ProjectionType: projType}
+		if len(nonKeyAttrs) > 0 {
+			proj.NonKeyAttributes = nonKeyAttrs
 		}
 
-		if idx.Hash != "" {
-			keys = append(keys, types.KeySchemaElement{
-				AttributeName: aws.String(idx.Hash),
-				KeyType:       types.KeyTypeHash,
+		if idx.Type == "local" {
+			def.LocalSecondaryIndexes = append(def.LocalSecondaryIndexes, types.LocalSecondaryIndex{
+				IndexName:  aws.String(name),
+				KeySchema:  keys,
+				Projection: &proj,
 			})
-			if !attributes[idx.Hash] {
-				at := types.ScalarAttributeTypeS
-				if t.getAttributeType(idx.Hash) == "number" {
-					at = types.ScalarAttributeTypeN
-				}
-				def.AttributeDefinitions = append(def.AttributeDefinitions,
-					types.AttributeDefinition{AttributeName: aws.String(idx.Hash), AttributeType: at})
-				attributes[idx.Hash] = true
-			}
+			continue
 		}
-		if idx.Sort != "" {
-			keys = append(keys, types.KeySchemaElement{
-				AttributeName: aws.String(idx.Sort),
-				KeyType:       types.KeyTypeRange,
-			})
-			_ = keys
-			if !attributes[idx.Sort] {
-				at := types.ScalarAttributeTypeS
-				if t.getAttributeType(idx.Sort) == "number" {
-					at = types.ScalarAttributeTypeN
-				}
-				def.AttributeDefinitions = append(def.AttributeDefinitions,
-					types.AttributeDefinition{AttributeName: aws.String(idx.Sort), AttributeType: at})
-				attributes[idx.Sort] = true
-			}
+		gsi := types.GlobalSecondaryIndex{
+			IndexName:  aws.String(name),
+			KeySchema:  keys,
+			Projection: &proj,
+		}
+		if provisioned != nil {
+			gsi.ProvisionedThroughput = provisioned
 		}
+		def.GlobalSecondaryIndexes = append(def.GlobalSecondaryIndexes, gsi)
 	}
 	return def
 }
@@ -873,7 +1452,7 @@ func (t *Table) UpdateTable(ctx context.Context, params *UpdateTableParams) erro
 	if params == nil {
 		return nil
 	}
-	indexes := t.schemaMgr.indexes
+	indexes := t.schemaMgr().indexes
 	if indexes == nil {
 		return NewArgError("Cannot update table without schema indexes")
 	}
@@ -924,14 +1503,14 @@ func (t *Table) UpdateTable(ctx context.Context, params *UpdateTableParams) erro
 			{AttributeName: aws.String(c.Hash), KeyType: types.KeyTypeHash},
 		}
 		attrDefs := []types.AttributeDefinition{
-			{AttributeName: aws.String(c.Hash), AttributeType: types.ScalarAttributeTypeS},
+			{AttributeName: aws.String(c.Hash), AttributeType: t.scalarAttributeType(c.Hash)},
 		}
 		if c.Sort != "" {
 			keySchema = append(keySchema, types.KeySchemaElement{
 				AttributeName: aws.String(c.Sort), KeyType: types.KeyTypeRange,
 			})
 			attrDefs = append(attrDefs, types.AttributeDefinition{
-				AttributeName: aws.String(c.Sort), AttributeType: types.ScalarAttributeTypeS,
+				AttributeName: aws.String(c.Sort), AttributeType: t.scalarAttributeType(c.Sort),
 			})
 		}
 		gsi := types.CreateGlobalSecondaryIndexAction{
@@ -971,19 +1550,31 @@ func (t *Table) UpdateTable(ctx context.Context, params *UpdateTableParams) erro
 	return err
 }
 
+// getAttributeType returns the key type ("string"|"number"|"binary") of an
+// index attribute, as declared by the schema models or discovered by GetKeys.
 func (t *Table) getAttributeType(name string) string {
-	for _, m := range t.schemaMgr.models {
-		if f, ok := m.block.Fields[name]; ok {
-			return string(f.Type)
-		}
+	if kt := t.schemaMgr().keyTypes[name]; kt != "" {
+		return kt
 	}
 	return "string"
 }
 
+// scalarAttributeType maps an index attribute to its DynamoDB key type.
+func (t *Table) scalarAttributeType(name string) types.ScalarAttributeType {
+	switch t.getAttributeType(name) {
+	case "number":
+		return types.ScalarAttributeTypeN
+	case "binary":
+		return types.ScalarAttributeTypeB
+	}
+	return types.ScalarAttributeTypeS
+}
+
 // ─── execute ──────────────────────────────────────────────────────────────────
 
 // execute dispatches a DynamoDB operation and returns a normalised result Item.
-func (t *Table) execute(ctx context.Context, modelName, op string, cmd Item, properties Item, params *Params) (Item, error) {
+// sm is the schema generation of the calling model.
+func (t *Table) execute(ctx context.Context, sm *schemaManager, modelName, op string, cmd Item, properties Item, params *Params) (Item, error) {
 	if ctx == nil {
 		ctx = context.Background()
 	}
@@ -998,6 +1589,9 @@ func (t *Table) execute(ctx context.Context, modelName, op string, cmd Item, pro
 	}
 
 	logInfo(t.log, fmt.Sprintf(`OneTable "%s" "%s"`, op, modelName), map[string]any{"cmd": cmd, "op": op})
+	if t.testHooks != nil && t.testHooks.Command != nil {
+		t.testHooks.Command(modelName, op, cmd)
+	}
 
 	var result Item
 	var execErr error
@@ -1101,6 +1695,9 @@ func (t *Table) execute(ctx context.Context, modelName, op string, cmd Item, pro
 			"Items": items,
 			"Count": int(out.Count),
 		}
+		if out.ConsumedCapacity != nil {
+			result["ConsumedCapacity"] = consumedCapacity(out.ConsumedCapacity)
+		}
 		if out.LastEvaluatedKey != nil {
 			lek, err := unmarshallFromDynamo(out.LastEvaluatedKey)
 			if err == nil {
@@ -1127,6 +1724,9 @@ func (t *Table) execute(ctx context.Context, modelName, op string, cmd Item, pro
 			"Count":        int(out.Count),
 			"ScannedCount": int(out.ScannedCount),
 		}
+		if out.ConsumedCapacity != nil {
+			result["ConsumedCapacity"] = consumedCapacity(out.ConsumedCapacity)
+		}
 		if out.LastEvaluatedKey != nil {
 			lek, err := unmarshallFromDynamo(out.LastEvaluatedKey)
 			if err == nil {
@@ -1209,101 +1809,317 @@ func (t *Table) execute(ctx context.Context, modelName, op string, cmd Item, pro
 		return nil, NewArgError("Unknown operation: " + op)
 	}
 
+	return t.executeResult(sm, modelName, op, cmd, result, execErr, params, start)
+}
+
+// executeResult maps a client error to a OneTableError, with a snapshot of cmd
+// under Context["command"], and reports a successful operation to the metrics
+// and monitor hooks.
+func (t *Table) executeResult(sm *schemaManager, modelName, op string, cmd, result Item, execErr error, params *Params, start time.Time) (Item, error) {
 	if execErr != nil {
 		errMsg := execErr.Error()
+		withCommand := WithContext(map[string]any{"command": commandSnapshot(op, cmd)})
 		if strings.Contains(errMsg, "ConditionalCheckFailedException") && op == "put" {
 			return nil, NewError(fmt.Sprintf(`Conditional create failed for "%s"`, modelName),
-				WithCode(ErrRuntime), WithCause(execErr))
+				WithCode(ErrRuntime), WithCause(execErr), withCommand)
 		}
+		// transient failures get their own code, see IsRetryable
+		code := cmp.Or(classifyError(execErr), ErrRuntime)
 		if strings.Contains(errMsg, "ProvisionedThroughputExceededException") {
-			return nil, NewError("Provisioning Throughput Exception", WithCode(ErrRuntime), WithCause(execErr))
+			return nil, NewError("Provisioning Throughput Exception", WithCode(code), WithCause(execErr), withCommand)
 		}
 		if strings.Contains(errMsg, "TransactionCanceledException") {
-			return nil, NewError("Transaction Canceled", WithCode(ErrRuntime), WithCause(execErr))
+			return nil, NewError("Transaction Canceled", WithCode(code), WithCause(execErr), withCommand)
 		}
 		return nil, NewError(fmt.Sprintf(`OneTable execute failed "%s" for "%s": %s`, op, modelName, errMsg),
-			WithCode(ErrRuntime), WithCause(execErr))
+			WithCode(code), WithCause(execErr), withCommand)
 	}
 
 	// metrics / monitoring
+	if t.metrics != nil || t.monitor != nil {
+		params = metricParams(sm, modelName, params)
+	}
 	if t.metrics != nil {
-		t.metrics.Add(modelName, op, result, params, start) //nolint:errcheck
+		t.runHook("metrics", modelName, op, func() error {
+			return t.metrics.Add(modelName, op, result, params, start)
+		})
 	}
 	if t.monitor != nil {
-		t.monitor(modelName, op, result, params, start) //nolint:errcheck
+		t.runHook("monitor", modelName, op, func() error {
+			return t.monitor(modelName, op, result, params, start)
+		})
+	}
+	if t.testHooks != nil && t.testHooks.Result != nil {
+		t.testHooks.Result(modelName, op, result)
 	}
 
 	return result, nil
 }
 
+// commandSnapshot returns the parts of cmd needed to reproduce a failure
+// without exposing data: table, index, key, expression strings and names.
+// Expression values are reduced to their DynamoDB type and written items to
+// their attribute names.
+func commandSnapshot(op string, cmd Item) map[string]any {
+	snap := map[string]any{"op": op}
+	for _, name := range []string{"TableName", "IndexName", "KeyConditionExpression", "ConditionExpression",
+		"FilterExpression", "UpdateExpression", "ProjectionExpression", "ExpressionAttributeNames"} {
+		if v, ok := cmd[name]; ok {
+			snap[name] = v
+		}
+	}
+	if key, ok := cmd["Key"].(map[string]types.AttributeValue); ok {
+		if item, err := unmarshallFromDynamo(key); err == nil {
+			snap["Key"] = item
+		}
+	}
+	if values, ok := cmd["ExpressionAttributeValues"].(map[string]types.AttributeValue); ok {
+		redacted := make(map[string]string, len(values))
+		for ref, v := range values {
+			redacted[ref] = attributeValueType(v)
+		}
+		snap["ExpressionAttributeValues"] = redacted
+	}
+	if item, ok := cmd["Item"].(map[string]types.AttributeValue); ok {
+		snap["Attributes"] = slices.Sorted(maps.Keys(item))
+	}
+	return snap
+}
+
+// attributeValueType returns the DynamoDB type descriptor of v, e.g. "S".
+func attributeValueType(v types.AttributeValue) string {
+	switch v.(type) {
+	case *types.AttributeValueMemberS:
+		return "S"
+	case *types.AttributeValueMemberN:
+		return "N"
+	case *types.AttributeValueMemberB:
+		return "B"
+	case *types.AttributeValueMemberBOOL:
+		return "BOOL"
+	case *types.AttributeValueMemberNULL:
+		return "NULL"
+	case *types.AttributeValueMemberM:
+		return "M"
+	case *types.AttributeValueMemberL:
+		return "L"
+	case *types.AttributeValueMemberSS:
+		return "SS"
+	case *types.AttributeValueMemberNS:
+		return "NS"
+	case *types.AttributeValueMemberBS:
+		return "BS"
+	}
+	return "?"
+}
+
+// runHook calls a user metrics/monitor/warning hook. Errors and panics are logged and
+// counted but never fail the data operation.
+func (t *Table) runHook(hook, modelName, op string, fn func() error) {
+	defer func() {
+		if r := recover(); r != nil {
+			t.hookFailures.Add(1)
+			logError(t.log, fmt.Sprintf(`OneTable %s hook panic for "%s" "%s"`, hook, op, modelName),
+				map[string]any{"panic": r, "model": modelName, "op": op})
+		}
+	}()
+	if err := fn(); err != nil {
+		t.hookFailures.Add(1)
+		logError(t.log, fmt.Sprintf(`OneTable %s hook failed for "%s" "%s"`, hook, op, modelName),
+			map[string]any{"err": err.Error(), "model": modelName, "op": op})
+	}
+}
+
+// warn reports a schema warning to the OnWarning hook, or logs it. A panicking
+// hook is counted like a failed metrics hook.
+func (t *Table) warn(w Warning) {
+	if t.onWarning == nil {
+		logInfo(t.log, w.Message, map[string]any{"code": w.Code, "model": w.Model, "field": w.Field})
+		return
+	}
+	t.runHook("warning", w.Model, w.Op, func() error {
+		t.onWarning(w)
+		return nil
+	})
+}
+
+// HookFailures returns the number of metrics/monitor/warning hook calls that
+// returned an error or panicked.
+func (t *Table) HookFailures() int64 {
+	return t.hookFailures.Load()
+}
+
+// metricParams returns params with MetricTags set to the model's tags in the
+// schema generation sm overlaid by the call's tags. params is copied, not
+// modified.
+func metricParams(sm *schemaManager, modelName string, params *Params) *Params {
+	var modelTags map[string]string
+	if sm != nil && sm.definition != nil {
+		modelTags = sm.definition.MetricTags[modelName]
+	}
+	if len(modelTags) == 0 {
+		return params
+	}
+	p := Params{}
+	if params != nil {
+		p = *params
+	}
+	tags := maps.Clone(modelTags)
+	maps.Copy(tags, p.MetricTags)
+	p.MetricTags = tags
+	return &p
+}
+
 // ─── crypto ───────────────────────────────────────────────────────────────────
 
 func (t *Table) initCrypto(cfg map[string]*CryptoConfig) error {
 	t.cryptoConfigs = map[string]*cryptoEntry{}
 	for name, c := range cfg {
+		if strings.Contains(name, ":") {
+			return NewArgError(fmt.Sprintf(`Crypto config name "%s" must not contain ":"`, name))
+		}
+		algorithm := strings.ToLower(cmp.Or(c.Cipher, "aes-256-gcm"))
+		size, ok := cipherKeySizes[algorithm]
+		if !ok {
+			return NewArgError(fmt.Sprintf(`Unsupported cipher "%s" for crypto config "%s"`, c.Cipher, name))
+		}
 		h := sha256.Sum256([]byte(c.Password))
 		t.cryptoConfigs[name] = &cryptoEntry{
 			name:   name,
-			cipher: c.Cipher,
-			key:    h[:],
+			cipher: algorithm,
+			key:    h[:size],
 		}
 	}
+	if t.cryptoConfigs[t.cryptKey] == nil && t.params.CryptKey != "" {
+		return NewArgError(fmt.Sprintf(`No crypto config for CryptKey "%s"`, t.cryptKey))
+	}
 	return nil
 }
 
-func (t *Table) encrypt(text string) (string, error) {
-	if text == "" {
-		return text, nil
+// aead returns the GCM cipher of the entry.
+func (e *cryptoEntry) aead() (cipher.AEAD, error) {
+	block, err := aes.NewCipher(e.key)
+	if err != nil {
+		return nil, err
 	}
+	return cipher.NewGCM(block)
+}
+
+// cryptEntry returns the crypto config name, or the table's CryptKey if name
+// is "".
+func (t *Table) cryptEntry(name string) (*cryptoEntry, error) {
 	if t.cryptoConfigs == nil {
-		return "", NewArgError("No crypto config defined")
+		return nil, NewArgError("No crypto config defined")
 	}
-	entry := t.cryptoConfigs["primary"]
+	name = cmp.Or(name, t.cryptKey)
+	entry := t.cryptoConfigs[name]
 	if entry == nil {
-		return "", NewArgError("No primary crypto config")
+		return nil, NewArgError(fmt.Sprintf("No crypto config for %q", name))
 	}
-	block, err := aes.NewCipher(entry.key)
+	return entry, nil
+}
+
+// encrypt encrypts text with the crypto config name, or the table's CryptKey
+// if name is "", sealing it with the associated data aad if not nil.
+func (t *Table) encrypt(text string, name string, aad []byte) (string, error) {
+	if text == "" {
+		return text, nil
+	}
+	entry, err := t.cryptEntry(name)
 	if err != nil {
 		return "", err
 	}
-	gcm, err := cipher.NewGCM(block)
+	gcm, err := entry.aead()
 	if err != nil {
 		return "", err
 	}
-	nonce := make([]byte, gcm.NonceSize())
+	format := cryptFormatV1
+	if aad != nil {
+		format |= cryptFormatBound
+	}
+	payload := make([]byte, 1+gcm.NonceSize(), 1+gcm.NonceSize()+len(text)+gcm.Overhead())
+	payload[0] = format
+	nonce := payload[1:]
 	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
 		return "", err
 	}
-	ciphertext := gcm.Seal(nonce, nonce, []byte(text), nil)
-	encoded := base64.StdEncoding.EncodeToString(ciphertext)
-	return fmt.Sprintf("primary::%x:%s", nonce, encoded), nil
+	payload = gcm.Seal(payload, nonce, []byte(text), append([]byte{format}, aad...))
+	return entry.name + ":" + base64.RawStdEncoding.EncodeToString(payload), nil
 }
 
-func (t *Table) decrypt(text string) (string, error) {
+// decrypt decrypts text produced by encrypt, or by the legacy format. aad must
+// match the associated data of a bound value and is ignored otherwise, unless
+// TableParams.CryptStrict rejects the unbound value.
+func (t *Table) decrypt(text string, aad []byte) (string, error) {
 	if text == "" {
 		return text, nil
 	}
-	parts := strings.SplitN(text, ":", 4)
-	if len(parts) < 4 {
+	name, encoded, ok := strings.Cut(text, ":")
+	if !ok {
 		return text, nil
 	}
-	name := parts[0]
-	if t.cryptoConfigs == nil {
-		return "", NewArgError("No crypto config defined")
+	if strings.Count(encoded, ":") == 2 {
+		return t.decryptLegacy(text, aad)
 	}
-	entry := t.cryptoConfigs[name]
-	if entry == nil {
-		return "", NewArgError(fmt.Sprintf("No crypto config for %q", name))
+	if strings.Contains(encoded, ":") {
+		return text, nil
 	}
-	data, err := base64.StdEncoding.DecodeString(parts[3])
+	entry, err := t.cryptEntry(name)
 	if err != nil {
 		return "", err
 	}
-	block, err := aes.NewCipher(entry.key)
+	payload, err := base64.RawStdEncoding.DecodeString(encoded)
 	if err != nil {
 		return "", err
 	}
-	gcm, err := cipher.NewGCM(block)
+	gcm, err := entry.aead()
+	if err != nil {
+		return "", err
+	}
+	if len(payload) < 1+gcm.NonceSize() {
+		return "", errors.New("ciphertext too short")
+	}
+	format := payload[0]
+	if format&^cryptFormatBound != cryptFormatV1 {
+		return "", fmt.Errorf("unsupported crypt format %#x", format)
+	}
+	if format&cryptFormatBound == 0 {
+		if t.cryptStrict {
+			return "", errors.New("unbound ciphertext rejected by CryptStrict")
+		}
+		aad = nil
+	} else if aad == nil {
+		return "", errors.New("missing associated data for bound ciphertext")
+	}
+	nonce, ciphertext := payload[1:1+gcm.NonceSize()], payload[1+gcm.NonceSize():]
+	plain, err := gcm.Open(nil, nonce, ciphertext, append([]byte{format}, aad...))
+	if err != nil {
+		return "", err
+	}
+	return string(plain), nil
+}
+
+// decryptLegacy decrypts the "name:mode:hex-nonce:base64" format, whose
+// base64 part repeats the nonce before the sealed text.
+func (t *Table) decryptLegacy(text string, aad []byte) (string, error) {
+	parts := strings.SplitN(text, ":", 4)
+	entry, err := t.cryptEntry(parts[0])
+	if err != nil {
+		return "", err
+	}
+	if parts[1] != cryptLegacyBound {
+		if t.cryptStrict {
+			return "", errors.New("unbound ciphertext rejected by CryptStrict")
+		}
+		aad = nil
+	} else if aad == nil {
+		return "", errors.New("missing associated data for bound ciphertext")
+	}
+	data, err := base64.StdEncoding.DecodeString(parts[3])
+	if err != nil {
+		return "", err
+	}
+	gcm, err := entry.aead()
 	if err != nil {
 		return "", err
 	}
@@ -1311,13 +2127,32 @@ func (t *Table) decrypt(text string) (string, error) {
 		return "", errors.New("ciphertext too short")
 	}
 	nonce, ciphertext := data[:gcm.NonceSize()], data[gcm.NonceSize():]
-	plain, err := gcm.Open(nil, nonce, ciphertext, nil)
+	plain, err := gcm.Open(nil, nonce, ciphertext, aad)
 	if err != nil {
 		return "", err
 	}
 	return string(plain), nil
 }
 
+// cryptCurrent reports whether the stored value text is in the current format,
+// sealed with the crypto config name (or the table's CryptKey) and bound to
+// its item as configured by TableParams.CryptBind.
+func (t *Table) cryptCurrent(text string, name string) bool {
+	key, encoded, ok := strings.Cut(text, ":")
+	if !ok || key != cmp.Or(name, t.cryptKey) || strings.Contains(encoded, ":") {
+		return false
+	}
+	payload, err := base64.RawStdEncoding.DecodeString(encoded)
+	if err != nil || len(payload) == 0 {
+		return false
+	}
+	want := cryptFormatV1
+	if t.cryptBind {
+		want |= cryptFormatBound
+	}
+	return payload[0] == want
+}
+
 // ─── marshall / unmarshall helpers ────────────────────────────────────────────
 
 // unmarshallItem converts a raw DynamoDB attribute value map into a plain Go Item.
@@ -1578,6 +2413,9 @@ func buildQueryInput(cmd Item) (*ddb.QueryInput, error) {
 	if esk, ok := cmd["ExclusiveStartKey"].(map[string]types.AttributeValue); ok {
 		input.ExclusiveStartKey = esk
 	}
+	if rc, ok := cmd["ReturnConsumedCapacity"].(string); ok {
+		input.ReturnConsumedCapacity = types.ReturnConsumedCapacity(rc)
+	}
 	if sel, ok := cmd["Select"].(string); ok {
 		input.Select = types.Select(sel)
 	}
@@ -1622,12 +2460,24 @@ func buildScanInput(cmd Item) (*ddb.ScanInput, error) {
 		s := int32(ts)
 		input.TotalSegments = &s
 	}
+	if rc, ok := cmd["ReturnConsumedCapacity"].(string); ok {
+		input.ReturnConsumedCapacity = types.ReturnConsumedCapacity(rc)
+	}
 	if sel, ok := cmd["Select"].(string); ok {
 		input.Select = types.Select(sel)
 	}
 	return input, nil
 }
 
+// consumedCapacity normalizes a ConsumedCapacity response for the result envelope.
+func consumedCapacity(c *types.ConsumedCapacity) map[string]any {
+	units := 0.0
+	if c.CapacityUnits != nil {
+		units = *c.CapacityUnits
+	}
+	return map[string]any{"CapacityUnits": units}
+}
+
 func unmarshalListOfMaps(list []map[string]types.AttributeValue) ([]Item, error) {
 	items := make([]Item, 0, len(list))
 	for _, av := range list {
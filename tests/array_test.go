@@ -4,6 +4,8 @@ package tests
 import (
 	"testing"
 
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
 	ot "github.com/cloudxsgmbh/dynamodb-onetable-go"
 )
 
@@ -80,3 +82,33 @@ func TestArray_FullUpdate(t *testing.T) {
 	}
 	_ = updated
 }
+
+func TestArray_EmptyList(t *testing.T) {
+	for _, partial := range []bool{true, false} {
+		for _, op := range []string{"create", "update", "upsert"} {
+			tbl, mock := makeTable(t, "ArrayTable", ArraySchema, partial)
+			if op != "create" {
+				tbl.Create(bg(), "User", ot.Item{"email": "user@example.com", //nolint
+					"addresses": []any{map[string]any{"street": "44 Park Ave"}}}, nil)
+			}
+			props := ot.Item{"email": "user@example.com", "addresses": []any{}}
+			var err error
+			switch op {
+			case "create":
+				_, err = tbl.Create(bg(), "User", props, nil)
+			case "update":
+				_, err = tbl.Update(bg(), "User", props, nil)
+			case "upsert":
+				_, err = tbl.Upsert(bg(), "User", props, nil)
+			}
+			if err != nil {
+				t.Fatalf("%s partial=%v: %v", op, partial, err)
+			}
+			for _, item := range mock.tbl("ArrayTable") {
+				if l, ok := item["addresses"].(*types.AttributeValueMemberL); !ok || len(l.Value) != 0 {
+					t.Errorf("%s partial=%v: expected an empty list, got %v", op, partial, item["addresses"])
+				}
+			}
+		}
+	}
+}
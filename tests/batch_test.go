@@ -2,7 +2,12 @@
 package tests
 
 import (
+	"context"
+	"errors"
 	"testing"
+	"time"
+
+	ddb "github.com/aws/aws-sdk-go-v2/service/dynamodb"
 
 	ot "github.com/cloudxsgmbh/dynamodb-onetable-go"
 )
@@ -143,3 +148,252 @@ func TestBatch_EmptyBatch(t *testing.T) {
 		t.Error("expected true for empty BatchWrite")
 	}
 }
+
+// throttledMock returns every batch write (or get) as unprocessed for the
+// first `throttle` (`getThrottle`) calls before delegating to the in-memory mock.
+type throttledMock struct {
+	*fullMock
+	throttle    int
+	calls       int
+	getThrottle int
+	getCalls    int
+}
+
+func (m *throttledMock) BatchGetItem(ctx context.Context, p *ddb.BatchGetItemInput, opts ...func(*ddb.Options)) (*ddb.BatchGetItemOutput, error) {
+	m.getCalls++
+	if m.getCalls <= m.getThrottle {
+		return &ddb.BatchGetItemOutput{UnprocessedKeys: p.RequestItems}, nil
+	}
+	return m.fullMock.BatchGetItem(ctx, p, opts...)
+}
+
+func (m *throttledMock) BatchWriteItem(ctx context.Context, p *ddb.BatchWriteItemInput, opts ...func(*ddb.Options)) (*ddb.BatchWriteItemOutput, error) {
+	m.calls++
+	if m.calls <= m.throttle {
+		return &ddb.BatchWriteItemOutput{UnprocessedItems: p.RequestItems}, nil
+	}
+	return m.fullMock.BatchWriteItem(ctx, p, opts...)
+}
+
+func TestBatch_WriteRetriesUnprocessed(t *testing.T) {
+	tbl, mock := makeTable(t, "BatchTable", DefaultSchema, false)
+	throttled := &throttledMock{fullMock: mock, throttle: 2}
+	tbl.SetClient(throttled)
+
+	batch := map[string]any{}
+	for _, d := range batchData {
+		if _, err := tbl.Create(bg(), "User", d, &ot.Params{Batch: batch}); err != nil {
+			t.Fatalf("batch create: %v", err)
+		}
+	}
+	ok, err := tbl.BatchWrite(bg(), batch, nil)
+	if err != nil || !ok {
+		t.Fatalf("BatchWrite: ok=%v err=%v", ok, err)
+	}
+	if throttled.calls != 3 {
+		t.Errorf("expected 3 BatchWriteItem calls, got %d", throttled.calls)
+	}
+	if mock.count("BatchTable") != len(batchData) {
+		t.Errorf("expected %d items, got %d", len(batchData), mock.count("BatchTable"))
+	}
+}
+
+func TestBatch_GetUnprocessedOtherError(t *testing.T) {
+	if got := ot.GetUnprocessed(ot.NewError("boom")); got != nil {
+		t.Errorf("expected nil unprocessed, got %v", got)
+	}
+}
+
+func TestBatch_GetUnprocessedKeys(t *testing.T) {
+	tbl, mock := makeRetryTable(t, 0, &ot.RetryPolicy{MaxRetries: 1, BaseDelay: time.Millisecond})
+	users := make([]ot.Item, 0, len(batchData))
+	for _, d := range batchData {
+		u, _ := tbl.Create(bg(), "User", d, nil)
+		users = append(users, u)
+	}
+	newBatch := func() map[string]any {
+		batch := map[string]any{}
+		for _, u := range users {
+			tbl.Get(bg(), "User", ot.Item{"id": u["id"]}, &ot.Params{Batch: batch}) //nolint
+		}
+		return batch
+	}
+
+	// retries exhausted: the keys are returned with the model resolved
+	mock.getThrottle = 100
+	result, err := tbl.BatchGet(bg(), newBatch(), &ot.Params{Parse: true})
+	var unprocessedErr *ot.UnprocessedError
+	if !errors.As(err, &unprocessedErr) || len(unprocessedErr.Requests) != len(users) {
+		t.Fatalf("expected UnprocessedError with %d keys, got %v", len(users), err)
+	}
+	for _, req := range unprocessedErr.Requests {
+		if req.Op != "get" || req.Model != "User" || req.Key["pk"] == nil {
+			t.Errorf("unexpected unprocessed request: %+v", req)
+		}
+	}
+	if items, ok := result.([]ot.Item); !ok || len(items) != 0 {
+		t.Errorf("expected an empty result, got %v", result)
+	}
+
+	// with Params.Batch the keys stay in the batch for the caller to retry
+	mock.getCalls, mock.getThrottle = 0, 1
+	batch := newBatch()
+	_, err = tbl.BatchGet(bg(), batch, &ot.Params{Parse: true, Batch: map[string]any{}})
+	if len(ot.GetUnprocessed(err)) != len(users) || mock.getCalls != 1 {
+		t.Fatalf("expected unprocessed keys without retry, got %v after %d calls", err, mock.getCalls)
+	}
+	result, err = tbl.BatchGet(bg(), batch, &ot.Params{Parse: true})
+	if err != nil {
+		t.Fatalf("BatchGet retry: %v", err)
+	}
+	items, _ := result.([]ot.Item)
+	assertLen(t, items, len(users))
+}
+
+func makeRetryTable(t *testing.T, throttle int, policy *ot.RetryPolicy) (*ot.Table, *throttledMock) {
+	t.Helper()
+	mock := &throttledMock{fullMock: newFullMock(), throttle: throttle}
+	tbl, err := ot.NewTable(ot.TableParams{Name: "BatchTable", Client: mock, Schema: DefaultSchema, Retry: policy})
+	if err != nil {
+		t.Fatalf("NewTable: %v", err)
+	}
+	return tbl, mock
+}
+
+func TestBatch_WriteRetriesExhausted(t *testing.T) {
+	tbl, mock := makeRetryTable(t, 100, &ot.RetryPolicy{MaxRetries: 2, BaseDelay: time.Millisecond})
+	batch := map[string]any{}
+	for _, d := range batchData {
+		tbl.Create(bg(), "User", d, &ot.Params{Batch: batch}) //nolint
+	}
+	stats := &ot.Stats{}
+	ok, err := tbl.BatchWrite(bg(), batch, &ot.Params{Stats: stats})
+	if ok {
+		t.Fatal("expected BatchWrite to fail")
+	}
+	var unprocessedErr *ot.UnprocessedError
+	if !errors.As(err, &unprocessedErr) || unprocessedErr.Code != ot.ErrRuntime {
+		t.Fatalf("expected UnprocessedError, got %v", err)
+	}
+	if mock.calls != 3 {
+		t.Errorf("expected 3 BatchWriteItem calls, got %d", mock.calls)
+	}
+	if stats.Retries != 2 {
+		t.Errorf("expected 2 retries in stats, got %d", stats.Retries)
+	}
+	unprocessed := unprocessedErr.Requests
+	if len(unprocessed) != len(batchData) {
+		t.Fatalf("expected %d unprocessed, got %d", len(batchData), len(unprocessed))
+	}
+	for _, req := range unprocessed {
+		if req.Model != "User" || req.Op != "put" {
+			t.Errorf("unexpected unprocessed request: %+v", req)
+		}
+		if req.Key["pk"] == nil || req.Key["sk"] == nil || req.Item["email"] == nil {
+			t.Errorf("incomplete unprocessed request: %+v", req)
+		}
+	}
+}
+
+func TestBatch_WriteRetryRespectsDeadline(t *testing.T) {
+	tbl, mock := makeRetryTable(t, 100, &ot.RetryPolicy{BaseDelay: time.Second, NoJitter: true})
+	batch := map[string]any{}
+	tbl.Remove(bg(), "User", ot.Item{"id": "gone"}, &ot.Params{Batch: batch}) //nolint
+	tbl.Remove(bg(), "Pet", ot.Item{"id": "gone"}, &ot.Params{Batch: batch})  //nolint
+	ctx, cancel := context.WithTimeout(bg(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := tbl.BatchWrite(ctx, batch, nil)
+	var ote *ot.OneTableError
+	if !errors.As(err, &ote) || ote.Code != ot.ErrRuntime {
+		t.Errorf("expected a runtime error, got %v", err)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected deadline error, got %v", err)
+	}
+	if time.Since(start) > 500*time.Millisecond {
+		t.Errorf("retry did not honour the context deadline")
+	}
+	if mock.calls != 1 {
+		t.Errorf("expected a single attempt, got %d", mock.calls)
+	}
+	// the model of a delete is resolved from its key
+	unprocessed := ot.GetUnprocessed(err)
+	models := map[string]bool{}
+	for _, req := range unprocessed {
+		if req.Op != "delete" {
+			t.Errorf("unexpected unprocessed: %+v", req)
+		}
+		models[req.Model] = true
+	}
+	if len(unprocessed) != 2 || !models["User"] || !models["Pet"] {
+		t.Errorf("unexpected unprocessed: %+v", unprocessed)
+	}
+}
+
+func TestBatch_GetRetriesUnprocessed(t *testing.T) {
+	tbl, mock := makeRetryTable(t, 0, &ot.RetryPolicy{BaseDelay: time.Millisecond})
+	mock.getThrottle = 1
+	users := make([]ot.Item, 0, len(batchData))
+	for _, d := range batchData {
+		u, _ := tbl.Create(bg(), "User", d, nil)
+		users = append(users, u)
+	}
+	batch := map[string]any{}
+	for _, u := range users {
+		tbl.Get(bg(), "User", ot.Item{"id": u["id"]}, &ot.Params{Batch: batch}) //nolint
+	}
+	stats := &ot.Stats{}
+	result, err := tbl.BatchGet(bg(), batch, &ot.Params{Parse: true, Stats: stats})
+	if err != nil {
+		t.Fatalf("BatchGet: %v", err)
+	}
+	items, _ := result.([]ot.Item)
+	assertLen(t, items, len(batchData))
+	if mock.getCalls != 2 || stats.Retries != 1 {
+		t.Errorf("expected 2 calls and 1 retry, got %d calls and %d retries", mock.getCalls, stats.Retries)
+	}
+}
+
+func TestBatch_GetFollowIndex(t *testing.T) {
+	tbl, _ := makeTable(t, "BatchTable", DefaultSchema, false)
+	users := make([]ot.Item, 0, len(batchData))
+	for _, d := range batchData {
+		u, _ := tbl.Create(bg(), "User", d, nil)
+		users = append(users, u)
+	}
+	batch := map[string]any{}
+	for _, u := range users {
+		_, err := tbl.Get(bg(), "User", ot.Item{"name": u["name"]}, &ot.Params{Batch: batch, Index: "gs1", Follow: truePtr()})
+		if err != nil {
+			t.Fatalf("batch get on gs1: %v", err)
+		}
+	}
+	def := batch["RequestItems"].(map[string]any)["BatchTable"].(map[string]any)
+	if keys, _ := def["Keys"].([]any); len(keys) != len(users) {
+		t.Fatalf("expected %d primary keys in batch, got %d", len(users), len(keys))
+	}
+	result, err := tbl.BatchGet(bg(), batch, &ot.Params{Parse: true})
+	if err != nil {
+		t.Fatalf("BatchGet: %v", err)
+	}
+	items, _ := result.([]ot.Item)
+	assertLen(t, items, len(users))
+	for _, item := range items {
+		assertPresent(t, item, "email")
+	}
+}
+
+func TestBatch_GetIndexWithoutFollow(t *testing.T) {
+	tbl, _ := makeTable(t, "BatchTable", DefaultSchema, false)
+	tbl.Create(bg(), "User", batchData[0], nil) //nolint
+	batch := map[string]any{}
+	_, err := tbl.Get(bg(), "User", ot.Item{"name": batchData[0]["name"]}, &ot.Params{Batch: batch, Index: "gs1"})
+	var argErr *ot.OneTableArgError
+	if !errors.As(err, &argErr) {
+		t.Fatalf("expected argument error, got %v", err)
+	}
+	if len(batch) != 0 {
+		t.Errorf("expected empty batch, got %v", batch)
+	}
+}
@@ -0,0 +1,110 @@
+package tests
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+
+	ot "github.com/cloudxsgmbh/dynamodb-onetable-go"
+)
+
+func TestClient_NewClient(t *testing.T) {
+	var mu sync.Mutex
+	var auth []string
+	var form url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		auth = append(auth, r.Header.Get("Authorization"))
+		if r.URL.Path == "/sts" {
+			r.ParseForm() //nolint
+			form = r.Form
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+		w.Write([]byte(`{"TableNames":["MyTable"]}`)) //nolint
+	}))
+	defer server.Close()
+
+	// keep the host's shared configuration out of the default chain
+	dir := t.TempDir()
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(dir, "config"))
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(dir, "credentials"))
+	t.Setenv("AWS_PROFILE", "")
+
+	// DynamoDB Local: an endpoint needs no credentials
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	client, err := ot.NewClient(bg(), ot.ClientOptions{Region: "eu-west-1", Endpoint: server.URL,
+		MaxAttempts: 1, MaxBackoff: time.Second})
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	options := client.Options()
+	if options.Region != "eu-west-1" || aws.ToString(options.BaseEndpoint) != server.URL {
+		t.Errorf("unexpected region %q or endpoint %q", options.Region, aws.ToString(options.BaseEndpoint))
+	}
+	if options.Retryer.MaxAttempts() != 1 {
+		t.Errorf("expected 1 attempt, got %d", options.Retryer.MaxAttempts())
+	}
+	out, err := client.ListTables(bg(), &dynamodb.ListTablesInput{})
+	if err != nil || len(out.TableNames) != 1 {
+		t.Fatalf("ListTables: %v %v", out, err)
+	}
+	if !strings.Contains(auth[0], "Credential=local/") || !strings.Contains(auth[0], "/eu-west-1/dynamodb/") {
+		t.Errorf("unexpected authorization %s", auth[0])
+	}
+
+	// explicit credentials are cached and sign the requests
+	static := credentials.NewStaticCredentialsProvider("AKIASTATIC", "secret", "")
+	client, err = ot.NewClient(bg(), ot.ClientOptions{Region: "eu-west-1", Endpoint: server.URL, Credentials: static})
+	if err != nil {
+		t.Fatalf("NewClient credentials: %v", err)
+	}
+	cache, ok := client.Options().Credentials.(*aws.CredentialsCache)
+	if !ok || !cache.IsCredentialsProvider(credentials.StaticCredentialsProvider{}) {
+		t.Errorf("credentials not a cached static provider: %T", client.Options().Credentials)
+	}
+	if _, err := client.ListTables(bg(), &dynamodb.ListTablesInput{}); err != nil {
+		t.Fatalf("ListTables: %v", err)
+	}
+	if !strings.Contains(auth[1], "Credential=AKIASTATIC/") {
+		t.Errorf("unexpected authorization %s", auth[1])
+	}
+
+	// the role is assumed at the STS endpoint with the base credentials
+	role := &ot.AssumeRole{RoleARN: "arn:aws:iam::123456789012:role/app", ExternalID: "ext",
+		Duration: 30 * time.Minute, Endpoint: server.URL + "/sts"}
+	_, err = ot.NewClient(bg(), ot.ClientOptions{Region: "eu-west-1", Credentials: static, AssumeRole: role, MaxAttempts: 1})
+	assertErrCode(t, err, ot.ErrRuntime)
+	if !strings.Contains(auth[2], "Credential=AKIASTATIC/") || !strings.Contains(auth[2], "/eu-west-1/sts/") {
+		t.Errorf("STS call not signed with the base credentials: %s", auth[2])
+	}
+	for key, want := range map[string]string{"Action": "AssumeRole", "RoleArn": role.RoleARN,
+		"RoleSessionName": "onetable", "ExternalId": "ext", "DurationSeconds": "1800"} {
+		if got := form.Get(key); got != want {
+			t.Errorf("STS %s = %q, want %q", key, got, want)
+		}
+	}
+
+	var argErr *ot.OneTableArgError
+	_, err = ot.NewClient(bg(), ot.ClientOptions{Region: "eu-west-1", AssumeRole: &ot.AssumeRole{}})
+	if !errors.As(err, &argErr) {
+		t.Errorf("missing role ARN: expected OneTableArgError, got %v", err)
+	}
+	t.Setenv("AWS_REGION", "")
+	t.Setenv("AWS_DEFAULT_REGION", "")
+	if _, err := ot.NewClient(bg(), ot.ClientOptions{}); !errors.As(err, &argErr) {
+		t.Errorf("missing region: expected OneTableArgError, got %v", err)
+	}
+}
@@ -0,0 +1,139 @@
+package tests
+
+import (
+	"errors"
+	"testing"
+
+	ot "github.com/cloudxsgmbh/dynamodb-onetable-go"
+)
+
+func TestConflict_Strategies(t *testing.T) {
+	tbl, mock := makeTable(t, "ConflictTable", DefaultSchema, false)
+	User, _ := tbl.GetModel("User")
+	if _, err := User.Create(bg(), ot.Item{"id": "1", "name": "Peter", "email": "peter@example.com", "age": 40}, nil); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if _, err := User.Create(bg(), ot.Item{"id": "1", "name": "Paul"}, &ot.Params{OnConflict: ot.ConflictFail}); err == nil {
+		t.Error("expected conflict to fail")
+	}
+
+	item, err := User.Create(bg(), ot.Item{"id": "1", "name": "Paul"}, &ot.Params{OnConflict: ot.ConflictIgnore})
+	if err != nil || item != nil {
+		t.Fatalf("Ignore: %v %v", item, err)
+	}
+	item, _ = User.Get(bg(), ot.Item{"id": "1"}, nil)
+	assertStr(t, item, "name", "Peter")
+
+	item, err = User.Create(bg(), ot.Item{"id": "1", "name": "Paul"}, &ot.Params{OnConflict: ot.ConflictMerge})
+	if err != nil {
+		t.Fatalf("Merge: %v", err)
+	}
+	item, _ = User.Get(bg(), ot.Item{"id": "1"}, nil)
+	assertStr(t, item, "name", "Paul")
+	assertStr(t, item, "email", "peter@example.com")
+
+	if _, err = User.Create(bg(), ot.Item{"id": "1", "name": "Mary"}, &ot.Params{OnConflict: "Replace"}); err != nil {
+		t.Fatalf("Replace: %v", err)
+	}
+	item, _ = User.Get(bg(), ot.Item{"id": "1"}, nil)
+	assertStr(t, item, "name", "Mary")
+	if item["email"] != nil || item["age"] != nil {
+		t.Errorf("replaced item kept old fields: %v", item)
+	}
+
+	// without a conflict every strategy creates the item
+	for i, strategy := range []ot.ConflictStrategy{ot.ConflictReplace, ot.ConflictMerge, ot.ConflictIgnore} {
+		id := string(rune('2' + i))
+		if item, err := User.Create(bg(), ot.Item{"id": id, "name": "New"}, &ot.Params{OnConflict: strategy}); err != nil || item == nil {
+			t.Errorf("%s: %v %v", strategy, item, err)
+		}
+	}
+	if n := len(mock.tbl("ConflictTable")); n != 4 {
+		t.Errorf("expected 4 items, got %d", n)
+	}
+
+	var argErr *ot.OneTableArgError
+	for _, params := range []*ot.Params{{OnConflict: "skip"}, {OnConflict: ot.ConflictReplace, Exists: new(bool)},
+		{OnConflict: ot.ConflictIgnore, Transaction: map[string]any{}}} {
+		if _, err := User.Create(bg(), ot.Item{"id": "9"}, params); !errors.As(err, &argErr) {
+			t.Errorf("%+v: expected OneTableArgError, got %v", params, err)
+		}
+	}
+}
+
+func TestConflict_Unique(t *testing.T) {
+	tbl, mock := makeTable(t, "ConflictTable", UniqueSchema, false)
+	User, _ := tbl.GetModel("User")
+	for _, p := range []ot.Item{
+		{"name": "Peter Smith", "email": "peter@example.com"},
+		{"name": "Judy Smith", "email": "judy@example.com"},
+	} {
+		if _, err := User.Create(bg(), p, nil); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+	}
+
+	// a unique value taken by another item is not ignored
+	_, err := User.Create(bg(), ot.Item{"name": "Paul Smith", "email": "judy@example.com"}, &ot.Params{OnConflict: ot.ConflictIgnore})
+	assertErrCode(t, err, ot.ErrUnique)
+	item, err := User.Create(bg(), ot.Item{"name": "Judy Smith", "email": "other@example.com"}, &ot.Params{OnConflict: ot.ConflictIgnore})
+	if err != nil || item != nil {
+		t.Errorf("Ignore: %v %v", item, err)
+	}
+
+	if _, err := User.Create(bg(), ot.Item{"name": "Peter Smith", "email": "pete@example.com"}, &ot.Params{OnConflict: ot.ConflictReplace}); err != nil {
+		t.Fatalf("Replace: %v", err)
+	}
+	item, _ = User.Get(bg(), ot.Item{"name": "Peter Smith"}, nil)
+	assertStr(t, item, "email", "pete@example.com")
+	items := mock.tbl("ConflictTable")
+	if _, ok := items["_unique#User#email#peter@example.com||_unique#"]; ok {
+		t.Error("stale unique sentinel")
+	}
+	if _, ok := items["_unique#User#email#pete@example.com||_unique#"]; !ok {
+		t.Error("missing unique sentinel for the new value")
+	}
+	if len(items) != 6 {
+		t.Errorf("expected 2 items and 4 sentinels, got %d", len(items))
+	}
+}
+
+func TestConflict_ReplaceCrypt(t *testing.T) {
+	schema := &ot.SchemaDef{
+		Version: "0.0.1",
+		Indexes: map[string]*ot.IndexDef{"primary": {Hash: "pk", Sort: "sk"}},
+		Models: map[string]ot.ModelDef{
+			"User": {
+				"pk":     {Type: ot.FieldTypeString, Value: "user#${id}"},
+				"sk":     {Type: ot.FieldTypeString, Value: "user#"},
+				"id":     {Type: ot.FieldTypeString, Generate: "ulid"},
+				"email":  {Type: ot.FieldTypeString, Unique: true},
+				"secret": {Type: ot.FieldTypeString, Crypt: true},
+			},
+		},
+	}
+	tbl, err := ot.NewTable(ot.TableParams{Name: "ConflictTable", Client: newFullMock(), Schema: schema,
+		Crypto: map[string]*ot.CryptoConfig{"primary": {Password: "pw"}}})
+	if err != nil {
+		t.Fatalf("NewTable: %v", err)
+	}
+	User, _ := tbl.GetModel("User")
+
+	// the first replace creates the item, the second replaces it
+	for _, secret := range []string{"s1", "s2"} {
+		if _, err := User.Create(bg(), ot.Item{"id": "1", "email": secret + "@example.com", "secret": secret},
+			&ot.Params{OnConflict: ot.ConflictReplace}); err != nil {
+			t.Fatalf("Replace %s: %v", secret, err)
+		}
+		item, _ := User.Get(bg(), ot.Item{"id": "1"}, nil)
+		assertStr(t, item, "secret", secret)
+	}
+
+	// a generated id is the same for the key lookup and the write
+	item, err := User.Create(bg(), ot.Item{"email": "new@example.com", "secret": "s3"}, &ot.Params{OnConflict: ot.ConflictReplace})
+	if err != nil {
+		t.Fatalf("Replace generated: %v", err)
+	}
+	item, _ = User.Get(bg(), ot.Item{"id": item["id"]}, nil)
+	assertStr(t, item, "secret", "s3")
+}
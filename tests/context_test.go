@@ -2,8 +2,12 @@
 package tests
 
 import (
+	"fmt"
+	"strings"
 	"testing"
 
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
 	ot "github.com/cloudxsgmbh/dynamodb-onetable-go"
 )
 
@@ -100,3 +104,148 @@ func TestContext_RemoveMany(t *testing.T) {
 	result, _ := tbl.Scan(bg(), "User", ot.Item{}, nil)
 	assertLen(t, result.Items, 0)
 }
+
+var templateNamespaceSchema = &ot.SchemaDef{
+	Format:  "onetable:1.1.0",
+	Version: "0.0.1",
+	Indexes: map[string]*ot.IndexDef{"primary": {Hash: "pk", Sort: "sk"}},
+	Models: map[string]ot.ModelDef{
+		"Order": {
+			"pk":     {Type: ot.FieldTypeString, Value: "Tenant#${ctx.tenantId}"},
+			"sk":     {Type: ot.FieldTypeString, Value: "Order#${id}"},
+			"id":     {Type: ot.FieldTypeString, Generate: "ulid"},
+			"region": {Type: ot.FieldTypeString, Value: "${param.substitutions.region}"},
+			"via":    {Type: ot.FieldTypeString, Value: "${param.index}"},
+		},
+	},
+	Params: &ot.SchemaParams{IsoDates: true},
+}
+
+func TestContext_TemplateNamespaces(t *testing.T) {
+	tbl, mock := makeTable(t, "ContextTable", templateNamespaceSchema, false)
+	tbl.SetContext(ot.Item{"tenantId": "acme"}, false)
+
+	order, err := tbl.Create(bg(), "Order", ot.Item{}, &ot.Params{
+		Substitutions: map[string]any{"region": "eu"},
+		Hidden:        truePtr(),
+	})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	assertStr(t, order, "pk", "Tenant#acme")
+	assertStr(t, order, "region", "eu")
+	assertStr(t, order, "via", "primary")
+	if mock.count("ContextTable") != 1 {
+		t.Fatalf("expected 1 item, got %d", mock.count("ContextTable"))
+	}
+
+	got, err := tbl.Get(bg(), "Order", ot.Item{"id": order["id"]}, nil)
+	if err != nil || got == nil {
+		t.Fatalf("Get with ctx key: %v %v", got, err)
+	}
+
+	// a different tenant cannot address the item
+	tbl.SetContext(ot.Item{"tenantId": "other"}, false)
+	got, err = tbl.Get(bg(), "Order", ot.Item{"id": order["id"]}, nil)
+	if err != nil || got != nil {
+		t.Errorf("expected no item for other tenant, got %v %v", got, err)
+	}
+}
+
+func TestContext_TemplateUnknownParam(t *testing.T) {
+	schema := &ot.SchemaDef{
+		Format:  "onetable:1.1.0",
+		Version: "0.0.1",
+		Indexes: map[string]*ot.IndexDef{"primary": {Hash: "pk", Sort: "sk"}},
+		Models: map[string]ot.ModelDef{
+			"Order": {
+				"pk": {Type: ot.FieldTypeString, Value: "${param.bogus}"},
+				"sk": {Type: ot.FieldTypeString, Value: "Order"},
+			},
+		},
+	}
+	defer func() {
+		if r := recover(); r == nil {
+			t.Error("expected schema error for unknown ${param.bogus}")
+		}
+	}()
+	ot.NewTable(ot.TableParams{Name: "ContextTable", Client: newFullMock(), Schema: schema}) //nolint
+}
+
+func TestContext_TemplateReservedField(t *testing.T) {
+	for _, name := range []string{"ctx", "param"} {
+		schema := &ot.SchemaDef{
+			Version: "0.0.1",
+			Indexes: map[string]*ot.IndexDef{"primary": {Hash: "pk", Sort: "sk"}},
+			Models: map[string]ot.ModelDef{
+				"Order": {
+					"pk": {Type: ot.FieldTypeString, Value: "Order#${" + name + ".id}"},
+					"sk": {Type: ot.FieldTypeString, Value: "Order"},
+					name: {Type: ot.FieldTypeObject},
+				},
+			},
+		}
+		func() {
+			defer func() {
+				if r := recover(); r == nil || !strings.Contains(fmt.Sprint(r), "reserved template namespace") {
+					t.Errorf("expected schema error for a field named %s, got %v", name, r)
+				}
+			}()
+			ot.NewTable(ot.TableParams{Name: "ContextTable", Client: newFullMock(), Schema: schema}) //nolint
+		}()
+	}
+}
+
+func TestContext_TemplateEscape(t *testing.T) {
+	schema := &ot.SchemaDef{
+		Format:  "onetable:1.1.0",
+		Version: "0.0.1",
+		Indexes: map[string]*ot.IndexDef{"primary": {Hash: "pk", Sort: "sk"}},
+		Models: map[string]ot.ModelDef{
+			"Doc": {
+				"pk":    {Type: ot.FieldTypeString, Value: "Doc#${name}"},
+				"sk":    {Type: ot.FieldTypeString, Value: "Doc#"},
+				"name":  {Type: ot.FieldTypeString},
+				"label": {Type: ot.FieldTypeString, Value: "$${param.bogus} by ${name}", Hidden: falsePtr()},
+				"body":  {Type: ot.FieldTypeString},
+			},
+		},
+	}
+	tbl, _ := makeTable(t, "EscapeTable", schema, false)
+
+	// a property value containing "${" is not an unresolved variable
+	doc, err := tbl.Create(bg(), "Doc", ot.Item{"name": "a${b}", "body": "Hello ${name}"}, nil)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	assertStr(t, doc, "label", "${param.bogus} by a${b}")
+
+	got, err := tbl.Get(bg(), "Doc", ot.Item{"name": "a${b}"}, nil)
+	if err != nil || got == nil {
+		t.Fatalf("Get: %v %v", got, err)
+	}
+	assertStr(t, got, "body", "Hello ${name}")
+
+	// where: a literal "${...}" inside a value
+	result, err := tbl.Scan(bg(), "Doc", nil, &ot.Params{Where: "${body} = {Hello $${name}}"})
+	if err != nil {
+		t.Fatalf("Scan: %v", err)
+	}
+	assertLen(t, result.Items, 1)
+
+	noExec := false
+	cmd, err := tbl.Scan(bg(), "Doc", nil, &ot.Params{Where: "${body} = {$${x}}", Execute: &noExec})
+	if err != nil {
+		t.Fatalf("Scan: %v", err)
+	}
+	values := cmd.Items[0]["ExpressionAttributeValues"].(map[string]types.AttributeValue)
+	found := false
+	for _, v := range values {
+		if s, ok := v.(*types.AttributeValueMemberS); ok && s.Value == "${x}" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("expected literal ${x} value, got %v", values)
+	}
+}
@@ -2,6 +2,10 @@
 package tests
 
 import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
 	"testing"
 	"time"
 
@@ -97,6 +101,58 @@ func TestCRUD_GetHidden(t *testing.T) {
 	assertPresent(t, got, "gs1pk")
 }
 
+func TestCRUD_GetIndexFollow(t *testing.T) {
+	tbl, _ := makeTable(t, "CrudTable", DefaultSchema, false)
+	user, _ := tbl.Create(bg(), "User", ot.Item{"name": "Peter Smith", "email": "peter@example.com"}, nil)
+
+	got, err := tbl.Get(bg(), "User", ot.Item{"name": "Peter Smith"}, &ot.Params{Index: "gs1", Follow: truePtr()})
+	if err != nil {
+		t.Fatalf("Get follow: %v", err)
+	}
+	if got == nil {
+		t.Fatal("expected item")
+	}
+	assertStr(t, got, "id", user["id"].(string))
+	assertStr(t, got, "email", "peter@example.com")
+}
+
+func TestCRUD_FollowFields(t *testing.T) {
+	tbl, _ := makeTable(t, "CrudTable", DefaultSchema, false)
+	user, _ := tbl.Create(bg(), "User", ot.Item{"name": "Peter Smith", "email": "peter@example.com", "age": float64(42)}, nil)
+	projections := map[string][]string{}
+	tbl.SetTestHooks(&ot.TestHooks{Command: func(_, op string, cmd ot.Item) {
+		names, _ := cmd["ExpressionAttributeNames"].(map[string]string)
+		for tok := range strings.SplitSeq(fmt.Sprint(cmd["ProjectionExpression"]), ", ") {
+			if name, ok := names[tok]; ok {
+				projections[op] = append(projections[op], name)
+			}
+		}
+	}})
+
+	// the index query reads only the primary key, the follow get the fields
+	params := &ot.Params{Index: "gs1", Follow: truePtr(), Fields: []string{"id", "email"}}
+	result, err := tbl.Find(bg(), "User", ot.Item{"name": "Peter Smith"}, params)
+	if err != nil {
+		t.Fatalf("Find follow: %v", err)
+	}
+	assertLen(t, result.Items, 1)
+	assertStr(t, result.Items[0], "id", user["id"].(string))
+	assertStr(t, result.Items[0], "email", "peter@example.com")
+	assertAbsent(t, result.Items[0], "age")
+	slices.Sort(projections["find"])
+	slices.Sort(projections["get"])
+	if !slices.Equal(projections["find"], []string{"pk", "sk"}) || !slices.Equal(projections["get"], []string{"email", "id"}) {
+		t.Errorf("unexpected projections %v", projections)
+	}
+
+	got, err := tbl.Get(bg(), "User", ot.Item{"name": "Peter Smith"}, params)
+	if err != nil || got == nil {
+		t.Fatalf("Get follow: %v %v", got, err)
+	}
+	assertStr(t, got, "email", "peter@example.com")
+	assertAbsent(t, got, "age")
+}
+
 func TestCRUD_Update(t *testing.T) {
 	tbl, _ := makeTable(t, "CrudTable", DefaultSchema, false)
 	user, _ := tbl.Create(bg(), "User", ot.Item{"name": "Peter Smith", "status": "active", "age": float64(20)}, nil)
@@ -156,6 +212,34 @@ func TestCRUD_Scan(t *testing.T) {
 	}
 }
 
+func TestCRUD_ScanMixedModels(t *testing.T) {
+	tbl, _ := makeTable(t, "CrudTable", DefaultSchema, false)
+	tbl.Create(bg(), "User", ot.Item{"name": "Sky Blue", "status": "active"}, nil)        //nolint
+	tbl.Create(bg(), "Pet", ot.Item{"name": "Rex", "race": "dog", "breed": "lab"}, nil)   //nolint
+	tbl.Create(bg(), "Pet", ot.Item{"name": "Tom", "race": "cat", "breed": "tabby"}, nil) //nolint
+
+	result, err := tbl.Scan(bg(), "User", ot.Item{"_type": "Pet"}, nil)
+	if err != nil {
+		t.Fatalf("Scan: %v", err)
+	}
+	assertLen(t, result.Items, 1)
+	assertStr(t, result.Items[0], "name", "Sky Blue")
+
+	groups, err := tbl.ScanAll(bg(), nil)
+	if err != nil {
+		t.Fatalf("ScanAll: %v", err)
+	}
+	assertLen(t, groups["User"], 1)
+	assertLen(t, groups["Pet"], 2)
+	for _, pet := range groups["Pet"] {
+		assertAbsent(t, pet, "pk")
+		assertPresent(t, pet, "breed")
+	}
+	if len(groups) != 2 {
+		t.Errorf("expected only User and Pet groups, got %v", slices.Collect(maps.Keys(groups)))
+	}
+}
+
 func TestCRUD_DefaultStatus(t *testing.T) {
 	tbl, _ := makeTable(t, "DefaultTable", DefaultSchema, false)
 	user, err := tbl.Create(bg(), "User", ot.Item{"name": "Peter Smith", "email": "peter@example.com"}, nil)
@@ -0,0 +1,344 @@
+package tests
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	ot "github.com/cloudxsgmbh/dynamodb-onetable-go"
+)
+
+var CryptSchema = &ot.SchemaDef{
+	Version: "0.0.1",
+	Indexes: map[string]*ot.IndexDef{
+		"primary": {Hash: "pk", Sort: "sk"},
+	},
+	Models: map[string]ot.ModelDef{
+		"User": {
+			"pk":     {Type: ot.FieldTypeString, Value: "user#${id}"},
+			"sk":     {Type: ot.FieldTypeString, Value: "user#"},
+			"id":     {Type: ot.FieldTypeString, Required: true},
+			"secret": {Type: ot.FieldTypeString, Crypt: true},
+			"ssn":    {Type: ot.FieldTypeString, CryptKey: "pii"},
+			"profile": {Type: ot.FieldTypeObject, Schema: ot.FieldMap{
+				"token": {Type: ot.FieldTypeString, Crypt: true},
+			}},
+		},
+	},
+}
+
+func TestCrypt_KeyRings(t *testing.T) {
+	mock := newFullMock()
+	crypto := map[string]*ot.CryptoConfig{
+		"primary": {Password: "first", Cipher: "aes-256-gcm"},
+		"pii":     {Password: "second", Cipher: "AES-128-GCM"},
+	}
+	tbl, err := ot.NewTable(ot.TableParams{Name: "CryptTable", Client: mock, Schema: CryptSchema, Crypto: crypto})
+	if err != nil {
+		t.Fatalf("NewTable: %v", err)
+	}
+	User, _ := tbl.GetModel("User")
+	if _, err := User.Create(bg(), ot.Item{"id": "1", "secret": "s3cret", "ssn": "123-45-6789"}, nil); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	stored := func() map[string]types.AttributeValue {
+		for _, item := range mock.tbl("CryptTable") {
+			return item
+		}
+		return nil
+	}
+	raw := stored()
+	if s := avStr(raw["secret"]); !strings.HasPrefix(s, "primary:") || strings.Contains(s, "s3cret") {
+		t.Errorf("secret not encrypted with primary: %s", s)
+	}
+	if s := avStr(raw["ssn"]); !strings.HasPrefix(s, "pii:") || strings.Contains(s, "123-45-6789") {
+		t.Errorf("ssn not encrypted with pii: %s", s)
+	}
+	item, err := User.Get(bg(), ot.Item{"id": "1"}, nil)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	assertStr(t, item, "secret", "s3cret")
+	assertStr(t, item, "ssn", "123-45-6789")
+
+	// rotate: new writes use "v2", old values still decrypt with "primary"
+	crypto["v2"] = &ot.CryptoConfig{Password: "third"}
+	tbl, err = ot.NewTable(ot.TableParams{Name: "CryptTable", Client: mock, Schema: CryptSchema, Crypto: crypto, CryptKey: "v2"})
+	if err != nil {
+		t.Fatalf("NewTable: %v", err)
+	}
+	User, _ = tbl.GetModel("User")
+	item, err = User.Get(bg(), ot.Item{"id": "1"}, nil)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	assertStr(t, item, "secret", "s3cret")
+	if _, err := User.Update(bg(), ot.Item{"id": "1", "secret": item["secret"]}, nil); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	raw = stored()
+	if s := avStr(raw["secret"]); !strings.HasPrefix(s, "v2:") {
+		t.Errorf("secret not re-encrypted with v2: %s", s)
+	}
+	if s := avStr(raw["ssn"]); !strings.HasPrefix(s, "pii:") {
+		t.Errorf("ssn should keep its field key: %s", s)
+	}
+	item, _ = User.Get(bg(), ot.Item{"id": "1"}, nil)
+	assertStr(t, item, "secret", "s3cret")
+}
+
+func TestCrypt_Bind(t *testing.T) {
+	mock := newFullMock()
+	crypto := map[string]*ot.CryptoConfig{"primary": {Password: "first"}, "pii": {Password: "second"}}
+	unbound, _ := ot.NewTable(ot.TableParams{Name: "CryptTable", Client: mock, Schema: CryptSchema, Crypto: crypto})
+	User, _ := unbound.GetModel("User")
+	User.Create(bg(), ot.Item{"id": "0", "secret": "legacy"}, nil) //nolint
+
+	tbl, err := ot.NewTable(ot.TableParams{Name: "CryptTable", Client: mock, Schema: CryptSchema, Crypto: crypto, CryptBind: true})
+	if err != nil {
+		t.Fatalf("NewTable: %v", err)
+	}
+	User, _ = tbl.GetModel("User")
+	for _, id := range []string{"1", "2"} {
+		item, err := User.Create(bg(), ot.Item{"id": id, "secret": "s" + id, "profile": ot.Item{"token": "t" + id}}, nil)
+		if err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+		assertStr(t, item, "secret", "s"+id)
+	}
+	stored := func(id string) map[string]types.AttributeValue {
+		for _, item := range mock.tbl("CryptTable") {
+			if avStr(item["pk"]) == "user#"+id {
+				return item
+			}
+		}
+		return nil
+	}
+	if s := avStr(stored("1")["secret"]); cryptFormat(s) != 0x81 {
+		t.Errorf("secret not bound to the item: %s", s)
+	}
+	item, err := User.Get(bg(), ot.Item{"id": "1"}, nil)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	assertStr(t, item, "secret", "s1")
+	if profile, _ := item["profile"].(ot.Item); profile["token"] != "t1" {
+		t.Errorf("nested token not decrypted: %v", item["profile"])
+	}
+	item, _ = User.Get(bg(), ot.Item{"id": "1"}, &ot.Params{Fields: []string{"secret"}})
+	assertStr(t, item, "secret", "s1")
+
+	// values written without binding still decrypt
+	item, _ = User.Get(bg(), ot.Item{"id": "0"}, nil)
+	assertStr(t, item, "secret", "legacy")
+
+	// a ciphertext moved to another item does not decrypt
+	stored("1")["secret"] = stored("2")["secret"]
+	item, _ = User.Get(bg(), ot.Item{"id": "1"}, nil)
+	if item["secret"] == "s2" {
+		t.Error("relocated ciphertext decrypted")
+	}
+}
+
+func TestCrypt_Strict(t *testing.T) {
+	mock := newFullMock()
+	crypto := map[string]*ot.CryptoConfig{"primary": {Password: "first"}, "pii": {Password: "second"}}
+	unbound, _ := ot.NewTable(ot.TableParams{Name: "CryptTable", Client: mock, Schema: CryptSchema, Crypto: crypto})
+	User, _ := unbound.GetModel("User")
+	for _, id := range []string{"1", "2"} {
+		if _, err := User.Create(bg(), ot.Item{"id": id, "secret": "s" + id}, nil); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+	}
+	stored := func(id string) map[string]types.AttributeValue {
+		for _, item := range mock.tbl("CryptTable") {
+			if avStr(item["pk"]) == "user#"+id {
+				return item
+			}
+		}
+		return nil
+	}
+
+	// without CryptStrict a relocated unbound value decrypts in its new place
+	stored("1")["secret"] = stored("2")["secret"]
+	bound, _ := ot.NewTable(ot.TableParams{Name: "CryptTable", Client: mock, Schema: CryptSchema, Crypto: crypto, CryptBind: true})
+	User, _ = bound.GetModel("User")
+	item, _ := User.Get(bg(), ot.Item{"id": "1"}, nil)
+	assertStr(t, item, "secret", "s2")
+
+	strict, err := ot.NewTable(ot.TableParams{Name: "CryptTable", Client: mock, Schema: CryptSchema, Crypto: crypto,
+		CryptBind: true, CryptStrict: true})
+	if err != nil {
+		t.Fatalf("NewTable: %v", err)
+	}
+	User, _ = strict.GetModel("User")
+	item, _ = User.Get(bg(), ot.Item{"id": "1"}, nil)
+	if item["secret"] == "s2" {
+		t.Error("relocated unbound ciphertext decrypted")
+	}
+	stored("2")["secret"] = &types.AttributeValueMemberS{Value: legacyEncrypt(t, "primary", "first", "s2")}
+	item, _ = User.Get(bg(), ot.Item{"id": "2"}, nil)
+	if item["secret"] == "s2" {
+		t.Error("unbound legacy ciphertext decrypted")
+	}
+
+	// bound values still decrypt
+	if _, err := User.Create(bg(), ot.Item{"id": "3", "secret": "s3"}, nil); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	item, _ = User.Get(bg(), ot.Item{"id": "3"}, nil)
+	assertStr(t, item, "secret", "s3")
+}
+
+func TestCrypt_BindNumericKey(t *testing.T) {
+	schema := &ot.SchemaDef{
+		Version: "0.0.1",
+		Indexes: map[string]*ot.IndexDef{"primary": {Hash: "pk", Sort: "sk"}},
+		Models: map[string]ot.ModelDef{
+			"Entry": {
+				"pk":     {Type: ot.FieldTypeString, Value: "entry#${id}"},
+				"sk":     {Type: ot.FieldTypeNumber},
+				"id":     {Type: ot.FieldTypeString, Required: true},
+				"secret": {Type: ot.FieldTypeString, Crypt: true},
+			},
+		},
+	}
+	tbl, err := ot.NewTable(ot.TableParams{Name: "CryptTable", Client: newFullMock(), Schema: schema,
+		Crypto: map[string]*ot.CryptoConfig{"primary": {Password: "first"}}, CryptBind: true})
+	if err != nil {
+		t.Fatalf("NewTable: %v", err)
+	}
+	Entry, _ := tbl.GetModel("Entry")
+	// the key is an int when written and a float64 when read back
+	if _, err := Entry.Create(bg(), ot.Item{"id": "1", "sk": 12345678, "secret": "s1"}, nil); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	item, err := Entry.Get(bg(), ot.Item{"id": "1", "sk": 12345678}, nil)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	assertStr(t, item, "secret", "s1")
+}
+
+// cryptFormat returns the format byte of an encrypted value.
+func cryptFormat(s string) byte {
+	_, encoded, _ := strings.Cut(s, ":")
+	payload, err := base64.RawStdEncoding.DecodeString(encoded)
+	if err != nil || len(payload) == 0 {
+		return 0
+	}
+	return payload[0]
+}
+
+// legacyEncrypt encrypts text in the "name::hex-nonce:base64" format.
+func legacyEncrypt(t *testing.T, name, password, text string) string {
+	key := sha256.Sum256([]byte(password))
+	block, _ := aes.NewCipher(key[:])
+	gcm, _ := cipher.NewGCM(block)
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		t.Fatal(err)
+	}
+	sealed := gcm.Seal(nonce, nonce, []byte(text), nil)
+	return fmt.Sprintf("%s::%x:%s", name, nonce, base64.StdEncoding.EncodeToString(sealed))
+}
+
+func TestCrypt_ReEncrypt(t *testing.T) {
+	mock := newFullMock()
+	crypto := map[string]*ot.CryptoConfig{"primary": {Password: "first"}, "pii": {Password: "second"}}
+	tbl, _ := ot.NewTable(ot.TableParams{Name: "CryptTable", Client: mock, Schema: CryptSchema, Crypto: crypto})
+	User, _ := tbl.GetModel("User")
+	for _, id := range []string{"1", "2", "3"} {
+		if _, err := User.Create(bg(), ot.Item{"id": id, "secret": "s" + id, "ssn": "n" + id, "profile": ot.Item{"token": "t" + id}}, nil); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+	}
+	stored := func(id string) map[string]types.AttributeValue {
+		for _, item := range mock.tbl("CryptTable") {
+			if avStr(item["pk"]) == "user#"+id {
+				return item
+			}
+		}
+		return nil
+	}
+	// item 1 holds values of the legacy format, item 3 a plain value
+	stored("1")["secret"] = &types.AttributeValueMemberS{Value: legacyEncrypt(t, "primary", "first", "s1")}
+	stored("3")["secret"] = &types.AttributeValueMemberS{Value: "plain"}
+	item, err := User.Get(bg(), ot.Item{"id": "1"}, nil)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	assertStr(t, item, "secret", "s1")
+
+	// rotate to "v2" and bind values to their items
+	crypto["v2"] = &ot.CryptoConfig{Password: "third"}
+	tbl, _ = ot.NewTable(ot.TableParams{Name: "CryptTable", Client: mock, Schema: CryptSchema, Crypto: crypto,
+		CryptKey: "v2", CryptBind: true})
+	User, _ = tbl.GetModel("User")
+	before := avStr(stored("2")["created"])
+	count, err := User.ReEncrypt(bg(), nil)
+	if err != nil || count != 3 {
+		t.Fatalf("ReEncrypt: %d %v", count, err)
+	}
+	for _, id := range []string{"1", "2", "3"} {
+		raw := stored(id)
+		if s := avStr(raw["secret"]); !strings.HasPrefix(s, "v2:") || cryptFormat(s) != 0x81 {
+			t.Errorf("secret of %s not re-encrypted: %s", id, s)
+		}
+		if s := avStr(raw["ssn"]); !strings.HasPrefix(s, "pii:") || cryptFormat(s) != 0x81 {
+			t.Errorf("ssn of %s not re-encrypted: %s", id, s)
+		}
+		if profile, ok := raw["profile"].(*types.AttributeValueMemberM); !ok || cryptFormat(avStr(profile.Value["token"])) != 0x81 {
+			t.Errorf("nested token of %s not re-encrypted: %v", id, raw["profile"])
+		}
+		item, _ := User.Get(bg(), ot.Item{"id": id}, nil)
+		if profile, _ := item["profile"].(ot.Item); profile["token"] != "t"+id {
+			t.Errorf("nested token of %s: %v", id, item["profile"])
+		}
+	}
+	item, _ = User.Get(bg(), ot.Item{"id": "3"}, nil)
+	assertStr(t, item, "secret", "plain")
+	if after := avStr(stored("2")["created"]); after != before {
+		t.Errorf("timestamps changed: %s → %s", before, after)
+	}
+
+	// a second sweep has nothing to do; an undecryptable value is reported
+	stored("2")["secret"] = &types.AttributeValueMemberS{Value: legacyEncrypt(t, "primary", "wrong", "s2")}
+	count, err = User.ReEncrypt(bg(), nil)
+	if count != 0 {
+		t.Errorf("expected no rewrites, got %d", count)
+	}
+	assertErrCode(t, err, ot.ErrRuntime)
+}
+
+func TestCrypt_Config(t *testing.T) {
+	var argErr *ot.OneTableArgError
+	for _, params := range []ot.TableParams{
+		{Crypto: map[string]*ot.CryptoConfig{"primary": {Password: "pw", Cipher: "des"}}},
+		{Crypto: map[string]*ot.CryptoConfig{"primary": {Password: "pw"}}, CryptKey: "missing"},
+		{Crypto: map[string]*ot.CryptoConfig{"a:b": {Password: "pw"}}},
+		{CryptKey: "missing"},
+	} {
+		params.Name = "CryptTable"
+		params.Client = newFullMock()
+		if _, err := ot.NewTable(params); !errors.As(err, &argErr) {
+			t.Errorf("expected OneTableArgError, got %v", err)
+		}
+	}
+
+	defer func() {
+		if r := recover(); r == nil || !strings.Contains(r.(string), `"pii"`) {
+			t.Errorf("expected schema error for unknown crypto config, got %v", r)
+		}
+	}()
+	ot.NewTable(ot.TableParams{Name: "CryptTable", Client: newFullMock(), Schema: CryptSchema, //nolint
+		Crypto: map[string]*ot.CryptoConfig{"primary": {Password: "pw"}}})
+}
@@ -0,0 +1,157 @@
+package tests
+
+import (
+	"testing"
+	"time"
+
+	ot "github.com/cloudxsgmbh/dynamodb-onetable-go"
+)
+
+type decodedUser struct {
+	ID      string         `dynamodbav:"id"`
+	Name    string         `dynamodbav:"name"`
+	Email   string         `dynamodbav:"email"`
+	Age     int            `dynamodbav:"age"`
+	Profile map[string]any `dynamodbav:"profile"`
+	Created time.Time      `dynamodbav:"created"`
+	Status  string         // matched by field name
+}
+
+func TestDecode_GetAs(t *testing.T) {
+	tbl, _ := makeTable(t, "DecodeTable", DefaultSchema, false)
+	created, _ := tbl.Create(bg(), "User", ot.Item{
+		"name": "Peter Smith", "email": "peter@example.com", "age": 42,
+		"profile": ot.Item{"color": "blue"},
+	}, nil)
+	userModel, _ := tbl.GetModel("User")
+
+	var user decodedUser
+	found, err := userModel.GetAs(bg(), ot.Item{"id": created["id"]}, &user, nil)
+	if err != nil || !found {
+		t.Fatalf("GetAs: found=%v err=%v", found, err)
+	}
+	if user.ID != created["id"] || user.Name != "Peter Smith" || user.Age != 42 || user.Status != "idle" {
+		t.Errorf("unexpected decoded user: %+v", user)
+	}
+	if user.Profile["color"] != "blue" {
+		t.Errorf("expected nested profile, got %v", user.Profile)
+	}
+	if user.Created.IsZero() {
+		t.Error("expected created date")
+	}
+
+	found, err = userModel.GetAs(bg(), ot.Item{"id": "missing"}, &user, nil)
+	if err != nil || found {
+		t.Errorf("expected not found, got found=%v err=%v", found, err)
+	}
+}
+
+func TestDecode_Result(t *testing.T) {
+	tbl, _ := makeTable(t, "DecodeTable", DefaultSchema, false)
+	for _, d := range batchData {
+		tbl.Create(bg(), "User", d, nil) //nolint
+	}
+	result, err := tbl.Scan(bg(), "User", ot.Item{}, nil)
+	if err != nil {
+		t.Fatalf("Scan: %v", err)
+	}
+	var users []decodedUser
+	if err := result.Decode(&users); err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if len(users) != len(batchData) {
+		t.Fatalf("expected %d users, got %d", len(batchData), len(users))
+	}
+	for _, u := range users {
+		if u.ID == "" || u.Email == "" {
+			t.Errorf("incomplete decoded user: %+v", u)
+		}
+	}
+}
+
+func TestDecode_InvalidDest(t *testing.T) {
+	result := &ot.Result{Items: []ot.Item{{"name": "x"}}}
+	var user decodedUser
+	if err := result.Decode(&user); err == nil {
+		t.Error("expected error decoding a result into a struct")
+	}
+	if err := ot.DecodeItem(ot.Item{"name": "x"}, user); err == nil {
+		t.Error("expected error for non-pointer destination")
+	}
+}
+
+type decodedLocation struct {
+	City    string    `onetable:"city"`
+	Since   time.Time `onetable:"started"`
+	Unknown string    `onetable:"-"`
+}
+
+type decodedNestedUser struct {
+	Name     string          `onetable:"name"`
+	Tokens   []string        `onetable:"tokens"`
+	Started  time.Time       `onetable:"started"`
+	Location decodedLocation `onetable:"location"`
+	Balance  float64         `dynamodbav:"balance"`
+}
+
+func TestDecode_NestedSchemaTags(t *testing.T) {
+	tbl, _ := makeTable(t, "DecodeTable", NestedSchema, false)
+	started := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	created, err := tbl.Create(bg(), "User", ot.Item{
+		"name":     "Peter Smith",
+		"balance":  float64(12.5),
+		"tokens":   []any{"red", "blue"},
+		"started":  started,
+		"location": ot.Item{"city": "Seattle", "started": started},
+	}, nil)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	userModel, _ := tbl.GetModel("User")
+	var user decodedNestedUser
+	if _, err := userModel.GetAs(bg(), ot.Item{"id": created["id"]}, &user, nil); err != nil {
+		t.Fatalf("GetAs: %v", err)
+	}
+	if user.Name != "Peter Smith" || user.Balance != 12.5 || len(user.Tokens) != 2 {
+		t.Errorf("unexpected decoded user: %+v", user)
+	}
+	if !user.Started.Equal(started) || !user.Location.Since.Equal(started) {
+		t.Errorf("dates not decoded: %v / %v", user.Started, user.Location.Since)
+	}
+	if user.Location.City != "Seattle" {
+		t.Errorf("nested city: got %q", user.Location.City)
+	}
+}
+
+func TestDecode_CustomTag(t *testing.T) {
+	tbl, err := ot.NewTable(ot.TableParams{Name: "DecodeTable", Client: newFullMock(), Schema: DefaultSchema, DecodeTag: "json"})
+	if err != nil {
+		t.Fatalf("NewTable: %v", err)
+	}
+	tbl.Create(bg(), "User", ot.Item{"name": "Peter Smith", "email": "peter@example.com"}, nil) //nolint
+	result, err := tbl.Scan(bg(), "User", ot.Item{}, nil)
+	if err != nil {
+		t.Fatalf("Scan: %v", err)
+	}
+	var users []struct {
+		Mail string `json:"email"`
+	}
+	if err := result.Decode(&users); err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if len(users) != 1 || users[0].Mail != "peter@example.com" {
+		t.Errorf("json tag not honoured: %+v", users)
+	}
+}
+
+func TestDecode_EpochMillisDate(t *testing.T) {
+	var out struct {
+		When time.Time `onetable:"when"`
+	}
+	if err := ot.DecodeItem(ot.Item{"when": float64(1714564800000)}, &out); err != nil {
+		t.Fatalf("DecodeItem: %v", err)
+	}
+	if want := time.UnixMilli(1714564800000).UTC(); !out.When.Equal(want) {
+		t.Errorf("expected %v, got %v", want, out.When)
+	}
+}
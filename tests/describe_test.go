@@ -0,0 +1,212 @@
+package tests
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	ot "github.com/cloudxsgmbh/dynamodb-onetable-go"
+)
+
+var ShopSchema = &ot.SchemaDef{
+	Name:    "Shop",
+	Version: "1.0.0",
+	Indexes: map[string]*ot.IndexDef{
+		"primary": {Hash: "pk", Sort: "sk"},
+		"gs1":     {Hash: "gs1pk", Sort: "gs1sk", Project: "keys"},
+		"ls1":     {Sort: "ls1sk"},
+	},
+	Models: map[string]ot.ModelDef{
+		"Account": {
+			"pk":    {Type: ot.FieldTypeString, Value: "account#${id}"},
+			"sk":    {Type: ot.FieldTypeString, Value: "account#"},
+			"id":    {Type: ot.FieldTypeString, Generate: "ulid"},
+			"email": {Type: ot.FieldTypeString, Required: true, Unique: true, Validate: "/^a|b$/"},
+			"address": {Type: ot.FieldTypeObject, Schema: ot.FieldMap{
+				"city": {Type: ot.FieldTypeString, Required: true},
+			}},
+			"gs1pk": {Type: ot.FieldTypeString, Value: "email#${email}"},
+			"gs1sk": {Type: ot.FieldTypeString, Value: "account#"},
+		},
+		"Order": {
+			"pk":     {Type: ot.FieldTypeString, Value: "account#${accountId}"},
+			"sk":     {Type: ot.FieldTypeString, Value: "order#${id}"},
+			"id":     {Type: ot.FieldTypeString, Generate: "ulid"},
+			"total":  {Type: ot.FieldTypeNumber, Map: "data.total"},
+			"placed": {Type: ot.FieldTypeDate, Map: "ls1sk"},
+		},
+	},
+}
+
+func TestDescribeSchema(t *testing.T) {
+	desc, err := ot.DescribeSchema(ShopSchema)
+	if err != nil {
+		t.Fatalf("DescribeSchema: %v", err)
+	}
+	if desc.Name != "Shop" || desc.Version != "1.0.0" {
+		t.Errorf("unexpected name/version %q %q", desc.Name, desc.Version)
+	}
+
+	indexes := map[string]ot.IndexDescription{}
+	var order []string
+	for _, idx := range desc.Indexes {
+		indexes[idx.Name] = idx
+		order = append(order, idx.Name)
+	}
+	if !slices.Equal(order, []string{"primary", "gs1", "ls1"}) {
+		t.Errorf("unexpected index order %v", order)
+	}
+	if !slices.Equal(indexes["primary"].Models, []string{"Account", "Order"}) {
+		t.Errorf("primary models = %v", indexes["primary"].Models)
+	}
+	if !slices.Equal(indexes["gs1"].Models, []string{"Account"}) || indexes["gs1"].Project != "keys" {
+		t.Errorf("gs1 = %+v", indexes["gs1"])
+	}
+	if ls1 := indexes["ls1"]; ls1.Type != "local" || ls1.Hash != "pk" || !slices.Equal(ls1.Models, []string{"Order"}) {
+		t.Errorf("ls1 = %+v", ls1)
+	}
+
+	account := desc.Models[0]
+	if account.Name != "Account" || !slices.Equal(account.Unique, []string{"email"}) {
+		t.Errorf("account = %+v", account)
+	}
+	if k := account.Keys[0]; k.Index != "primary" || k.Hash != "account#${id}" || k.Sort != "account#" {
+		t.Errorf("account primary key = %+v", k)
+	}
+	order2 := desc.Models[1]
+	if k := order2.Keys[1]; k.Index != "ls1" || k.Sort != "${placed}" {
+		t.Errorf("order ls1 key = %+v", k)
+	}
+
+	md := desc.Markdown()
+	for _, want := range []string{
+		"# Shop (version 1.0.0)",
+		"| gs1 | global | `gs1pk` | `gs1sk` | keys | Account |",
+		"### Account",
+		"| primary | `account#${id}` | `account#` |",
+		"Unique: email",
+		"| `address.city` | string | yes |",
+		"validate `/^a\\|b$/`; unique",
+		"| `total` | number |  | map `data.total` |",
+	} {
+		assertContains(t, md, want)
+	}
+
+	var argErr *ot.OneTableArgError
+	if _, err := ot.DescribeSchema(&ot.SchemaDef{}); !errors.As(err, &argErr) {
+		t.Errorf("expected OneTableArgError for a schema without primary index, got %v", err)
+	}
+}
+
+func TestCheckAccessPatterns(t *testing.T) {
+	schema := *ShopSchema
+	schema.Queries = map[string]any{
+		"accountById":     ot.AccessPattern{Model: "Account", Keys: []string{"id"}},
+		"accountByEmail":  ot.AccessPattern{Model: "Account", Index: "gs1", Keys: []string{"email"}},
+		"ordersByAccount": map[string]any{"model": "Order", "keys": []any{"accountId"}},
+		"orderById":       map[string]any{"model": "Order", "keys": []any{"accountId", "id"}},
+		"ordersByPlaced":  ot.AccessPattern{Model: "Order", Index: "ls1", Keys: []string{"accountId", "placed"}},
+		"savedQuery":      map[string]any{"hash": "account#1", "index": "primary", "type": "Query"},
+	}
+	if err := ot.CheckAccessPatterns(&schema); err != nil {
+		t.Fatalf("CheckAccessPatterns: %v", err)
+	}
+
+	schema.Queries = map[string]any{
+		"ordersByTotal":   ot.AccessPattern{Model: "Order", Keys: []string{"accountId", "total"}},
+		"accountByEmail":  ot.AccessPattern{Model: "Account", Keys: []string{"email"}},
+		"ordersByPlaced":  ot.AccessPattern{Model: "Order", Index: "gs1", Keys: []string{"accountId"}},
+		"missingModel":    ot.AccessPattern{Model: "Invoice", Keys: []string{"id"}},
+		"accountByIdOkay": ot.AccessPattern{Model: "Account", Keys: []string{"id"}},
+	}
+	err := ot.CheckAccessPatterns(&schema)
+	assertErrCode(t, err, ot.ErrValidation)
+	problems, _ := err.(*ot.OneTableError).Context["patterns"].(map[string]string)
+	if len(problems) != 4 {
+		t.Fatalf("expected 4 broken patterns, got %v", problems)
+	}
+	assertContains(t, problems["ordersByTotal"], `"total" is not part of the key`)
+	assertContains(t, problems["accountByEmail"], `needs "id"`)
+	assertContains(t, problems["ordersByPlaced"], "does not populate")
+	assertContains(t, problems["missingModel"], "unknown model")
+
+	// the configured type field is resolved from the model name
+	kinds := &ot.SchemaDef{
+		Version: "0.0.1",
+		Indexes: map[string]*ot.IndexDef{"primary": {Hash: "pk", Sort: "sk"}},
+		Models: map[string]ot.ModelDef{
+			"Account": {
+				"pk": {Type: ot.FieldTypeString, Value: "${kind}#${id}"},
+				"sk": {Type: ot.FieldTypeString, Value: "${kind}#"},
+				"id": {Type: ot.FieldTypeString},
+			},
+		},
+		Params:  &ot.SchemaParams{TypeField: "kind"},
+		Queries: map[string]any{"accountById": ot.AccessPattern{Model: "Account", Keys: []string{"id"}}},
+	}
+	if err := ot.CheckAccessPatterns(kinds); err != nil {
+		t.Errorf("CheckAccessPatterns with a custom type field: %v", err)
+	}
+}
+
+func TestIAMStatements(t *testing.T) {
+	arn := "arn:aws:dynamodb:eu-west-1:123456789012:table/Shop"
+	statements, err := ot.IAMStatements(ShopSchema, ot.IAMParams{TableARN: arn})
+	if err != nil {
+		t.Fatalf("IAMStatements: %v", err)
+	}
+	if len(statements) != 2 {
+		t.Fatalf("expected table and index statements, got %+v", statements)
+	}
+	table := statements[0]
+	for _, action := range []string{"dynamodb:GetItem", "dynamodb:PutItem", "dynamodb:Query", "dynamodb:ConditionCheckItem"} {
+		if !slices.Contains(table.Actions, action) {
+			t.Errorf("expected %s in %v", action, table.Actions)
+		}
+	}
+	if slices.Contains(table.Actions, "dynamodb:CreateTable") || slices.Contains(table.Actions, "dynamodb:DescribeTable") {
+		t.Errorf("unexpected DDL actions %v", table.Actions)
+	}
+	if !slices.Equal(statements[1].Resources, []string{arn + "/index/gs1", arn + "/index/ls1"}) {
+		t.Errorf("unexpected index resources %v", statements[1].Resources)
+	}
+	// Scan with Params.Index reads the index
+	if !slices.Equal(statements[1].Actions, []string{"dynamodb:Query", "dynamodb:Scan"}) {
+		t.Errorf("unexpected index actions %v", statements[1].Actions)
+	}
+
+	statements, _ = ot.IAMStatements(ShopSchema, ot.IAMParams{TableARN: arn, ReadOnly: true})
+	if slices.Contains(statements[0].Actions, "dynamodb:PutItem") {
+		t.Errorf("read-only statement grants writes: %v", statements[0].Actions)
+	}
+
+	statements, _ = ot.IAMStatements(nil, ot.IAMParams{TableARN: arn, DDL: true})
+	if !slices.Contains(statements[0].Actions, "dynamodb:DescribeTable") || !slices.Contains(statements[0].Actions, "dynamodb:CreateTable") {
+		t.Errorf("expected DDL actions, got %v", statements[0].Actions)
+	}
+	if statements[1].Resources[0] != arn+"/index/*" || statements[2].Resources[0] != "*" {
+		t.Errorf("unexpected resources %+v", statements)
+	}
+
+	// TTL fields need UpdateTimeToLive to enable expiry
+	if slices.Contains(statements[0].Actions, "dynamodb:UpdateTimeToLive") {
+		t.Errorf("UpdateTimeToLive granted without a TTL field: %v", statements[0].Actions)
+	}
+	statements, _ = ot.IAMStatements(SessionSchema, ot.IAMParams{TableARN: arn, DDL: true})
+	if !slices.Contains(statements[0].Actions, "dynamodb:UpdateTimeToLive") {
+		t.Errorf("expected UpdateTimeToLive for a TTL field, got %v", statements[0].Actions)
+	}
+	statements, _ = ot.IAMStatements(SessionSchema, ot.IAMParams{TableARN: arn, DDL: true, ReadOnly: true})
+	if slices.Contains(statements[0].Actions, "dynamodb:UpdateTimeToLive") {
+		t.Errorf("read-only statement grants UpdateTimeToLive: %v", statements[0].Actions)
+	}
+
+	policy, err := ot.IAMPolicy(ShopSchema, ot.IAMParams{TableARN: arn})
+	if err != nil || policy["Version"] != "2012-10-17" {
+		t.Errorf("unexpected policy %v %v", policy, err)
+	}
+	var argErr *ot.OneTableArgError
+	if _, err := ot.IAMStatements(ShopSchema, ot.IAMParams{}); !errors.As(err, &argErr) {
+		t.Errorf("expected OneTableArgError without TableARN, got %v", err)
+	}
+}
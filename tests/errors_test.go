@@ -0,0 +1,83 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	ddb "github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	ot "github.com/cloudxsgmbh/dynamodb-onetable-go"
+)
+
+// failMock fails GetItem and TransactWriteItems with err.
+type failMock struct {
+	*fullMock
+	err error
+}
+
+func (m *failMock) GetItem(context.Context, *ddb.GetItemInput, ...func(*ddb.Options)) (*ddb.GetItemOutput, error) {
+	return nil, m.err
+}
+
+func (m *failMock) TransactWriteItems(context.Context, *ddb.TransactWriteItemsInput, ...func(*ddb.Options)) (*ddb.TransactWriteItemsOutput, error) {
+	return nil, m.err
+}
+
+func canceled(codes ...string) error {
+	reasons := make([]types.CancellationReason, len(codes))
+	for i, code := range codes {
+		reasons[i].Code = aws.String(code)
+	}
+	return &types.TransactionCanceledException{Message: aws.String("canceled"), CancellationReasons: reasons}
+}
+
+func TestErrors_Retryable(t *testing.T) {
+	for _, tc := range []struct {
+		err       error
+		code      ot.ErrorCode
+		retryable bool
+	}{
+		{&types.ProvisionedThroughputExceededException{Message: aws.String("slow down")}, ot.ErrThrottling, true},
+		{&types.RequestLimitExceeded{Message: aws.String("slow down")}, ot.ErrThrottling, true},
+		{&types.LimitExceededException{Message: aws.String("too many")}, ot.ErrLimitExceeded, true},
+		{&types.TransactionConflictException{Message: aws.String("conflict")}, ot.ErrTransactionConflict, true},
+		{canceled("None", "TransactionConflict"), ot.ErrTransactionConflict, true},
+		{canceled("ConditionalCheckFailed", "TransactionConflict"), ot.ErrRuntime, false},
+		{fmt.Errorf("send: %w", context.DeadlineExceeded), ot.ErrTimeout, true},
+		{&types.ResourceNotFoundException{Message: aws.String("no table")}, ot.ErrRuntime, false},
+	} {
+		mock := &failMock{fullMock: newFullMock(), err: tc.err}
+		tbl, err := ot.NewTable(ot.TableParams{Name: "ErrTable", Client: mock, Schema: DefaultSchema})
+		if err != nil {
+			t.Fatalf("NewTable: %v", err)
+		}
+		_, err = tbl.Get(bg(), "User", ot.Item{"id": "42"}, nil)
+		if _, ok := tc.err.(*types.TransactionCanceledException); ok {
+			tx := map[string]any{}
+			tbl.Create(bg(), "User", ot.Item{"name": "Peter Smith"}, &ot.Params{Transaction: tx}) //nolint
+			_, err = tbl.Transact(bg(), "write", tx, nil)
+		}
+		assertErrCode(t, err, tc.code)
+		if ot.IsRetryable(err) != tc.retryable || ot.IsRetryable(tc.err) != tc.retryable {
+			t.Errorf("%T: expected IsRetryable %v", tc.err, tc.retryable)
+		}
+	}
+	if ot.IsRetryable(nil) || ot.IsRetryable(errors.New("boom")) || ot.IsRetryable(ot.NewArgError("bad")) {
+		t.Error("unexpected retryable error")
+	}
+}
+
+func TestErrors_UniqueConflict(t *testing.T) {
+	// a conflicting transaction is not a unique violation
+	mock := &failMock{fullMock: newFullMock(), err: canceled("TransactionConflict", "None")}
+	tbl, err := ot.NewTable(ot.TableParams{Name: "UniqueTable", Client: mock, Schema: UniqueSchema})
+	if err != nil {
+		t.Fatalf("NewTable: %v", err)
+	}
+	_, err = tbl.Create(bg(), "User", ot.Item{"name": "Judy Smith", "email": "judy@example.com"}, nil)
+	assertErrCode(t, err, ot.ErrTransactionConflict)
+}
@@ -2,6 +2,9 @@
 package tests
 
 import (
+	"cmp"
+	"errors"
+	"strings"
 	"testing"
 
 	ot "github.com/cloudxsgmbh/dynamodb-onetable-go"
@@ -133,3 +136,210 @@ func TestFind_SelectCount(t *testing.T) {
 	}
 	_ = result
 }
+
+func TestFind_SelectParams(t *testing.T) {
+	tbl, _ := setupFindTable(t)
+
+	// counting returns no items, also on a followed index query
+	for name, params := range map[string]*ot.Params{
+		"count":  {Count: true},
+		"select": {Select: "count"},
+		"follow": {Count: true, Index: "gs1", Follow: truePtr()},
+	} {
+		params.Index = cmp.Or(params.Index, "gs1")
+		result, err := tbl.Find(bg(), "User", ot.Item{"name": "Peter Smith"}, params)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if result.Items != nil || result.Count != 1 {
+			t.Errorf("%s: expected count 1 and no items, got %d %v", name, result.Count, result.Items)
+		}
+	}
+	result, err := tbl.Scan(bg(), "User", ot.Item{}, &ot.Params{Count: true})
+	if err != nil {
+		t.Fatalf("Scan count: %v", err)
+	}
+	if result.Items != nil || result.Count != len(findData) {
+		t.Errorf("expected scan count %d and no items, got %d %v", len(findData), result.Count, result.Items)
+	}
+
+	noExec := false
+	cmd, err := tbl.Find(bg(), "User", ot.Item{"name": "Peter Smith"},
+		&ot.Params{Index: "gs1", Select: "all_projected_attributes", Execute: &noExec})
+	if err != nil {
+		t.Fatalf("Find ALL_PROJECTED_ATTRIBUTES: %v", err)
+	}
+	if cmd.Items[0]["Select"] != "ALL_PROJECTED_ATTRIBUTES" {
+		t.Errorf("unexpected Select %v", cmd.Items[0]["Select"])
+	}
+
+	var argErr *ot.OneTableArgError
+	for name, params := range map[string]*ot.Params{
+		"unknown":              {Select: "SOME"},
+		"count and select":     {Count: true, Select: "ALL_ATTRIBUTES"},
+		"count and fields":     {Count: true, Fields: []string{"name"}},
+		"specific no fields":   {Select: "SPECIFIC_ATTRIBUTES"},
+		"all with fields":      {Select: "ALL_ATTRIBUTES", Fields: []string{"name"}},
+		"projected on primary": {Select: "ALL_PROJECTED_ATTRIBUTES"},
+	} {
+		_, err := tbl.Scan(bg(), "User", ot.Item{}, params)
+		if !errors.As(err, &argErr) {
+			t.Errorf("%s: expected OneTableArgError, got %v", name, err)
+		}
+	}
+}
+
+func TestScan_RequireKeyCondition(t *testing.T) {
+	mock := newFullMock()
+	tbl, err := ot.NewTable(ot.TableParams{Name: "FindTable", Client: mock, Schema: DefaultSchema, RequireKeyCondition: true})
+	if err != nil {
+		t.Fatalf("NewTable: %v", err)
+	}
+	user, err := tbl.Create(bg(), "User", findData[0], nil)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	result, err := tbl.Find(bg(), "User", ot.Item{"id": user["id"]}, nil)
+	if err != nil {
+		t.Fatalf("Find with key condition: %v", err)
+	}
+	assertLen(t, result.Items, 1)
+
+	_, err = tbl.Scan(bg(), "User", nil, nil)
+	assertErrCode(t, err, ot.ErrArgument)
+	_, err = tbl.ScanItems(bg(), nil, nil)
+	assertErrCode(t, err, ot.ErrArgument)
+
+	result, err = tbl.Scan(bg(), "User", nil, &ot.Params{RequireKeyCondition: falsePtr()})
+	if err != nil {
+		t.Fatalf("Scan with opt-in: %v", err)
+	}
+	assertLen(t, result.Items, 1)
+}
+
+func TestFind_ExistsFilter(t *testing.T) {
+	tbl, _ := makeTable(t, "FindTable", DefaultSchema, false)
+	tbl.Create(bg(), "User", ot.Item{"name": "Ann", "email": "ann@example.com", "status": "active"}, nil)
+	tbl.Create(bg(), "User", ot.Item{"name": "Bob", "status": "active"}, nil)
+	tbl.Create(bg(), "User", ot.Item{"name": "Cid", "status": "active", "age": float64(40)}, nil)
+
+	result, err := tbl.Scan(bg(), "User", ot.Item{"email": ot.NotExists()}, nil)
+	if err != nil {
+		t.Fatalf("Scan NotExists: %v", err)
+	}
+	assertLen(t, result.Items, 2)
+
+	result, err = tbl.Scan(bg(), "User", ot.Item{"email": ot.Exists()}, nil)
+	if err != nil {
+		t.Fatalf("Scan Exists: %v", err)
+	}
+	assertLen(t, result.Items, 1)
+	assertStr(t, result.Items[0], "name", "Ann")
+
+	// map form, combined with a value filter, on a GSI query
+	result, err = tbl.Find(bg(), "User", ot.Item{"status": "active", "age": map[string]any{"exists": true}}, &ot.Params{Index: "gs3"})
+	if err != nil {
+		t.Fatalf("Find exists map: %v", err)
+	}
+	assertLen(t, result.Items, 1)
+	assertStr(t, result.Items[0], "name", "Cid")
+
+	noExec := false
+	cmd, _ := tbl.Scan(bg(), "User", ot.Item{"email": ot.NotExists()}, &ot.Params{Execute: &noExec})
+	assertContains(t, cmd.Items[0]["FilterExpression"].(string), "attribute_not_exists(#_")
+
+	// filters on key fields are argument errors
+	var argErr *ot.OneTableArgError
+	_, err = tbl.Find(bg(), "User", ot.Item{"pk": "User#x", "sk": ot.Exists()}, &ot.Params{Execute: &noExec})
+	if !errors.As(err, &argErr) || !strings.Contains(err.Error(), "exists filter") {
+		t.Errorf("expected error for an exists filter on a key field, got %v", err)
+	}
+	_, err = tbl.Find(bg(), "User", ot.Item{"pk": "User#x", "sk": ot.Contains("x")}, &ot.Params{Execute: &noExec})
+	if !errors.As(err, &argErr) || !strings.Contains(err.Error(), "filter on key field") {
+		t.Errorf("expected error for a filter on a key field, got %v", err)
+	}
+}
+
+func TestFind_ArrayFilters(t *testing.T) {
+	schema := &ot.SchemaDef{
+		Version: "0.0.1",
+		Indexes: map[string]*ot.IndexDef{"primary": {Hash: "pk", Sort: "sk"}},
+		Models: map[string]ot.ModelDef{
+			"User": {
+				"pk":   {Type: ot.FieldTypeString, Value: "user"},
+				"sk":   {Type: ot.FieldTypeString, Value: "user#${name}"},
+				"name": {Type: ot.FieldTypeString, Required: true},
+				"tags": {Type: ot.FieldTypeArray},
+				"addresses": {Type: ot.FieldTypeArray, Items: &ot.ItemsDef{Schema: ot.FieldMap{
+					"street": {Type: ot.FieldTypeString},
+					"zip":    {Type: ot.FieldTypeNumber},
+				}}},
+			},
+		},
+	}
+	tbl, _ := makeTable(t, "FindTable", schema, false)
+	for _, user := range []ot.Item{
+		{"name": "Ann", "tags": []any{"admin", "ops"}, "addresses": []any{
+			ot.Item{"street": "1 Main St", "zip": 3000}, ot.Item{"street": "2 Side St", "zip": 4000}}},
+		{"name": "Bob", "tags": []any{"ops"}, "addresses": []any{ot.Item{"street": "3 Main St", "zip": 5000}}},
+		{"name": "Cid"},
+	} {
+		if _, err := tbl.Create(bg(), "User", user, nil); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+	}
+	names := func(result *ot.Result) string {
+		var out []string
+		for _, item := range result.Items {
+			out = append(out, item["name"].(string))
+		}
+		return strings.Join(out, ",")
+	}
+
+	// server-side
+	result, err := tbl.Find(bg(), "User", ot.Item{"tags": ot.Contains("admin")}, nil)
+	if err != nil {
+		t.Fatalf("Find Contains: %v", err)
+	}
+	if got := names(result); got != "Ann" {
+		t.Errorf("Contains: %s", got)
+	}
+	noExec := false
+	cmd, _ := tbl.Find(bg(), "User", ot.Item{"tags": ot.Contains("ops")}, &ot.Params{Execute: &noExec})
+	assertContains(t, cmd.Items[0]["FilterExpression"].(string), "contains(#_")
+
+	// client-side
+	for _, tc := range []struct {
+		match ot.Item
+		want  string
+	}{
+		{ot.Item{"zip": 4000}, "Ann"},
+		{ot.Item{"zip": 3000, "street": "2 Side St"}, ""},
+		{ot.Item{"street": "3 Main St"}, "Bob"},
+	} {
+		result, err = tbl.Find(bg(), "User", ot.Item{"addresses": ot.AnyElement(tc.match)}, nil)
+		if err != nil {
+			t.Fatalf("Find AnyElement: %v", err)
+		}
+		if got := names(result); got != tc.want {
+			t.Errorf("AnyElement %v: got %q, want %q", tc.match, got, tc.want)
+		}
+	}
+	result, err = tbl.Scan(bg(), "User", ot.Item{"addresses": ot.AnyElement(ot.Item{"zip": 5000})}, nil)
+	if err != nil {
+		t.Fatalf("Scan AnyElement: %v", err)
+	}
+	if got := names(result); got != "Bob" {
+		t.Errorf("Scan AnyElement: %s", got)
+	}
+
+	var argErr *ot.OneTableArgError
+	if _, err := tbl.Find(bg(), "User", ot.Item{"name": ot.AnyElement(ot.Item{"zip": 5000})}, nil); !errors.As(err, &argErr) {
+		t.Errorf("expected OneTableArgError for AnyElement on a string field, got %v", err)
+	}
+	for _, params := range []*ot.Params{{Count: true}, {Fields: []string{"name"}}} {
+		if _, err := tbl.Find(bg(), "User", ot.Item{"addresses": ot.AnyElement(ot.Item{"zip": 5000})}, params); !errors.As(err, &argErr) {
+			t.Errorf("%+v: expected OneTableArgError, got %v", params, err)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package tests
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	ot "github.com/cloudxsgmbh/dynamodb-onetable-go"
+)
+
+var FixtureSchema = &ot.SchemaDef{
+	Version: "0.0.1",
+	Indexes: map[string]*ot.IndexDef{
+		"primary": {Hash: "pk", Sort: "sk"},
+	},
+	Models: map[string]ot.ModelDef{
+		"User": {
+			"pk":     {Type: ot.FieldTypeString, Value: "user#${id}"},
+			"sk":     {Type: ot.FieldTypeString, Value: "user#"},
+			"id":     {Type: ot.FieldTypeString, Required: true},
+			"name":   {Type: ot.FieldTypeString},
+			"age":    {Type: ot.FieldTypeNumber},
+			"joined": {Type: ot.FieldTypeDate},
+		},
+	},
+	Params: &ot.SchemaParams{Timestamps: true},
+}
+
+func TestFixtures_Load(t *testing.T) {
+	tbl, mock := makeTable(t, "FixtureTable", FixtureSchema, false)
+	User, _ := tbl.GetModel("User")
+
+	// items grouped by model, as read with hidden fields by JS OneTable
+	fixture := `{"User": [
+		{"pk": "user#1", "sk": "user#", "id": "1", "name": "Ann", "age": 42,
+		 "joined": "2024-02-01T10:00:00.000Z", "created": "2024-02-01T10:00:00.000Z"}
+	]}`
+	count, err := tbl.LoadFixtures(bg(), strings.NewReader(fixture), nil)
+	if err != nil || count != 1 {
+		t.Fatalf("LoadFixtures: %d %v", count, err)
+	}
+	raw := mock.tbl("FixtureTable")["user#1||user#"]
+	if raw == nil {
+		t.Fatal("fixture item not stored")
+	}
+	if _, ok := raw["joined"].(*types.AttributeValueMemberN); !ok {
+		t.Errorf("ISO date not stored as epoch: %#v", raw["joined"])
+	}
+	if typ := avStr(raw["_type"]); typ != "User" {
+		t.Errorf("type field = %q, want User", typ)
+	}
+
+	item, err := User.Get(bg(), ot.Item{"id": "1"}, nil)
+	if err != nil || item == nil {
+		t.Fatalf("Get: %v %v", item, err)
+	}
+	assertStr(t, item, "name", "Ann")
+	assertNum(t, item, "age", 42)
+	want := time.Date(2024, 2, 1, 10, 0, 0, 0, time.UTC)
+	if joined, _ := item["joined"].(time.Time); !joined.Equal(want) {
+		t.Errorf("joined = %v, want %v", item["joined"], want)
+	}
+
+	// a Scan export of typed attribute maps spans several batches
+	var items []string
+	for i := range 30 {
+		items = append(items, fmt.Sprintf(`{"pk": {"S": "user#t%d"}, "sk": {"S": "user#"}, "id": {"S": "t%d"},
+			"_type": {"S": "User"}, "age": {"N": "%d"}, "tags": {"SS": ["a", "b"]}}`, i, i, i))
+	}
+	fixture = `{"Items": [` + strings.Join(items, ",") + `], "Count": 30}`
+	count, err = tbl.LoadFixtures(bg(), strings.NewReader(fixture), nil)
+	if err != nil || count != 30 {
+		t.Fatalf("LoadFixtures typed: %d %v", count, err)
+	}
+	if _, ok := mock.tbl("FixtureTable")["user#t7||user#"]["tags"].(*types.AttributeValueMemberSS); !ok {
+		t.Error("typed string set not preserved")
+	}
+	item, _ = User.Get(bg(), ot.Item{"id": "t7"}, nil)
+	assertNum(t, item, "age", 7)
+
+	// items must carry the primary key attributes
+	var argErr *ot.OneTableArgError
+	for _, fixture := range []string{`[{"pk": "user#2", "id": "2"}]`, `{"User": {"id": "2"}}`} {
+		if _, err := tbl.LoadFixtures(bg(), strings.NewReader(fixture), nil); !errors.As(err, &argErr) {
+			t.Errorf("expected OneTableArgError for %s, got %v", fixture, err)
+		}
+	}
+}
@@ -0,0 +1,582 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	ddb "github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	ot "github.com/cloudxsgmbh/dynamodb-onetable-go"
+)
+
+// describeMock reports a table with numeric primary keys and a GSI with a
+// numeric sort key.
+type describeMock struct {
+	*fullMock
+}
+
+func (m *describeMock) DescribeTable(_ context.Context, p *ddb.DescribeTableInput, _ ...func(*ddb.Options)) (*ddb.DescribeTableOutput, error) {
+	return &ddb.DescribeTableOutput{Table: &types.TableDescription{
+		TableName: p.TableName,
+		AttributeDefinitions: []types.AttributeDefinition{
+			{AttributeName: aws.String("pk"), AttributeType: types.ScalarAttributeTypeN},
+			{AttributeName: aws.String("sk"), AttributeType: types.ScalarAttributeTypeN},
+			{AttributeName: aws.String("gs1pk"), AttributeType: types.ScalarAttributeTypeS},
+			{AttributeName: aws.String("gs1sk"), AttributeType: types.ScalarAttributeTypeN},
+		},
+		KeySchema: []types.KeySchemaElement{
+			{AttributeName: aws.String("pk"), KeyType: types.KeyTypeHash},
+			{AttributeName: aws.String("sk"), KeyType: types.KeyTypeRange},
+		},
+		GlobalSecondaryIndexes: []types.GlobalSecondaryIndexDescription{{
+			IndexName: aws.String("gs1"),
+			KeySchema: []types.KeySchemaElement{
+				{AttributeName: aws.String("gs1pk"), KeyType: types.KeyTypeHash},
+				{AttributeName: aws.String("gs1sk"), KeyType: types.KeyTypeRange},
+			},
+		}},
+	}}, nil
+}
+
+func assertNumberAttr(t *testing.T, item map[string]types.AttributeValue, att string) {
+	t.Helper()
+	if _, ok := item[att].(*types.AttributeValueMemberN); !ok {
+		t.Errorf("expected %s stored as N, got %T", att, item[att])
+	}
+}
+
+func TestGeneric_DiscoveredNumericKeys(t *testing.T) {
+	mock := &describeMock{fullMock: newFullMock()}
+	tbl, err := ot.NewTable(ot.TableParams{Name: "GenericTable", Client: mock})
+	if err != nil {
+		t.Fatalf("NewTable: %v", err)
+	}
+	if _, err := tbl.PutItem(bg(), ot.Item{"pk": 7, "sk": 1, "gs1pk": "sensor", "gs1sk": 3}, nil); err != nil {
+		t.Fatalf("PutItem: %v", err)
+	}
+	for _, item := range mock.tbl("GenericTable") {
+		assertNumberAttr(t, item, "pk")
+		assertNumberAttr(t, item, "sk")
+		assertNumberAttr(t, item, "gs1sk")
+	}
+
+	got, err := tbl.GetItem(bg(), ot.Item{"pk": 7, "sk": "1"}, &ot.Params{Parse: true})
+	if err != nil || got == nil {
+		t.Fatalf("GetItem: %v %v", got, err)
+	}
+	assertNum(t, got, "pk", 7)
+
+	result, err := tbl.QueryItems(bg(), ot.Item{"gs1pk": "sensor", "gs1sk": "3"}, &ot.Params{Index: "gs1", Parse: true})
+	if err != nil {
+		t.Fatalf("QueryItems: %v", err)
+	}
+	assertLen(t, result.Items, 1)
+}
+
+func TestGeneric_SchemaNumericKeys(t *testing.T) {
+	schema := &ot.SchemaDef{
+		Version: "0.0.1",
+		Indexes: map[string]*ot.IndexDef{"primary": {Hash: "pk", Sort: "sk"}},
+		Models: map[string]ot.ModelDef{
+			"Reading": {
+				"device": {Type: ot.FieldTypeNumber, Map: "pk"},
+				"seq":    {Type: ot.FieldTypeNumber, Map: "sk"},
+			},
+		},
+	}
+	tbl, mock := makeTable(t, "GenericTable", schema, false)
+	if _, err := tbl.PutItem(bg(), ot.Item{"pk": 7, "sk": 1}, nil); err != nil {
+		t.Fatalf("PutItem: %v", err)
+	}
+	for _, item := range mock.tbl("GenericTable") {
+		assertNumberAttr(t, item, "pk")
+		assertNumberAttr(t, item, "sk")
+	}
+	got, err := tbl.GetItem(bg(), ot.Item{"pk": 7, "sk": 1}, &ot.Params{Parse: true})
+	if err != nil || got == nil {
+		t.Fatalf("GetItem: %v %v", got, err)
+	}
+}
+
+// EventSchema uses epoch-second number sort keys on the primary index, a GSI
+// and an LSI.
+var EventSchema = &ot.SchemaDef{
+	Version: "0.0.1",
+	Indexes: map[string]*ot.IndexDef{
+		"primary": {Hash: "pk", Sort: "sk"},
+		"gs1":     {Hash: "gs1pk", Sort: "gs1sk"},
+		"ls1":     {Type: "local", Sort: "priority"},
+	},
+	Models: map[string]ot.ModelDef{
+		"Event": {
+			"pk":       {Type: ot.FieldTypeString, Value: "${_type}#${device}"},
+			"time":     {Type: ot.FieldTypeNumber, Map: "sk"},
+			"device":   {Type: ot.FieldTypeString},
+			"gs1pk":    {Type: ot.FieldTypeString, Value: "${_type}"},
+			"gs1sk":    {Type: ot.FieldTypeNumber, Value: "${time}"},
+			"priority": {Type: ot.FieldTypeNumber},
+		},
+	},
+}
+
+func TestGeneric_TableDefinitionKeyTypes(t *testing.T) {
+	tbl, _ := makeTable(t, "EventTable", EventSchema, false)
+	def := tbl.GetTableDefinition(nil)
+
+	if len(def.KeySchema) != 2 || aws.ToString(def.KeySchema[1].AttributeName) != "sk" {
+		t.Fatalf("unexpected primary key schema: %+v", def.KeySchema)
+	}
+	want := map[string]types.ScalarAttributeType{
+		"pk": types.ScalarAttributeTypeS, "sk": types.ScalarAttributeTypeN,
+		"gs1pk": types.ScalarAttributeTypeS, "gs1sk": types.ScalarAttributeTypeN,
+		"priority": types.ScalarAttributeTypeN,
+	}
+	if len(def.AttributeDefinitions) != len(want) {
+		t.Errorf("expected %d attribute definitions, got %d", len(want), len(def.AttributeDefinitions))
+	}
+	for _, ad := range def.AttributeDefinitions {
+		if want[aws.ToString(ad.AttributeName)] != ad.AttributeType {
+			t.Errorf("attribute %s: got %s", aws.ToString(ad.AttributeName), ad.AttributeType)
+		}
+	}
+	if len(def.GlobalSecondaryIndexes) != 1 || len(def.GlobalSecondaryIndexes[0].KeySchema) != 2 {
+		t.Errorf("unexpected GSIs: %+v", def.GlobalSecondaryIndexes)
+	}
+	if len(def.LocalSecondaryIndexes) != 1 || aws.ToString(def.LocalSecondaryIndexes[0].KeySchema[1].AttributeName) != "priority" {
+		t.Errorf("unexpected LSIs: %+v", def.LocalSecondaryIndexes)
+	}
+}
+
+// pagingMock serves one item per Query page, consuming half a capacity unit
+// per page, and records the start keys it receives.
+type pagingMock struct {
+	*fullMock
+	items  []map[string]types.AttributeValue
+	starts []map[string]types.AttributeValue
+}
+
+func (m *pagingMock) Query(_ context.Context, p *ddb.QueryInput, _ ...func(*ddb.Options)) (*ddb.QueryOutput, error) {
+	m.starts = append(m.starts, p.ExclusiveStartKey)
+	i := 0
+	if p.ExclusiveStartKey != nil {
+		for i < len(m.items) && itemKey(m.items[i]) != itemKey(p.ExclusiveStartKey) {
+			i++
+		}
+		i++
+	}
+	out := &ddb.QueryOutput{}
+	if p.ReturnConsumedCapacity != "" && p.ReturnConsumedCapacity != types.ReturnConsumedCapacityNone {
+		out.ConsumedCapacity = &types.ConsumedCapacity{CapacityUnits: aws.Float64(0.5)}
+	}
+	if i < len(m.items) {
+		out.Items = m.items[i : i+1]
+		out.Count = 1
+		if i+1 < len(m.items) {
+			out.LastEvaluatedKey = map[string]types.AttributeValue{"pk": m.items[i]["pk"], "sk": m.items[i]["sk"]}
+		}
+	}
+	return out, nil
+}
+
+func TestGeneric_NumericSortKeyPaging(t *testing.T) {
+	mock := &pagingMock{fullMock: newFullMock()}
+	tbl, err := ot.NewTable(ot.TableParams{Name: "EventTable", Client: mock, Schema: EventSchema})
+	if err != nil {
+		t.Fatalf("NewTable: %v", err)
+	}
+	for _, ts := range []int{1700000000, 1700000060, 1700000120} {
+		if _, err := tbl.Create(bg(), "Event", ot.Item{"device": "d1", "time": ts}, nil); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+	}
+	for _, item := range mock.tbl("EventTable") {
+		assertNumberAttr(t, item, "sk")
+		assertNumberAttr(t, item, "gs1sk")
+		mock.items = append(mock.items, item)
+	}
+	sortItemsBySK(mock.items)
+
+	result, err := tbl.Find(bg(), "Event", ot.Item{"device": "d1"}, nil)
+	if err != nil {
+		t.Fatalf("Find: %v", err)
+	}
+	assertLen(t, result.Items, 3)
+	if len(mock.starts) != 3 {
+		t.Fatalf("expected 3 pages, got %d", len(mock.starts))
+	}
+	for _, esk := range mock.starts[1:] {
+		assertNumberAttr(t, esk, "sk")
+	}
+
+	// a cursor whose numeric key became a string (e.g. via JSON) is restored to N
+	mock.starts = nil
+	_, err = tbl.Find(bg(), "Event", ot.Item{"device": "d1"}, &ot.Params{
+		Next: ot.Item{"pk": "Event#d1", "sk": "1700000060"},
+	})
+	if err != nil {
+		t.Fatalf("Find with cursor: %v", err)
+	}
+	assertNumberAttr(t, mock.starts[0], "sk")
+}
+
+func TestGeneric_MaxQueryPages(t *testing.T) {
+	mock := &pagingMock{fullMock: newFullMock()}
+	tbl, err := ot.NewTable(ot.TableParams{Name: "EventTable", Client: mock, Schema: EventSchema, MaxQueryPages: 2})
+	if err != nil {
+		t.Fatalf("NewTable: %v", err)
+	}
+	for _, ts := range []int{1700000000, 1700000060, 1700000120} {
+		tbl.Create(bg(), "Event", ot.Item{"device": "d1", "time": ts}, nil) //nolint
+	}
+	mock.items = slices.Collect(maps.Values(mock.tbl("EventTable")))
+	sortItemsBySK(mock.items)
+
+	result, _ := tbl.Find(bg(), "Event", ot.Item{"device": "d1"}, nil)
+	assertLen(t, result.Items, 2)
+	result, _ = tbl.Find(bg(), "Event", ot.Item{"device": "d1"}, &ot.Params{MaxPages: 3})
+	assertLen(t, result.Items, 3)
+
+	var argErr *ot.OneTableArgError
+	if _, err := ot.NewTable(ot.TableParams{Name: "EventTable", Client: mock, MaxQueryPages: -1}); !errors.As(err, &argErr) {
+		t.Errorf("expected OneTableArgError for negative MaxQueryPages, got %v", err)
+	}
+}
+
+func TestGeneric_MaxCapacity(t *testing.T) {
+	mock := &pagingMock{fullMock: newFullMock()}
+	tbl, err := ot.NewTable(ot.TableParams{Name: "EventTable", Client: mock, Schema: EventSchema})
+	if err != nil {
+		t.Fatalf("NewTable: %v", err)
+	}
+	for _, ts := range []int{1700000000, 1700000060, 1700000120, 1700000180} {
+		tbl.Create(bg(), "Event", ot.Item{"device": "d1", "time": ts}, nil) //nolint
+	}
+	mock.items = slices.Collect(maps.Values(mock.tbl("EventTable")))
+	sortItemsBySK(mock.items)
+
+	// stops once the budget is reached and continues from Next
+	stats := &ot.Stats{}
+	result, err := tbl.Find(bg(), "Event", ot.Item{"device": "d1"}, &ot.Params{MaxCapacity: 1, Stats: stats})
+	if err != nil {
+		t.Fatalf("Find: %v", err)
+	}
+	assertLen(t, result.Items, 2)
+	if !result.CapacityExceeded || result.Next == nil || stats.Capacity != 1 {
+		t.Errorf("expected a partial result after 1 unit, got exceeded=%v next=%v capacity=%v",
+			result.CapacityExceeded, result.Next, stats.Capacity)
+	}
+	result, _ = tbl.Find(bg(), "Event", ot.Item{"device": "d1"}, &ot.Params{MaxCapacity: 1, Next: result.Next})
+	assertLen(t, result.Items, 2)
+	if result.CapacityExceeded {
+		t.Error("the last page must not be reported as exceeded")
+	}
+
+	// a budget that is not reached returns everything
+	result, _ = tbl.Find(bg(), "Event", ot.Item{"device": "d1"}, &ot.Params{MaxCapacity: 10})
+	assertLen(t, result.Items, 4)
+	if result.CapacityExceeded {
+		t.Error("unexpected CapacityExceeded")
+	}
+
+	var argErr *ot.OneTableArgError
+	if _, err := tbl.Find(bg(), "Event", ot.Item{"device": "d1"}, &ot.Params{MaxCapacity: -1}); !errors.As(err, &argErr) {
+		t.Errorf("expected OneTableArgError for negative MaxCapacity, got %v", err)
+	}
+}
+
+func TestGeneric_PostFilter(t *testing.T) {
+	mock := &pagingMock{fullMock: newFullMock()}
+	tbl, err := ot.NewTable(ot.TableParams{Name: "EventTable", Client: mock, Schema: EventSchema})
+	if err != nil {
+		t.Fatalf("NewTable: %v", err)
+	}
+	for _, ts := range []int{1700000000, 1700000060, 1700000120, 1700000180} {
+		tbl.Create(bg(), "Event", ot.Item{"device": "d1", "time": ts}, nil) //nolint
+	}
+	mock.items = slices.Collect(maps.Values(mock.tbl("EventTable")))
+	sortItemsBySK(mock.items)
+
+	// pages that lose items to the filter still continue after the last item read
+	keep := func(item ot.Item) bool {
+		v, _ := item["time"].(float64)
+		return v != 1700000000 && v != 1700000120
+	}
+	var times []float64
+	var next ot.Item
+	for pages := 0; ; pages++ {
+		result, err := tbl.Find(bg(), "Event", ot.Item{"device": "d1"}, &ot.Params{Limit: 1, Next: next, PostFilter: keep})
+		if err != nil {
+			t.Fatalf("Find: %v", err)
+		}
+		for _, item := range result.Items {
+			v, _ := item["time"].(float64)
+			times = append(times, v)
+		}
+		if next = result.Next; next == nil {
+			if pages != 3 {
+				t.Errorf("expected 4 pages, got %d", pages+1)
+			}
+			break
+		}
+	}
+	if !slices.Equal(times, []float64{1700000060, 1700000180}) {
+		t.Errorf("unexpected items: %v", times)
+	}
+
+	var argErr *ot.OneTableArgError
+	if _, err := tbl.Find(bg(), "Event", ot.Item{"device": "d1"}, &ot.Params{Count: true, PostFilter: keep}); !errors.As(err, &argErr) {
+		t.Errorf("expected OneTableArgError for PostFilter with Count, got %v", err)
+	}
+}
+
+// slowGetMock records the most GetItem calls in flight at once.
+type slowGetMock struct {
+	*fullMock
+	active, peak atomic.Int32
+}
+
+func (m *slowGetMock) GetItem(ctx context.Context, p *ddb.GetItemInput, opts ...func(*ddb.Options)) (*ddb.GetItemOutput, error) {
+	n := m.active.Add(1)
+	defer m.active.Add(-1)
+	for {
+		peak := m.peak.Load()
+		if n <= peak || m.peak.CompareAndSwap(peak, n) {
+			break
+		}
+	}
+	time.Sleep(5 * time.Millisecond)
+	return m.fullMock.GetItem(ctx, p, opts...)
+}
+
+func TestGeneric_FollowConcurrency(t *testing.T) {
+	mock := &slowGetMock{fullMock: newFullMock()}
+	tbl, err := ot.NewTable(ot.TableParams{Name: "FollowTable", Client: mock, Schema: DefaultSchema, FollowConcurrency: 2})
+	if err != nil {
+		t.Fatalf("NewTable: %v", err)
+	}
+	for i := range 6 {
+		tbl.Create(bg(), "User", ot.Item{"name": "Peter Smith", "email": fmt.Sprintf("user%d@example.com", i)}, nil) //nolint
+	}
+
+	result, err := tbl.Find(bg(), "User", ot.Item{"name": "Peter Smith"}, &ot.Params{Index: "gs1", Follow: truePtr()})
+	if err != nil {
+		t.Fatalf("Find: %v", err)
+	}
+	assertLen(t, result.Items, 6)
+	if peak := mock.peak.Load(); peak != 2 {
+		t.Errorf("expected 2 concurrent gets, got %d", peak)
+	}
+
+	mock.peak.Store(0)
+	tbl.Find(bg(), "User", ot.Item{"name": "Peter Smith"}, &ot.Params{Index: "gs1", Follow: truePtr(), FollowConcurrency: 1}) //nolint
+	if peak := mock.peak.Load(); peak != 1 {
+		t.Errorf("expected sequential gets, got %d", peak)
+	}
+}
+
+func sortItemsBySK(items []map[string]types.AttributeValue) {
+	slices.SortFunc(items, func(a, b map[string]types.AttributeValue) int {
+		return strings.Compare(avStr(a["sk"]), avStr(b["sk"]))
+	})
+}
+
+func TestGeneric_NumberKeyOperators(t *testing.T) {
+	tbl, _ := makeTable(t, "EventTable", EventSchema, false)
+	noExec := false
+	result, err := tbl.Find(bg(), "Event", ot.Item{
+		"device": "d1",
+		"time":   map[string]any{"between": []any{"1700000000", 1700000100}},
+	}, &ot.Params{Execute: &noExec})
+	if err != nil {
+		t.Fatalf("Find between: %v", err)
+	}
+	values, _ := result.Items[0]["ExpressionAttributeValues"].(map[string]types.AttributeValue)
+	numbers := 0
+	for _, v := range values {
+		if _, ok := v.(*types.AttributeValueMemberN); ok {
+			numbers++
+		}
+	}
+	if numbers != 2 {
+		t.Errorf("expected both between operands typed N, got %v", values)
+	}
+
+	invalid := []map[string]any{
+		{"between": []any{1700000000}},
+		{"between": []any{"soon", 1700000100}},
+		{">=": []any{1}},
+		{"begins": "17"},
+	}
+	var argErr *ot.OneTableArgError
+	for _, ops := range invalid {
+		_, err := tbl.Find(bg(), "Event", ot.Item{"device": "d1", "time": ops}, &ot.Params{Execute: &noExec})
+		if !errors.As(err, &argErr) {
+			t.Errorf("expected OneTableArgError for %v, got %v", ops, err)
+		}
+	}
+}
+
+func TestGeneric_Execute(t *testing.T) {
+	tbl, _ := makeTable(t, "ExecuteTable", DefaultSchema, false)
+	user, _ := tbl.Create(bg(), "User", ot.Item{"name": "Ann", "age": float64(31)}, nil)
+	tbl.Create(bg(), "Pet", ot.Item{"name": "Rex", "race": "dog", "breed": "lab"}, nil)
+
+	// hand-built query on the primary index
+	result, err := tbl.Execute(bg(), "find", &ddb.QueryInput{
+		TableName:                 aws.String("ExecuteTable"),
+		KeyConditionExpression:    aws.String("#pk = :pk"),
+		ExpressionAttributeNames:  map[string]string{"#pk": "pk"},
+		ExpressionAttributeValues: map[string]types.AttributeValue{":pk": &types.AttributeValueMemberS{Value: "User#" + user["id"].(string)}},
+	}, nil)
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	assertLen(t, result.Items, 1)
+	assertStr(t, result.Items[0], "name", "Ann")
+	assertNum(t, result.Items[0], "age", 31)
+	assertDate(t, result.Items[0]["created"])
+	assertAbsent(t, result.Items[0], "pk")
+
+	// scan parses each item with its own model
+	result, err = tbl.Execute(bg(), "scan", &ddb.ScanInput{TableName: aws.String("ExecuteTable")}, nil)
+	if err != nil {
+		t.Fatalf("Execute scan: %v", err)
+	}
+	assertLen(t, result.Items, 2)
+	for _, item := range result.Items {
+		assertAbsent(t, item, "sk")
+	}
+
+	got, err := tbl.Execute(bg(), "get", &ddb.GetItemInput{
+		TableName: aws.String("ExecuteTable"),
+		Key: map[string]types.AttributeValue{
+			"pk": &types.AttributeValueMemberS{Value: "User#" + user["id"].(string)},
+			"sk": &types.AttributeValueMemberS{Value: "User#"},
+		},
+	}, &ot.Params{Hidden: truePtr()})
+	if err != nil {
+		t.Fatalf("Execute get: %v", err)
+	}
+	assertLen(t, got.Items, 1)
+	assertPresent(t, got.Items[0], "pk")
+
+	// a failed input is reported with a redacted snapshot of it
+	_, err = tbl.Execute(bg(), "update", &ddb.UpdateItemInput{
+		TableName: aws.String("ExecuteTable"),
+		Key: map[string]types.AttributeValue{
+			"pk": &types.AttributeValueMemberS{Value: "User#" + user["id"].(string)},
+			"sk": &types.AttributeValueMemberS{Value: "User#"},
+		},
+		UpdateExpression:          aws.String("set #name = :name"),
+		ConditionExpression:       aws.String("#age = :age"),
+		ExpressionAttributeNames:  map[string]string{"#name": "name", "#age": "age"},
+		ExpressionAttributeValues: map[string]types.AttributeValue{":name": &types.AttributeValueMemberS{Value: "secret-name"}, ":age": &types.AttributeValueMemberN{Value: "99"}},
+	}, nil)
+	var otErr *ot.OneTableError
+	if !errors.As(err, &otErr) {
+		t.Fatalf("expected OneTableError, got %v", err)
+	}
+	cmd, _ := otErr.Context["command"].(map[string]any)
+	if cmd["op"] != "update" || cmd["TableName"] != "ExecuteTable" || cmd["ConditionExpression"] != "#age = :age" {
+		t.Errorf("unexpected snapshot %v", cmd)
+	}
+	if key, _ := cmd["Key"].(ot.Item); key["sk"] != "User#" {
+		t.Errorf("snapshot key %v", cmd["Key"])
+	}
+	if strings.Contains(fmt.Sprint(cmd), "secret-name") {
+		t.Error("snapshot leaks an expression value")
+	}
+
+	var argErr *ot.OneTableArgError
+	if _, err := tbl.Execute(bg(), "get", &ddb.ScanInput{}, nil); !errors.As(err, &argErr) {
+		t.Errorf("expected OneTableArgError for mismatched op, got %v", err)
+	}
+	if _, err := tbl.Execute(bg(), "find", ot.Item{}, nil); !errors.As(err, &argErr) {
+		t.Errorf("expected OneTableArgError for unsupported input, got %v", err)
+	}
+}
+
+func TestGeneric_IndexValidation(t *testing.T) {
+	schemaPanic := func(indexes map[string]*ot.IndexDef) string {
+		var msg string
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					msg = fmt.Sprint(r)
+				}
+			}()
+			schema := &ot.SchemaDef{Version: "0.0.1", Indexes: indexes, Models: map[string]ot.ModelDef{}}
+			ot.NewTable(ot.TableParams{Name: "IndexTable", Client: newFullMock(), Schema: schema}) //nolint
+		}()
+		return msg
+	}
+
+	gsis := map[string]*ot.IndexDef{"primary": {Hash: "pk", Sort: "sk"}}
+	for i := range 21 {
+		gsis[fmt.Sprintf("gs%d", i)] = &ot.IndexDef{Hash: fmt.Sprintf("gs%dpk", i), Sort: fmt.Sprintf("gs%dsk", i)}
+	}
+	assertContains(t, schemaPanic(gsis), "21 GSIs")
+	delete(gsis, "gs20")
+	if msg := schemaPanic(gsis); msg != "" {
+		t.Errorf("expected 20 GSIs to be accepted, got %s", msg)
+	}
+
+	msg := schemaPanic(map[string]*ot.IndexDef{
+		"primary": {Hash: "pk", Sort: "sk"},
+		"gs1":     {Hash: "gs1pk", Sort: "gs1sk"},
+		"gs2":     {Hash: "gs1pk", Sort: "gs1sk"},
+	})
+	assertContains(t, msg, `indexes "gs1" and "gs2" both use hash "gs1pk" and sort "gs1sk"`)
+
+	msg = schemaPanic(map[string]*ot.IndexDef{
+		"primary": {Hash: "pk", Sort: "sk"},
+		"ls1":     {Sort: "sk"},
+	})
+	assertContains(t, msg, `indexes "ls1" and "primary"`)
+}
+
+func TestGeneric_QueryIndex(t *testing.T) {
+	tbl, _ := makeTable(t, "QueryIndexTable", DefaultSchema, false)
+	for _, name := range []string{"Ann", "Bob"} {
+		tbl.Create(bg(), "User", ot.Item{"name": name, "status": "active"}, nil)
+	}
+	tbl.Create(bg(), "User", ot.Item{"name": "Cid", "status": "idle"}, nil)
+
+	result, err := tbl.QueryIndex(bg(), "gs3", "User#active", nil, nil)
+	if err != nil {
+		t.Fatalf("QueryIndex: %v", err)
+	}
+	assertLen(t, result.Items, 2)
+	assertPresent(t, result.Items[0], "gs3pk")
+
+	result, err = tbl.QueryIndex(bg(), "gs3", "User#active", map[string]any{"begins": "User#B"}, nil)
+	if err != nil {
+		t.Fatalf("QueryIndex begins: %v", err)
+	}
+	assertLen(t, result.Items, 1)
+	assertStr(t, result.Items[0], "name", "Bob")
+
+	result, err = tbl.QueryIndex(bg(), "gs3", "User#idle", "User#Cid", &ot.Params{Parse: true})
+	if err != nil {
+		t.Fatalf("QueryIndex equal: %v", err)
+	}
+	assertLen(t, result.Items, 1)
+	assertAbsent(t, result.Items[0], "gs3pk")
+
+	var argErr *ot.OneTableArgError
+	if _, err := tbl.QueryIndex(bg(), "gs9", "x", nil, nil); !errors.As(err, &argErr) {
+		t.Errorf("expected OneTableArgError for unknown index, got %v", err)
+	}
+	if _, err := tbl.QueryIndex(bg(), "gs3", nil, nil, nil); !errors.As(err, &argErr) {
+		t.Errorf("expected OneTableArgError for missing hash value, got %v", err)
+	}
+}
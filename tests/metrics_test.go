@@ -0,0 +1,190 @@
+package tests
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	ddb "github.com/aws/aws-sdk-go-v2/service/dynamodb"
+
+	ot "github.com/cloudxsgmbh/dynamodb-onetable-go"
+)
+
+// recordingMetrics keeps the metric tags of every operation it sees.
+type recordingMetrics struct {
+	mu   sync.Mutex
+	tags []map[string]string
+}
+
+func (r *recordingMetrics) Add(_, _ string, _ ot.Item, params *ot.Params, _ time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.tags = append(r.tags, params.MetricTags)
+	return nil
+}
+
+func (r *recordingMetrics) Flush() error { return nil }
+
+func TestMetrics_Tags(t *testing.T) {
+	schema := *DefaultSchema
+	schema.MetricTags = map[string]map[string]string{
+		"User": {"domain": "identity", "tier": "gold"},
+	}
+	metrics := &recordingMetrics{}
+	tbl, err := ot.NewTable(ot.TableParams{Name: "MetricsTable", Client: newFullMock(), Schema: &schema, Metrics: metrics})
+	if err != nil {
+		t.Fatalf("NewTable: %v", err)
+	}
+
+	callTags := map[string]string{"tier": "trial", "tenant": "acme"}
+	user, err := tbl.Create(bg(), "User", ot.Item{"name": "Ann", "email": "ann@example.com"}, &ot.Params{MetricTags: callTags})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if _, err := tbl.Get(bg(), "User", ot.Item{"id": user["id"]}, nil); err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+
+	if len(metrics.tags) != 2 {
+		t.Fatalf("expected 2 metric records, got %d", len(metrics.tags))
+	}
+	want := map[string]string{"domain": "identity", "tier": "trial", "tenant": "acme"}
+	for k, v := range want {
+		if metrics.tags[0][k] != v {
+			t.Errorf("create tag %s = %q, want %q", k, metrics.tags[0][k], v)
+		}
+	}
+	if metrics.tags[1]["tier"] != "gold" || metrics.tags[1]["tenant"] != "" {
+		t.Errorf("unexpected get tags %v", metrics.tags[1])
+	}
+	if len(callTags) != 2 {
+		t.Errorf("caller tags were modified: %v", callTags)
+	}
+}
+
+func TestMetrics_TagsAfterReload(t *testing.T) {
+	schema := *DefaultSchema
+	schema.MetricTags = map[string]map[string]string{"User": {"domain": "identity"}}
+	metrics := &recordingMetrics{}
+	tbl, err := ot.NewTable(ot.TableParams{Name: "MetricsTable", Client: newFullMock(), Schema: &schema, Metrics: metrics})
+	if err != nil {
+		t.Fatalf("NewTable: %v", err)
+	}
+	User, _ := tbl.GetModel("User")
+	reloaded := *DefaultSchema
+	reloaded.MetricTags = map[string]map[string]string{"User": {"domain": "accounts"}}
+	if _, err := tbl.SetSchema(bg(), &reloaded); err != nil {
+		t.Fatalf("SetSchema: %v", err)
+	}
+
+	// a model held across the reload reports the tags of its own schema
+	if _, err := User.Create(bg(), ot.Item{"name": "Ann"}, nil); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if _, err := tbl.Create(bg(), "User", ot.Item{"name": "Bob"}, nil); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if len(metrics.tags) != 2 || metrics.tags[0]["domain"] != "identity" || metrics.tags[1]["domain"] != "accounts" {
+		t.Errorf("unexpected tags %v", metrics.tags)
+	}
+}
+
+func TestMetrics_UnknownModelTags(t *testing.T) {
+	schema := *DefaultSchema
+	schema.MetricTags = map[string]map[string]string{"Nope": {"domain": "x"}}
+	defer func() {
+		if r := recover(); r == nil {
+			t.Error("expected schema error for metric tags of an unknown model")
+		}
+	}()
+	ot.NewTable(ot.TableParams{Name: "MetricsTable", Client: newFullMock(), Schema: &schema}) //nolint
+}
+
+// errorLog records Error messages and drops everything else.
+type errorLog struct{ errors []string }
+
+func (l *errorLog) Trace(string, map[string]any)       {}
+func (l *errorLog) Info(string, map[string]any)        {}
+func (l *errorLog) Data(string, map[string]any)        {}
+func (l *errorLog) Error(msg string, _ map[string]any) { l.errors = append(l.errors, msg) }
+
+type panickingMetrics struct{}
+
+func (panickingMetrics) Add(string, string, ot.Item, *ot.Params, time.Time) error { panic("boom") }
+func (panickingMetrics) Flush() error                                             { return nil }
+
+func TestMetrics_HookFailuresContained(t *testing.T) {
+	log := &errorLog{}
+	tbl, err := ot.NewTable(ot.TableParams{
+		Name: "MetricsTable", Client: newFullMock(), Schema: DefaultSchema, Logger: log,
+		Metrics: panickingMetrics{},
+		Monitor: func(string, string, ot.Item, *ot.Params, time.Time) error {
+			return errors.New("monitor down")
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewTable: %v", err)
+	}
+	user, err := tbl.Create(bg(), "User", ot.Item{"name": "Ann", "email": "ann@example.com"}, nil)
+	if err != nil {
+		t.Fatalf("Create with failing hooks: %v", err)
+	}
+	assertStr(t, user, "name", "Ann")
+	if n := tbl.HookFailures(); n != 2 {
+		t.Errorf("expected 2 hook failures, got %d", n)
+	}
+	if len(log.errors) != 2 {
+		t.Fatalf("expected 2 logged hook errors, got %v", log.errors)
+	}
+	assertContains(t, log.errors[0], "metrics hook panic")
+	assertContains(t, log.errors[1], "monitor hook failed")
+}
+
+func TestMetrics_TestHooks(t *testing.T) {
+	type call struct {
+		model, op string
+		data      ot.Item
+	}
+	var commands, results []call
+	hooks := &ot.TestHooks{
+		Command: func(model, op string, cmd ot.Item) { commands = append(commands, call{model, op, cmd}) },
+		Result:  func(model, op string, result ot.Item) { results = append(results, call{model, op, result}) },
+	}
+	tbl, err := ot.NewTable(ot.TableParams{Name: "HooksTable", Client: newFullMock(), Schema: DefaultSchema, TestHooks: hooks})
+	if err != nil {
+		t.Fatalf("NewTable: %v", err)
+	}
+	user, _ := tbl.Create(bg(), "User", ot.Item{"name": "Ann"}, nil)
+	if _, err := tbl.Find(bg(), "User", ot.Item{"id": user["id"]}, &ot.Params{Where: "${status} = {idle}"}); err != nil {
+		t.Fatalf("Find: %v", err)
+	}
+
+	if len(commands) != 2 || commands[0].op != "put" || commands[1].op != "find" || commands[1].model != "User" {
+		t.Fatalf("unexpected commands %+v", commands)
+	}
+	assertContains(t, commands[1].data["KeyConditionExpression"].(string), "#_")
+	assertContains(t, commands[1].data["FilterExpression"].(string), "= :_")
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results, got %d", len(results))
+	}
+	if items, _ := results[1].data["Items"].([]ot.Item); len(items) != 1 {
+		t.Errorf("expected one raw item in the find result, got %v", results[1].data)
+	}
+
+	// pre-built inputs are reported like the model API commands
+	if _, err := tbl.Execute(bg(), "scan", &ddb.ScanInput{TableName: aws.String("HooksTable"),
+		FilterExpression: aws.String("attribute_exists(#name)"), ExpressionAttributeNames: map[string]string{"#name": "name"}}, nil); err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if len(commands) != 3 || commands[2].op != "scan" || commands[2].data["FilterExpression"] != "attribute_exists(#name)" {
+		t.Fatalf("unexpected Execute command %+v", commands[len(commands)-1])
+	}
+
+	tbl.SetTestHooks(nil)
+	tbl.Get(bg(), "User", ot.Item{"id": user["id"]}, nil) //nolint
+	if len(commands) != 3 {
+		t.Errorf("expected no calls after removing hooks, got %d", len(commands))
+	}
+}
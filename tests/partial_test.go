@@ -2,9 +2,13 @@
 package tests
 
 import (
+	"maps"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
 	ot "github.com/cloudxsgmbh/dynamodb-onetable-go"
 )
 
@@ -98,3 +102,145 @@ func TestPartial_UpdateFullReplace(t *testing.T) {
 		t.Fatalf("Update full replace: %v", err)
 	}
 }
+
+func TestPartial_UpsertNestedDefaults(t *testing.T) {
+	tbl, mock := makeTable(t, "PartialTable", PartialSchema, true)
+	// reads fill in defaults, so check the stored address
+	stored := func() map[string]types.AttributeValue {
+		for _, item := range mock.tbl("PartialTable") {
+			if m, ok := item["address"].(*types.AttributeValueMemberM); ok {
+				return m.Value
+			}
+		}
+		t.Fatal("no stored address")
+		return nil
+	}
+	start := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	tbl.Create(bg(), "User", ot.Item{ //nolint
+		"email": "user@example.com", "id": "42", "status": "active",
+		"address": map[string]any{"street": "42 Park Ave", "zip": float64(12345), "box": map[string]any{"start": start}},
+	}, nil)
+
+	// merging into an existing address keeps the stored box
+	if _, err := tbl.Upsert(bg(), "User", ot.Item{"id": "42", "email": "user@example.com",
+		"address": map[string]any{"zip": float64(99999)}}, nil); err != nil {
+		t.Fatalf("Upsert partial: %v", err)
+	}
+	addr := stored()
+	if avStr(addr["street"]) != "42 Park Ave" || avStr(addr["zip"]) != "99999" {
+		t.Errorf("unexpected address %v", addr)
+	}
+	if box, ok := addr["box"].(*types.AttributeValueMemberM); !ok || box.Value["start"] == nil {
+		t.Errorf("expected the stored box to be kept, got %v", addr["box"])
+	}
+
+	// a missing box is created from its default
+	tbl.Update(bg(), "User", ot.Item{"id": "42", "address": map[string]any{"street": "1 Main St"}}, //nolint
+		&ot.Params{Partial: falsePtr()})
+	if stored()["box"] != nil {
+		t.Fatal("update must not apply nested defaults")
+	}
+	if _, err := tbl.Upsert(bg(), "User", ot.Item{"id": "42", "email": "user@example.com",
+		"address": map[string]any{"zip": float64(11111)}}, nil); err != nil {
+		t.Fatalf("Upsert partial: %v", err)
+	}
+	addr = stored()
+	if avStr(addr["street"]) != "1 Main St" || addr["box"] == nil {
+		t.Errorf("expected street kept and box created, got %v", addr)
+	}
+
+	// a replaced address is a new structure and gets its defaults
+	noExec := false
+	cmd, _ := tbl.Upsert(bg(), "User", ot.Item{"id": "42", "email": "user@example.com",
+		"address": map[string]any{"zip": float64(22222)}}, &ot.Params{Partial: falsePtr(), Execute: &noExec})
+	if strings.Contains(cmd["UpdateExpression"].(string), "if_not_exists") {
+		t.Errorf("replaced address must not use if_not_exists: %s", cmd["UpdateExpression"])
+	}
+	if _, err := tbl.Upsert(bg(), "User", ot.Item{"id": "42", "email": "user@example.com",
+		"address": map[string]any{"zip": float64(22222)}}, &ot.Params{Partial: falsePtr()}); err != nil {
+		t.Fatalf("Upsert replace: %v", err)
+	}
+	addr = stored()
+	if addr["street"] != nil || addr["box"] == nil {
+		t.Errorf("expected a new address with a default box, got %v", addr)
+	}
+}
+
+func TestPartial_EmptyObject(t *testing.T) {
+	for _, partial := range []bool{true, false} {
+		for _, op := range []string{"create", "update", "upsert"} {
+			tbl, mock := makeTable(t, "PartialTable", PartialSchema, partial)
+			if op != "create" {
+				tbl.Create(bg(), "User", ot.Item{"id": "42", "email": "user@example.com", //nolint
+					"address": map[string]any{"street": "42 Park Ave"}}, nil)
+			}
+			props := ot.Item{"id": "42", "email": "user@example.com", "address": map[string]any{}}
+			var err error
+			switch op {
+			case "create":
+				_, err = tbl.Create(bg(), "User", props, nil)
+			case "update":
+				_, err = tbl.Update(bg(), "User", props, nil)
+			case "upsert":
+				_, err = tbl.Upsert(bg(), "User", props, nil)
+			}
+			if err != nil {
+				t.Fatalf("%s partial=%v: %v", op, partial, err)
+			}
+			// the empty address replaces the stored one; only create and
+			// upsert apply the nested box default
+			for _, item := range mock.tbl("PartialTable") {
+				addr, ok := item["address"].(*types.AttributeValueMemberM)
+				if !ok || addr.Value["street"] != nil || (addr.Value["box"] != nil) == (op == "update") {
+					t.Errorf("%s partial=%v: unexpected address %v", op, partial, item["address"])
+				}
+			}
+		}
+	}
+}
+
+func TestPartial_OmitEmpty(t *testing.T) {
+	schema := &ot.SchemaDef{
+		Version: "0.0.1",
+		Indexes: map[string]*ot.IndexDef{"primary": {Hash: "pk", Sort: "sk"}},
+		Models: map[string]ot.ModelDef{
+			"User": {
+				"pk":   {Type: ot.FieldTypeString, Value: "${_type}#${id}"},
+				"sk":   {Type: ot.FieldTypeString, Value: "${_type}#"},
+				"id":   {Type: ot.FieldTypeString},
+				"tags": {Type: ot.FieldTypeArray, OmitEmpty: true},
+				"address": {Type: ot.FieldTypeObject, OmitEmpty: true, Schema: ot.FieldMap{
+					"street": {Type: ot.FieldTypeString},
+				}},
+			},
+		},
+	}
+	for _, partial := range []bool{true, false} {
+		tbl, mock := makeTable(t, "OmitTable", schema, partial)
+		empty := ot.Item{"id": "42", "tags": []any{}, "address": map[string]any{}}
+		if _, err := tbl.Create(bg(), "User", empty, nil); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+		for _, item := range mock.tbl("OmitTable") {
+			if item["tags"] != nil || item["address"] != nil {
+				t.Errorf("partial=%v: create must omit empty values, got %v", partial, item)
+			}
+		}
+		tbl, mock = makeTable(t, "OmitTable", schema, partial)
+		tbl.Create(bg(), "User", ot.Item{"id": "42", "tags": []any{"a"}, //nolint
+			"address": map[string]any{"street": "42 Park Ave"}}, nil)
+		for _, update := range []func() error{
+			func() error { _, err := tbl.Update(bg(), "User", maps.Clone(empty), nil); return err },
+			func() error { _, err := tbl.Upsert(bg(), "User", maps.Clone(empty), nil); return err },
+		} {
+			if err := update(); err != nil {
+				t.Fatalf("Update: %v", err)
+			}
+			for _, item := range mock.tbl("OmitTable") {
+				if item["tags"] == nil || item["address"] == nil {
+					t.Errorf("partial=%v: update must keep the stored values, got %v", partial, item)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	ddb "github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	ot "github.com/cloudxsgmbh/dynamodb-onetable-go"
+)
+
+func TestReKey_IndexKeys(t *testing.T) {
+	tbl, mock := makeTable(t, "ReKeyTable", DefaultSchema, false)
+	User, _ := tbl.GetModel("User")
+	user, err := User.Create(bg(), ot.Item{"name": "Peter Smith", "email": "peter@example.com"}, nil)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	id := user["id"].(string)
+	created := avStr(mock.tbl("ReKeyTable")["User#"+id+"||User#"]["created"])
+
+	item, err := User.ReKey(bg(), ot.Item{"id": id}, ot.Item{"name": "Peter Jones"}, nil)
+	if err != nil {
+		t.Fatalf("ReKey: %v", err)
+	}
+	assertStr(t, item, "name", "Peter Jones")
+	assertStr(t, item, "email", "peter@example.com")
+	items := mock.tbl("ReKeyTable")
+	if len(items) != 1 {
+		t.Fatalf("expected 1 item, got %d", len(items))
+	}
+	for _, raw := range items {
+		if s := avStr(raw["gs1pk"]); s != "User#Peter Jones" {
+			t.Errorf("stale gs1pk: %s", s)
+		}
+		if s := avStr(raw["created"]); s != created {
+			t.Errorf("created changed: %s → %s", created, s)
+		}
+	}
+}
+
+func TestReKey_PrimaryKey(t *testing.T) {
+	tbl, mock := makeTable(t, "ReKeyTable", UniqueSchema, false)
+	User, _ := tbl.GetModel("User")
+	for _, p := range []ot.Item{
+		{"name": "Peter Smith", "email": "peter@example.com", "phone": "+15551234"},
+		{"name": "Judy Smith", "email": "judy@example.com"},
+	} {
+		if _, err := User.Create(bg(), p, nil); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+	}
+	if n := len(mock.tbl("ReKeyTable")); n != 7 {
+		t.Fatalf("expected 2 items and 5 sentinels, got %d", n)
+	}
+
+	// the new key is taken: nothing changes
+	_, err := User.ReKey(bg(), ot.Item{"name": "Peter Smith"}, ot.Item{"name": "Judy Smith"}, nil)
+	assertErrCode(t, err, ot.ErrUnique)
+	if item, _ := User.Get(bg(), ot.Item{"name": "Peter Smith"}, nil); item == nil {
+		t.Fatal("item removed by failed ReKey")
+	}
+
+	item, err := User.ReKey(bg(), ot.Item{"name": "Peter Smith"}, ot.Item{"name": "Peter Jones"}, nil)
+	if err != nil {
+		t.Fatalf("ReKey: %v", err)
+	}
+	assertStr(t, item, "name", "Peter Jones")
+	if item, _ := User.Get(bg(), ot.Item{"name": "Peter Smith"}, nil); item != nil {
+		t.Error("old item not removed")
+	}
+	item, _ = User.Get(bg(), ot.Item{"name": "Peter Jones"}, nil)
+	assertStr(t, item, "phone", "+15551234")
+
+	items := mock.tbl("ReKeyTable")
+	if len(items) != 7 {
+		t.Errorf("expected 2 items and 5 sentinels, got %d", len(items))
+	}
+	if _, ok := items["_unique#User#interpolated#Peter Smith#peter@example.com||_unique#"]; ok {
+		t.Error("stale unique sentinel")
+	}
+	if _, ok := items["_unique#User#interpolated#Peter Jones#peter@example.com||_unique#"]; !ok {
+		t.Error("missing unique sentinel for the new value")
+	}
+}
+
+func TestReKey_ConcurrentWrite(t *testing.T) {
+	tbl, mock := makeTable(t, "ReKeyTable", DefaultSchema, false)
+	User, _ := tbl.GetModel("User")
+	for _, newProps := range []ot.Item{{"name": "Peter Jones"}, {"id": "moved"}} {
+		user, err := User.Create(bg(), ot.Item{"name": "Peter Smith"}, nil)
+		if err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+		key := "User#" + user["id"].(string) + "||User#"
+		// another writer updates the item after ReKey has read it
+		tbl.SetTestHooks(&ot.TestHooks{Command: func(_, op string, _ ot.Item) {
+			if op == "transactWrite" {
+				mock.tbl("ReKeyTable")[key]["updated"] = &types.AttributeValueMemberS{Value: "2099-01-01T00:00:00Z"}
+			}
+		}})
+		_, err = User.ReKey(bg(), ot.Item{"id": user["id"]}, newProps, nil)
+		tbl.SetTestHooks(nil)
+		assertErrCode(t, err, ot.ErrUnique)
+		if item, _ := User.Get(bg(), ot.Item{"id": user["id"]}, nil); item == nil || item["name"] != "Peter Smith" {
+			t.Errorf("%v: concurrent write overwritten: %v", newProps, item)
+		}
+	}
+}
+
+// consistentIndexMock rejects consistent reads of secondary indexes, as
+// DynamoDB does.
+type consistentIndexMock struct {
+	*fullMock
+}
+
+func (m *consistentIndexMock) Query(ctx context.Context, p *ddb.QueryInput, opts ...func(*ddb.Options)) (*ddb.QueryOutput, error) {
+	if p.IndexName != nil && aws.ToBool(p.ConsistentRead) {
+		return nil, errors.New("ValidationException: Consistent reads are not supported on global secondary indexes")
+	}
+	return m.fullMock.Query(ctx, p, opts...)
+}
+
+func TestReKey_Params(t *testing.T) {
+	tbl, err := ot.NewTable(ot.TableParams{Name: "ReKeyTable", Client: &consistentIndexMock{newFullMock()}, Schema: DefaultSchema})
+	if err != nil {
+		t.Fatalf("NewTable: %v", err)
+	}
+	User, _ := tbl.GetModel("User")
+	if _, err := User.Create(bg(), ot.Item{"name": "Peter Smith", "email": "peter@example.com"}, nil); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	// the old item is found on a secondary index, and the caller's params
+	// apply to the written item
+	item, err := User.ReKey(bg(), ot.Item{"name": "Peter Smith"}, ot.Item{"name": "Peter Jones"},
+		&ot.Params{Index: "gs1", Hidden: truePtr()})
+	if err != nil {
+		t.Fatalf("ReKey: %v", err)
+	}
+	assertStr(t, item, "name", "Peter Jones")
+	assertStr(t, item, "gs1pk", "User#Peter Jones")
+}
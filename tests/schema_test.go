@@ -0,0 +1,127 @@
+package tests
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	ot "github.com/cloudxsgmbh/dynamodb-onetable-go"
+)
+
+func reloadSchema(prefix, typeField string) *ot.SchemaDef {
+	return &ot.SchemaDef{
+		Version: "0.0.1",
+		Indexes: map[string]*ot.IndexDef{
+			"primary": {Hash: "pk", Sort: "sk"},
+		},
+		Models: map[string]ot.ModelDef{
+			"Item": {
+				"pk": {Type: ot.FieldTypeString, Value: prefix + "#${id}"},
+				"sk": {Type: ot.FieldTypeString, Value: prefix + "#"},
+				"id": {Type: ot.FieldTypeString, Required: true},
+			},
+		},
+		Params: &ot.SchemaParams{TypeField: typeField},
+	}
+}
+
+func TestSchema_Reload(t *testing.T) {
+	tbl, mock := makeTable(t, "ReloadTable", reloadSchema("old", "_type"), false)
+	old, err := tbl.GetModel("Item")
+	if err != nil {
+		t.Fatalf("GetModel: %v", err)
+	}
+	if _, err := tbl.SetSchema(bg(), reloadSchema("new", "kind")); err != nil {
+		t.Fatalf("SetSchema: %v", err)
+	}
+	current, err := tbl.GetModel("Item")
+	if err != nil {
+		t.Fatalf("GetModel: %v", err)
+	}
+
+	// a model obtained before the reload keeps its schema, params included
+	if _, err := old.Create(bg(), ot.Item{"id": "1"}, nil); err != nil {
+		t.Fatalf("Create old: %v", err)
+	}
+	if _, err := current.Create(bg(), ot.Item{"id": "2"}, nil); err != nil {
+		t.Fatalf("Create new: %v", err)
+	}
+	items := mock.tbl("ReloadTable")
+	if len(items) != 2 {
+		t.Fatalf("expected 2 items, got %d", len(items))
+	}
+	for _, item := range items {
+		pk := avStr(item["pk"])
+		switch {
+		case pk == "old#1" && item["_type"] != nil && item["kind"] == nil:
+		case pk == "new#2" && item["kind"] != nil && item["_type"] == nil:
+		default:
+			t.Errorf("item written with mixed schemas: %v", item)
+		}
+	}
+	item, err := old.Get(bg(), ot.Item{"id": "1"}, nil)
+	if err != nil || item == nil {
+		t.Fatalf("Get old: %v %v", item, err)
+	}
+
+	// removing a model leaves held models usable
+	if err := tbl.RemoveModel("Item"); err != nil {
+		t.Fatalf("RemoveModel: %v", err)
+	}
+	if _, err := tbl.GetModel("Item"); err == nil {
+		t.Error("expected removed model to be gone")
+	}
+	if item, err := current.Get(bg(), ot.Item{"id": "2"}, nil); err != nil || item == nil {
+		t.Errorf("Get after RemoveModel: %v %v", item, err)
+	}
+}
+
+func TestSchema_ReloadKeyTypes(t *testing.T) {
+	numeric := reloadSchema("n", "_type")
+	numeric.Models["Item"]["sk"] = &ot.FieldDef{Type: ot.FieldTypeNumber}
+	tbl, _ := makeTable(t, "ReloadTable", numeric, false)
+
+	// the reloaded schema's declared key type replaces the old one
+	if _, err := tbl.SetSchema(bg(), reloadSchema("s", "_type")); err != nil {
+		t.Fatalf("SetSchema: %v", err)
+	}
+	for _, ad := range tbl.GetTableDefinition(nil).AttributeDefinitions {
+		if aws.ToString(ad.AttributeName) == "sk" && ad.AttributeType != types.ScalarAttributeTypeS {
+			t.Errorf("sk type = %s after reload, want S", ad.AttributeType)
+		}
+	}
+}
+
+func TestSchema_ConcurrentReload(t *testing.T) {
+	tbl, _ := makeTable(t, "ReloadTable", reloadSchema("a", "_type"), false)
+	var wg sync.WaitGroup
+	for w := range 4 {
+		wg.Go(func() {
+			for i := range 50 {
+				m, err := tbl.GetModel("Item")
+				if err != nil {
+					t.Errorf("GetModel: %v", err)
+					return
+				}
+				if _, err := m.Upsert(bg(), ot.Item{"id": string(rune('a' + w))}, nil); err != nil {
+					t.Errorf("Upsert %d: %v", i, err)
+					return
+				}
+				if _, err := tbl.GetItem(bg(), ot.Item{"pk": "a#a", "sk": "a#"}, nil); err != nil {
+					t.Errorf("GetItem: %v", err)
+					return
+				}
+			}
+		})
+	}
+	for i := range 50 {
+		prefix := []string{"a", "b"}[i%2]
+		if _, err := tbl.SetSchema(bg(), reloadSchema(prefix, "_type")); err != nil {
+			t.Fatalf("SetSchema: %v", err)
+		}
+		tbl.AddModel("Extra", ot.FieldMap{"pk": {Type: ot.FieldTypeString}, "sk": {Type: ot.FieldTypeString}})
+	}
+	wg.Wait()
+}
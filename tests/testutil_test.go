@@ -11,6 +11,7 @@ import (
 	"fmt"
 	"maps"
 	"regexp"
+	"slices"
 	"strings"
 	"sync"
 	"testing"
@@ -86,7 +87,7 @@ func applyUpdateExpression(
 
 	// process SET
 	if setClause, ok := clauses["set"]; ok {
-		for assignment := range strings.SplitSeq(setClause, ",") {
+		for _, assignment := range splitTopLevel(setClause, ",") {
 			assignment = strings.TrimSpace(assignment)
 			if assignment == "" {
 				continue
@@ -95,11 +96,32 @@ func applyUpdateExpression(
 			if !ok {
 				continue
 			}
-			attr := resolveName(strings.TrimSpace(lhs))
+			rhs = strings.TrimSpace(rhs)
+			ifMissing := strings.HasPrefix(rhs, "if_not_exists(")
+			if ifMissing {
+				// if_not_exists(path, :v) — the path is the assignment target
+				rhs = strings.TrimSuffix(rhs[strings.LastIndex(rhs, ",")+1:], ")")
+			}
 			val := resolveVal(strings.TrimSpace(rhs))
-			if val != nil {
-				item[attr] = val
+			if val == nil {
+				continue
+			}
+			// nested document path: #_0.#_1
+			path := strings.Split(strings.TrimSpace(lhs), ".")
+			target := item
+			for _, tok := range path[:len(path)-1] {
+				m, ok := target[resolveName(tok)].(*types.AttributeValueMemberM)
+				if !ok {
+					target = nil
+					break
+				}
+				target = m.Value
 			}
+			attr := resolveName(path[len(path)-1])
+			if target == nil || (ifMissing && target[attr] != nil) {
+				continue
+			}
+			target[attr] = val
 		}
 	}
 
@@ -132,6 +154,8 @@ func applyUpdateExpression(
 
 // filterItems applies a FilterExpression (simplified) to a list of items.
 // Handles: attr = :val, attribute_exists, attribute_not_exists, begins_with, AND/OR clauses.
+var reBetween = regexp.MustCompile(`(\S+) BETWEEN (\S+) AND (\S+)`)
+
 func filterItems(
 	items []map[string]types.AttributeValue,
 	filterExpr string,
@@ -164,6 +188,8 @@ func evalFilter(
 	if expr == "" {
 		return true
 	}
+	// attr BETWEEN :lo AND :hi → (attr >= :lo and attr <= :hi)
+	expr = reBetween.ReplaceAllString(expr, "($1 >= $2 and $1 <= $3)")
 
 	// strip outer parens
 	if strings.HasPrefix(expr, "(") && strings.HasSuffix(expr, ")") {
@@ -246,6 +272,14 @@ func evalFilter(
 			attr := resolveName(inner[:commIdx])
 			valTok := strings.TrimSpace(inner[commIdx+1:])
 			needle := avStr(resolveVal(valTok))
+			switch av := item[attr].(type) {
+			case *types.AttributeValueMemberL:
+				return slices.ContainsFunc(av.Value, func(v types.AttributeValue) bool { return avStr(v) == needle })
+			case *types.AttributeValueMemberSS:
+				return slices.Contains(av.Value, needle)
+			case *types.AttributeValueMemberNS:
+				return slices.Contains(av.Value, needle)
+			}
 			return strings.Contains(getItemVal(attr), needle)
 		}
 	}
@@ -401,7 +435,25 @@ func (m *fullMock) GetItem(_ context.Context, p *ddb.GetItemInput, _ ...func(*dd
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 	item := m.tbl(deref(p.TableName))[itemKey(p.Key)]
-	return &ddb.GetItemOutput{Item: item}, nil
+	return &ddb.GetItemOutput{Item: project(item, p.ProjectionExpression, p.ExpressionAttributeNames)}, nil
+}
+
+// project keeps the top-level attributes named by a ProjectionExpression.
+func project(item map[string]types.AttributeValue, expr *string, names map[string]string) map[string]types.AttributeValue {
+	if item == nil || deref(expr) == "" {
+		return item
+	}
+	out := map[string]types.AttributeValue{}
+	for tok := range strings.SplitSeq(*expr, ",") {
+		tok = strings.TrimSpace(tok)
+		if name, ok := names[tok]; ok {
+			tok = name
+		}
+		if v, ok := item[tok]; ok {
+			out[tok] = v
+		}
+	}
+	return out
 }
 
 func (m *fullMock) DeleteItem(_ context.Context, p *ddb.DeleteItemInput, _ ...func(*ddb.Options)) (*ddb.DeleteItemOutput, error) {
@@ -410,6 +462,15 @@ func (m *fullMock) DeleteItem(_ context.Context, p *ddb.DeleteItemInput, _ ...fu
 	t := m.tbl(deref(p.TableName))
 	k := itemKey(p.Key)
 	prior := t[k]
+	if cond := deref(p.ConditionExpression); cond != "" {
+		existing := prior
+		if existing == nil {
+			existing = map[string]types.AttributeValue{}
+		}
+		if !conditionPasses(existing, cond, p.ExpressionAttributeNames, p.ExpressionAttributeValues) {
+			return nil, errors.New("ConditionalCheckFailedException: condition not met")
+		}
+	}
 	delete(t, k)
 	return &ddb.DeleteItemOutput{Attributes: prior}, nil
 }
@@ -458,6 +519,19 @@ func (m *fullMock) Query(_ context.Context, p *ddb.QueryInput, _ ...func(*ddb.Op
 		}
 	}
 	items := filterItems(all, combined, p.ExpressionAttributeNames, p.ExpressionAttributeValues)
+	// order by key like DynamoDB orders by sort key within a partition
+	slices.SortFunc(items, func(a, b map[string]types.AttributeValue) int {
+		return strings.Compare(itemKey(a), itemKey(b))
+	})
+	if p.ScanIndexForward != nil && !*p.ScanIndexForward {
+		slices.Reverse(items)
+	}
+	for i, item := range items {
+		items[i] = project(item, p.ProjectionExpression, p.ExpressionAttributeNames)
+	}
+	if p.Select == types.SelectCount {
+		return &ddb.QueryOutput{Count: int32(len(items))}, nil
+	}
 	return &ddb.QueryOutput{Items: items, Count: int32(len(items))}, nil
 }
 
@@ -469,6 +543,9 @@ func (m *fullMock) Scan(_ context.Context, p *ddb.ScanInput, _ ...func(*ddb.Opti
 		all = append(all, v)
 	}
 	items := filterItems(all, deref(p.FilterExpression), p.ExpressionAttributeNames, p.ExpressionAttributeValues)
+	if p.Select == types.SelectCount {
+		return &ddb.ScanOutput{Count: int32(len(items)), ScannedCount: int32(len(all))}, nil
+	}
 	return &ddb.ScanOutput{Items: items, Count: int32(len(items)), ScannedCount: int32(len(all))}, nil
 }
 
@@ -556,6 +633,15 @@ func (m *fullMock) TransactWriteItems(_ context.Context, p *ddb.TransactWriteIte
 					return nil, errors.New("TransactionCanceledException: condition failed for Delete")
 				}
 			}
+		case ti.ConditionCheck != nil:
+			existing := m.tbl(deref(ti.ConditionCheck.TableName))[itemKey(ti.ConditionCheck.Key)]
+			if existing == nil {
+				existing = map[string]types.AttributeValue{}
+			}
+			if !conditionPasses(existing, deref(ti.ConditionCheck.ConditionExpression),
+				ti.ConditionCheck.ExpressionAttributeNames, ti.ConditionCheck.ExpressionAttributeValues) {
+				return nil, errors.New("TransactionCanceledException: condition failed for ConditionCheck")
+			}
 		}
 	}
 	// second pass: apply
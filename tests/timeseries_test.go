@@ -0,0 +1,84 @@
+package tests
+
+import (
+	"testing"
+	"time"
+
+	ot "github.com/cloudxsgmbh/dynamodb-onetable-go"
+)
+
+var ReadingSchema = &ot.SchemaDef{
+	Version: "0.0.1",
+	Indexes: map[string]*ot.IndexDef{"primary": {Hash: "pk", Sort: "sk"}},
+	Models: map[string]ot.ModelDef{
+		"Reading": {
+			"pk":     {Type: ot.FieldTypeString, Value: "${_type}#${device}#${month}"},
+			"time":   {Type: ot.FieldTypeDate, Map: "sk"},
+			"device": {Type: ot.FieldTypeString, Required: true},
+			"month":  {Type: ot.FieldTypeString},
+			"value":  {Type: ot.FieldTypeNumber},
+		},
+	},
+}
+
+func makeReadings(t *testing.T) *ot.TimeSeries {
+	t.Helper()
+	tbl, _ := makeTable(t, "ReadingTable", ReadingSchema, false)
+	model, _ := tbl.GetModel("Reading")
+	ts, err := ot.NewTimeSeries(model, ot.TimeSeriesParams{TimeField: "time", BucketField: "month"})
+	if err != nil {
+		t.Fatalf("NewTimeSeries: %v", err)
+	}
+	for i, at := range []string{
+		"2026-01-30T10:00:00Z", "2026-01-31T23:59:00Z",
+		"2026-02-01T00:01:00Z", "2026-02-15T12:00:00Z",
+		"2026-03-02T08:00:00Z", "2026-04-01T00:00:00Z",
+	} {
+		when, _ := time.Parse(time.RFC3339, at)
+		if _, err := ts.Write(bg(), ot.Item{"device": "d1", "time": when, "value": i}, nil); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+	return ts
+}
+
+func TestTimeSeries_QueryAcrossBuckets(t *testing.T) {
+	ts := makeReadings(t)
+	from, _ := time.Parse(time.RFC3339, "2026-01-31T00:00:00Z")
+	to, _ := time.Parse(time.RFC3339, "2026-03-31T00:00:00Z")
+
+	result, err := ts.Query(bg(), ot.Item{"device": "d1"}, from, to, nil)
+	if err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+	assertLen(t, result.Items, 4)
+	for i, item := range result.Items {
+		assertNum(t, item, "value", float64(i+1))
+	}
+	assertStr(t, result.Items[0], "month", "202601")
+	assertStr(t, result.Items[3], "month", "202603")
+
+	result, err = ts.Query(bg(), ot.Item{"device": "d1"}, from, to, &ot.Params{Reverse: true, Limit: 3})
+	if err != nil {
+		t.Fatalf("Query reverse: %v", err)
+	}
+	assertLen(t, result.Items, 3)
+	for i, item := range result.Items {
+		assertNum(t, item, "value", float64(4-i))
+	}
+}
+
+func TestTimeSeries_InvalidModel(t *testing.T) {
+	tbl, _ := makeTable(t, "ReadingTable", ReadingSchema, false)
+	model, _ := tbl.GetModel("Reading")
+	cases := []ot.TimeSeriesParams{
+		{TimeField: "value", BucketField: "month"},
+		{TimeField: "time", BucketField: "value"},
+		{TimeField: "time", BucketField: "month", Period: "week"},
+	}
+	for _, params := range cases {
+		if _, err := ot.NewTimeSeries(model, params); err == nil {
+			t.Errorf("expected error for %+v", params)
+		}
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"testing"
 	"time"
 
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
 	ot "github.com/cloudxsgmbh/dynamodb-onetable-go"
 )
 
@@ -68,3 +70,135 @@ func TestTimestamps_DefaultFields(t *testing.T) {
 	assertDate(t, user["created"])
 	assertDate(t, user["updated"])
 }
+
+var SessionSchema = &ot.SchemaDef{
+	Version: "0.0.1",
+	Indexes: map[string]*ot.IndexDef{"primary": {Hash: "pk", Sort: "sk"}},
+	Models: map[string]ot.ModelDef{
+		"Session": {
+			"pk":      {Type: ot.FieldTypeString, Value: "Session#${user}"},
+			"sk":      {Type: ot.FieldTypeString, Value: "Session#${id}"},
+			"user":    {Type: ot.FieldTypeString},
+			"id":      {Type: ot.FieldTypeString},
+			"expires": {Type: ot.FieldTypeDate, TTL: true, Default: ot.ExpiresIn(time.Hour)},
+		},
+	},
+}
+
+func TestTimestamps_HideExpired(t *testing.T) {
+	mock := newFullMock()
+	tbl, err := ot.NewTable(ot.TableParams{Name: "SessionTable", Client: mock, Schema: SessionSchema, HideExpired: true})
+	if err != nil {
+		t.Fatalf("NewTable: %v", err)
+	}
+	live, err := tbl.Create(bg(), "Session", ot.Item{"user": "u1", "id": "live"}, nil)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	expires, _ := live["expires"].(time.Time)
+	if d := time.Until(expires); d < 59*time.Minute || d > 61*time.Minute {
+		t.Errorf("expected ExpiresIn default about an hour out, got %v", expires)
+	}
+	_, err = tbl.Create(bg(), "Session", ot.Item{"user": "u1", "id": "stale", "expires": ot.ExpiresIn(-time.Minute)}, nil)
+	if err != nil {
+		t.Fatalf("Create expired: %v", err)
+	}
+
+	item, err := tbl.Get(bg(), "Session", ot.Item{"user": "u1", "id": "stale"}, nil)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if item != nil {
+		t.Errorf("expected expired item to be hidden, got %v", item)
+	}
+	result, err := tbl.Find(bg(), "Session", ot.Item{"user": "u1"}, nil)
+	if err != nil {
+		t.Fatalf("Find: %v", err)
+	}
+	assertLen(t, result.Items, 1)
+	assertStr(t, result.Items[0], "id", "live")
+
+	// per-call override
+	result, err = tbl.Find(bg(), "Session", ot.Item{"user": "u1"}, &ot.Params{HideExpired: falsePtr()})
+	if err != nil {
+		t.Fatalf("Find with expired: %v", err)
+	}
+	assertLen(t, result.Items, 2)
+}
+
+func TestTimestamps_Overrides(t *testing.T) {
+	tbl, _ := makeTable(t, "TimestampsTable", TimestampsSchema, false)
+	created := time.Date(2019, 5, 1, 8, 0, 0, 0, time.UTC)
+	updated := time.Date(2020, 6, 2, 9, 30, 0, 0, time.UTC)
+
+	// replayed items keep their audit times
+	user, err := tbl.Create(bg(), "User", ot.Item{"name": "Peter Smith", "createdAt": created, "updatedAt": updated},
+		&ot.Params{SkipTimestamps: true})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if got, _ := user["createdAt"].(time.Time); !got.Equal(created) {
+		t.Errorf("createdAt = %v, want %v", got, created)
+	}
+	if got, _ := user["updatedAt"].(time.Time); !got.Equal(updated) {
+		t.Errorf("updatedAt = %v, want %v", got, updated)
+	}
+
+	// SkipTimestamps leaves updatedAt alone on update
+	user, err = tbl.Update(bg(), "User", ot.Item{"id": user["id"], "name": "Marcelo"}, &ot.Params{SkipTimestamps: true})
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if got, _ := user["updatedAt"].(time.Time); !got.Equal(updated) {
+		t.Errorf("updatedAt = %v, want %v", got, updated)
+	}
+
+	// Timestamps sets the time written instead of now
+	at := time.Date(2021, 7, 3, 10, 0, 0, 0, time.UTC)
+	user, err = tbl.Update(bg(), "User", ot.Item{"id": user["id"], "name": "Ralph"}, &ot.Params{Timestamps: at})
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if got, _ := user["updatedAt"].(time.Time); !got.Equal(at) {
+		t.Errorf("updatedAt = %v, want %v", got, at)
+	}
+	other, _ := tbl.Create(bg(), "User", ot.Item{"name": "Cu Later"}, &ot.Params{Timestamps: at})
+	if got, _ := other["createdAt"].(time.Time); !got.Equal(at) {
+		t.Errorf("createdAt = %v, want %v", got, at)
+	}
+}
+
+func TestTimestamps_IsoDatesOverride(t *testing.T) {
+	tbl, mock := makeTable(t, "TimestampsTable", TimestampsSchema, false)
+	user, err := tbl.Create(bg(), "User", ot.Item{"name": "Peter Smith"}, &ot.Params{IsoDates: truePtr()})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	key := "User#" + user["id"].(string) + "||User#"
+	raw := mock.tbl("TimestampsTable")[key]
+	for _, name := range []string{"createdAt", "updatedAt"} {
+		if _, err := time.Parse(time.RFC3339Nano, avStr(raw[name])); err != nil {
+			t.Errorf("%s not stored as an ISO date: %#v", name, raw[name])
+		}
+	}
+	if _, err := tbl.Update(bg(), "User", ot.Item{"id": user["id"], "name": "Marcelo"}, nil); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if updated := mock.tbl("TimestampsTable")[key]["updatedAt"]; !isNum(updated) {
+		t.Errorf("updatedAt not stored as epoch by default: %#v", updated)
+	}
+	got, _ := tbl.Get(bg(), "User", ot.Item{"id": user["id"]}, nil)
+	assertDate(t, got["createdAt"])
+
+	// an item written with the override can be re-keyed
+	User, _ := tbl.GetModel("User")
+	other, _ := User.Create(bg(), ot.Item{"name": "Judy"}, &ot.Params{IsoDates: truePtr()})
+	if _, err := User.ReKey(bg(), ot.Item{"id": other["id"]}, ot.Item{"email": "judy@example.com"}, nil); err != nil {
+		t.Errorf("ReKey: %v", err)
+	}
+}
+
+func isNum(av types.AttributeValue) bool {
+	_, ok := av.(*types.AttributeValueMemberN)
+	return ok
+}
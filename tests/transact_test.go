@@ -2,6 +2,9 @@
 package tests
 
 import (
+	"errors"
+	"maps"
+	"slices"
 	"testing"
 
 	ot "github.com/cloudxsgmbh/dynamodb-onetable-go"
@@ -120,3 +123,52 @@ func TestTransact_GetWithoutParse(t *testing.T) {
 		t.Error("expected Responses")
 	}
 }
+
+func TestTransact_Check(t *testing.T) {
+	tbl, _ := makeTable(t, "TransactTable", DefaultSchema, false)
+	account, _ := tbl.Create(bg(), "User", ot.Item{"name": "Peter Smith", "status": "active"}, nil)
+	member, _ := tbl.Create(bg(), "User", ot.Item{"name": "Patty O'Furniture", "status": "idle"}, nil)
+
+	// the update only applies while the account is active
+	transaction := map[string]any{}
+	if _, err := tbl.Check(bg(), "User", ot.Item{"id": account["id"]},
+		&ot.Params{Transaction: transaction, Where: "${status} = {active}"}); err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+	tbl.Update(bg(), "User", ot.Item{"id": member["id"], "status": "active"}, &ot.Params{Transaction: transaction}) //nolint
+	items := transaction["TransactItems"].([]any)
+	check := items[0].(map[string]any)["ConditionCheck"].(ot.Item)
+	for _, key := range slices.Sorted(maps.Keys(check)) {
+		if !slices.Contains([]string{"TableName", "Key", "ConditionExpression", "ExpressionAttributeNames", "ExpressionAttributeValues"}, key) {
+			t.Errorf("unexpected ConditionCheck field %s", key)
+		}
+	}
+	assertContains(t, check["ConditionExpression"].(string), "= :_")
+	if _, err := tbl.Transact(bg(), "write", transaction, nil); err != nil {
+		t.Fatalf("Transact: %v", err)
+	}
+	got, _ := tbl.Get(bg(), "User", ot.Item{"id": member["id"]}, nil)
+	assertStr(t, got, "status", "active")
+
+	// a failing check cancels the transaction
+	transaction = map[string]any{}
+	tbl.Check(bg(), "User", ot.Item{"id": account["id"]}, &ot.Params{Transaction: transaction, Where: "${status} = {suspended}"}) //nolint
+	tbl.Update(bg(), "User", ot.Item{"id": member["id"], "status": "idle"}, &ot.Params{Transaction: transaction})                 //nolint
+	if _, err := tbl.Transact(bg(), "write", transaction, nil); err == nil {
+		t.Fatal("expected the transaction to be canceled")
+	}
+	got, _ = tbl.Get(bg(), "User", ot.Item{"id": member["id"]}, nil)
+	assertStr(t, got, "status", "active")
+
+	var argErr *ot.OneTableArgError
+	for name, params := range map[string]*ot.Params{
+		"no transaction": {Where: "${status} = {active}"},
+		"no condition":   {Transaction: map[string]any{}},
+		"set":            {Transaction: map[string]any{}, Exists: truePtr(), Set: map[string]string{"status": "{x}"}},
+		"return":         {Transaction: map[string]any{}, Exists: truePtr(), Return: "ALL_NEW"},
+	} {
+		if _, err := tbl.Check(bg(), "User", ot.Item{"id": account["id"]}, params); !errors.As(err, &argErr) {
+			t.Errorf("%s: expected OneTableArgError, got %v", name, err)
+		}
+	}
+}
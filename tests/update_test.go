@@ -2,7 +2,14 @@
 package tests
 
 import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
 	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 
 	ot "github.com/cloudxsgmbh/dynamodb-onetable-go"
 )
@@ -73,3 +80,424 @@ func TestUpdate_MultipleUsers(t *testing.T) {
 	}
 	assertLen(t, result.Items, 3)
 }
+
+func TestUpdate_EscapedDotPath(t *testing.T) {
+	tbl, _ := makeTable(t, "UpdateTable", DefaultSchema, false)
+	noExec := false
+	cmd, err := tbl.Update(bg(), "User", ot.Item{"id": "u1"}, &ot.Params{
+		Set:     map[string]string{`profile.meta\.source`: "{csv}"},
+		Where:   "${" + ot.EscapePath("import.batch") + "} = {7}",
+		Execute: &noExec,
+	})
+	if err != nil {
+		t.Fatalf("Update escaped path: %v", err)
+	}
+	names, _ := cmd["ExpressionAttributeNames"].(map[string]string)
+	found := map[string]bool{}
+	for _, n := range names {
+		found[n] = true
+	}
+	for _, want := range []string{"profile", "meta.source", "import.batch"} {
+		if !found[want] {
+			t.Errorf("expected attribute name %q in %v", want, names)
+		}
+	}
+	for _, bad := range []string{"meta", "source", "import", "batch"} {
+		if found[bad] {
+			t.Errorf("unexpected split attribute name %q in %v", bad, names)
+		}
+	}
+}
+
+func TestUpdate_EscapePath(t *testing.T) {
+	cases := map[string]string{
+		"name":     "name",
+		"a.b":      `a\.b`,
+		`dir\file`: `dir\\file`,
+	}
+	for in, want := range cases {
+		if got := ot.EscapePath(in); got != want {
+			t.Errorf("EscapePath(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestUpdate_Condition(t *testing.T) {
+	tbl, _ := makeTable(t, "UpdateTable", DefaultSchema, false)
+	user, err := tbl.Create(bg(), "User", ot.Item{"name": "Peter Smith", "status": "idle"}, nil)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	// conditions compose with the implicit exists check
+	noExec := false
+	cmd, err := tbl.Create(bg(), "User", ot.Item{"name": "Ann", "status": "idle"}, &ot.Params{
+		Condition: "${status} <> {banned}", Execute: &noExec,
+	})
+	if err != nil {
+		t.Fatalf("Create command: %v", err)
+	}
+	cond, _ := cmd["ConditionExpression"].(string)
+	assertContains(t, cond, "(attribute_not_exists(#_0)) and (attribute_not_exists(#_1)) and (")
+	assertContains(t, cond, " <> ")
+
+	// update: condition fails, then passes
+	_, err = tbl.Update(bg(), "User", ot.Item{"id": user["id"], "age": 30},
+		&ot.Params{Condition: "${status} = {active}"})
+	if err == nil {
+		t.Fatal("expected conditional update to fail")
+	}
+	updated, err := tbl.Update(bg(), "User", ot.Item{"id": user["id"], "age": 30},
+		&ot.Params{Condition: "${status} = {idle}"})
+	if err != nil {
+		t.Fatalf("conditional update: %v", err)
+	}
+	assertNum(t, updated, "age", 30)
+
+	// delete: condition fails, then passes
+	_, err = tbl.Remove(bg(), "User", ot.Item{"id": user["id"]}, &ot.Params{Condition: "${age} = {31}"})
+	if err == nil {
+		t.Fatal("expected conditional remove to fail")
+	}
+	if _, err = tbl.Remove(bg(), "User", ot.Item{"id": user["id"]}, &ot.Params{Condition: "${age} = {30}"}); err != nil {
+		t.Fatalf("conditional remove: %v", err)
+	}
+
+	// not a read option
+	_, err = tbl.Find(bg(), "User", ot.Item{"id": user["id"]}, &ot.Params{Condition: "${age} = {30}"})
+	var argErr *ot.OneTableArgError
+	if !errors.As(err, &argErr) {
+		t.Errorf("expected OneTableArgError for Condition on find, got %v", err)
+	}
+}
+
+func TestUpdate_ConditionLogicOr(t *testing.T) {
+	tbl, _ := makeTable(t, "UpdateTable", DefaultSchema, false)
+	// create unless it exists, or overwrite if we own it
+	claim := func(id, owner string) error {
+		_, err := tbl.Create(bg(), "User", ot.Item{"id": id, "name": owner, "status": "owned"}, &ot.Params{
+			Where:          "${name} = {" + owner + "}",
+			ConditionLogic: "or",
+		})
+		return err
+	}
+	if err := claim("u1", "alice"); err != nil {
+		t.Fatalf("first claim: %v", err)
+	}
+	if err := claim("u1", "alice"); err != nil {
+		t.Errorf("owner re-claim should pass: %v", err)
+	}
+	if err := claim("u1", "bob"); err == nil {
+		t.Error("expected claim by another owner to fail")
+	}
+
+	noExec := false
+	cmd, err := tbl.Create(bg(), "User", ot.Item{"id": "u2", "name": "x"}, &ot.Params{
+		Where: "${name} = {x}", ConditionLogic: "or", Execute: &noExec,
+	})
+	if err != nil {
+		t.Fatalf("Create command: %v", err)
+	}
+	cond, _ := cmd["ConditionExpression"].(string)
+	if !strings.HasPrefix(cond, "((attribute_not_exists(#_0)) and (attribute_not_exists(#_1))) or (") {
+		t.Errorf("unexpected condition %q", cond)
+	}
+
+	_, err = tbl.Update(bg(), "User", ot.Item{"id": "u1", "age": 3}, &ot.Params{ConditionLogic: "xor"})
+	var argErr *ot.OneTableArgError
+	if !errors.As(err, &argErr) {
+		t.Errorf("expected OneTableArgError for invalid ConditionLogic, got %v", err)
+	}
+}
+
+func TestUpdate_ExpressionLimits(t *testing.T) {
+	tbl, _ := makeTable(t, "UpdateTable", DefaultSchema, false)
+	user, _ := tbl.Create(bg(), "User", ot.Item{"name": "Peter Smith"}, nil)
+
+	set := map[string]string{}
+	for i := range 320 {
+		set[fmt.Sprintf("counter%d", i)] = "{1}"
+	}
+	_, err := tbl.Update(bg(), "User", ot.Item{"id": user["id"]}, &ot.Params{Set: set})
+	assertErrCode(t, err, ot.ErrArgument)
+	assertContains(t, err.Error(), "UpdateExpression")
+
+	sum := make([]string, 0, 310)
+	for i := range 310 {
+		sum = append(sum, fmt.Sprintf("${part%d}", i))
+	}
+	_, err = tbl.Update(bg(), "User", ot.Item{"id": user["id"]}, &ot.Params{
+		Set: map[string]string{"profile.total": strings.Join(sum, " + "), "age": "{3}"},
+	})
+	assertErrCode(t, err, ot.ErrArgument)
+	fields, _ := err.(*ot.OneTableError).Context["fields"].([]string)
+	if len(fields) == 0 || !strings.HasPrefix(fields[0], "profile.total ") {
+		t.Errorf("expected profile.total as largest term, got %v", fields)
+	}
+}
+
+// opCounter counts the operations it sees by name.
+type opCounter struct {
+	mu  sync.Mutex
+	ops map[string]int
+}
+
+func (c *opCounter) Add(_, op string, _ ot.Item, _ *ot.Params, _ time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ops[op]++
+	return nil
+}
+
+func (c *opCounter) Flush() error { return nil }
+
+func TestUpdate_ChunkUpdates(t *testing.T) {
+	counter := &opCounter{ops: map[string]int{}}
+	mock := newFullMock()
+	tbl, err := ot.NewTable(ot.TableParams{Name: "ChunkTable", Client: mock, Schema: DefaultSchema, Metrics: counter})
+	if err != nil {
+		t.Fatalf("NewTable: %v", err)
+	}
+	user, _ := tbl.Create(bg(), "User", ot.Item{"name": "Peter Smith"}, nil)
+
+	set := map[string]string{}
+	for i := range 320 {
+		set[fmt.Sprintf("counter%d", i)] = "{1}"
+	}
+
+	// the conditional check on the first chunk stops the whole update
+	_, err = tbl.Update(bg(), "User", ot.Item{"id": user["id"], "name": "Ann"}, &ot.Params{
+		Set: set, ChunkUpdates: true, Where: "${name} = {Nobody}",
+	})
+	if err == nil {
+		t.Fatal("expected conditional failure")
+	}
+	for _, item := range mock.tbl("ChunkTable") {
+		if item["counter319"] != nil {
+			t.Error("expected no chunk to be written after a failed condition")
+		}
+	}
+
+	updated, err := tbl.Update(bg(), "User", ot.Item{"id": user["id"], "name": "Ann"}, &ot.Params{
+		Set: set, ChunkUpdates: true, Where: "${name} = {Peter Smith}",
+	})
+	if err != nil {
+		t.Fatalf("chunked Update: %v", err)
+	}
+	if counter.ops["update"] < 2 {
+		t.Errorf("expected several update calls, got %d", counter.ops["update"])
+	}
+	assertStr(t, updated, "name", "Ann")
+	written := 0
+	for _, item := range mock.tbl("ChunkTable") {
+		if item["counter0"] != nil && item["counter319"] != nil {
+			written++
+		}
+	}
+	if written != 1 {
+		t.Errorf("expected every chunk to be written, got %d complete items", written)
+	}
+
+	_, err = tbl.Update(bg(), "User", ot.Item{"id": user["id"]}, &ot.Params{
+		Set: set, ChunkUpdates: true, Transaction: map[string]any{},
+	})
+	var argErr *ot.OneTableArgError
+	if !errors.As(err, &argErr) {
+		t.Errorf("expected OneTableArgError for ChunkUpdates in a transaction, got %v", err)
+	}
+}
+
+func TestUpdate_ErrorCommandSnapshot(t *testing.T) {
+	tbl, _ := makeTable(t, "UpdateTable", DefaultSchema, false)
+	user, _ := tbl.Create(bg(), "User", ot.Item{"name": "Peter Smith", "status": "active"}, nil)
+
+	_, err := tbl.Update(bg(), "User", ot.Item{"id": user["id"], "status": "secret-status"},
+		&ot.Params{Where: "${status} = {inactive}"})
+	var otErr *ot.OneTableError
+	if !errors.As(err, &otErr) {
+		t.Fatalf("expected OneTableError, got %v", err)
+	}
+	cmd, _ := otErr.Context["command"].(map[string]any)
+	if cmd == nil {
+		t.Fatalf("expected a command snapshot, got %v", otErr.Context)
+	}
+	if cmd["op"] != "update" || cmd["TableName"] != "UpdateTable" {
+		t.Errorf("unexpected snapshot %v", cmd)
+	}
+	assertContains(t, cmd["ConditionExpression"].(string), "= :_")
+	assertContains(t, cmd["UpdateExpression"].(string), "set ")
+	key, _ := cmd["Key"].(ot.Item)
+	assertStr(t, key, "pk", "User#"+user["id"].(string))
+	values, _ := cmd["ExpressionAttributeValues"].(map[string]string)
+	if len(values) == 0 {
+		t.Fatalf("expected redacted values, got %v", cmd["ExpressionAttributeValues"])
+	}
+	for ref, v := range values {
+		if v != "S" {
+			t.Errorf("expected value %s redacted to its type, got %q", ref, v)
+		}
+	}
+	if strings.Contains(fmt.Sprint(cmd), "secret-status") {
+		t.Error("snapshot leaks an expression value")
+	}
+}
+
+func TestUpdate_UpdateByKey(t *testing.T) {
+	tbl, _ := makeTable(t, "UpdateTable", DefaultSchema, false)
+	user, _ := tbl.Create(bg(), "User", ot.Item{"name": "Peter Smith", "email": "peter@example.com", "status": "active", "age": float64(20)}, nil)
+	model, _ := tbl.GetModel("User")
+	key := ot.Item{"pk": "User#" + user["id"].(string), "sk": "User#"}
+
+	set := map[string]string{"status": "{suspended}"}
+	updated, err := model.UpdateByKey(bg(), key, ot.UpdateOps{Set: set, Remove: []string{"email"}},
+		&ot.Params{Where: "${status} = {active}"})
+	if err != nil {
+		t.Fatalf("UpdateByKey: %v", err)
+	}
+	assertStr(t, updated, "status", "suspended")
+	assertStr(t, updated, "name", "Peter Smith")
+	assertAbsent(t, updated, "email")
+	if len(set) != 1 {
+		t.Errorf("caller's Set was modified: %v", set)
+	}
+
+	// only the operations and the updated timestamp are written
+	noExec := false
+	cmd, _ := model.UpdateByKey(bg(), key, ot.UpdateOps{Add: map[string]any{"age": 1}}, &ot.Params{Execute: &noExec})
+	names := fmt.Sprint(cmd["ExpressionAttributeNames"])
+	if strings.Contains(names, "_type") || !strings.Contains(names, "updated") || !strings.Contains(names, "age") {
+		t.Errorf("unexpected attribute names %s", names)
+	}
+
+	// the where condition no longer holds
+	_, err = model.UpdateByKey(bg(), key, ot.UpdateOps{Set: set}, &ot.Params{Where: "${status} = {active}"})
+	assertErrCode(t, err, ot.ErrRuntime)
+
+	// the item must exist
+	_, err = model.UpdateByKey(bg(), ot.Item{"pk": "User#missing", "sk": "User#"}, ot.UpdateOps{Set: set}, nil)
+	assertErrCode(t, err, ot.ErrRuntime)
+
+	var argErr *ot.OneTableArgError
+	for name, call := range map[string]func() error{
+		"no ops": func() error { _, err := model.UpdateByKey(bg(), key, ot.UpdateOps{}, nil); return err },
+		"no sort": func() error {
+			_, err := model.UpdateByKey(bg(), ot.Item{"pk": key["pk"]}, ot.UpdateOps{Set: set}, nil)
+			return err
+		},
+		"extra key": func() error {
+			_, err := model.UpdateByKey(bg(), ot.Item{"pk": key["pk"], "sk": "User#", "id": "x"}, ot.UpdateOps{Set: set}, nil)
+			return err
+		},
+	} {
+		if err := call(); !errors.As(err, &argErr) {
+			t.Errorf("%s: expected OneTableArgError, got %v", name, err)
+		}
+	}
+}
+
+func TestUpdate_ReturnValues(t *testing.T) {
+	tbl, _ := makeTable(t, "UpdateTable", DefaultSchema, false)
+	user, _ := tbl.Create(bg(), "User", ot.Item{"name": "Peter Smith", "status": "active", "age": float64(20)}, nil)
+	noExec := false
+
+	for _, tc := range []struct {
+		op     string
+		ret    any
+		expect string
+	}{
+		{"update", true, "ALL_NEW"},
+		{"update", false, "NONE"},
+		{"update", ot.ReturnUpdatedOld, "UPDATED_OLD"},
+		{"update", "all_old", "ALL_OLD"},
+		{"update", ot.ReturnGet, "NONE"},
+		{"put", true, "NONE"},
+		{"put", "all_old", "ALL_OLD"},
+		{"delete", true, "ALL_OLD"},
+		{"delete", ot.ReturnNone, "NONE"},
+	} {
+		params := &ot.Params{Execute: &noExec, Return: tc.ret}
+		var cmd ot.Item
+		var err error
+		switch tc.op {
+		case "update":
+			cmd, err = tbl.Update(bg(), "User", ot.Item{"id": user["id"], "age": float64(21)}, params)
+		case "put":
+			cmd, err = tbl.Create(bg(), "User", ot.Item{"name": "Judy Smith"}, params)
+		case "delete":
+			cmd, err = tbl.Remove(bg(), "User", ot.Item{"id": user["id"]}, params)
+		}
+		if err != nil {
+			t.Fatalf("%s %v: %v", tc.op, tc.ret, err)
+		}
+		if cmd["ReturnValues"] != tc.expect {
+			t.Errorf("%s %v: expected ReturnValues %s, got %v", tc.op, tc.ret, tc.expect, cmd["ReturnValues"])
+		}
+	}
+
+	// invalid values are rejected per operation
+	var argErr *ot.OneTableArgError
+	for name, call := range map[string]func() error{
+		"put ALL_NEW": func() error {
+			_, err := tbl.Create(bg(), "User", ot.Item{"name": "Judy Smith"}, &ot.Params{Return: ot.ReturnAllNew})
+			return err
+		},
+		"delete get": func() error {
+			_, err := tbl.Remove(bg(), "User", ot.Item{"id": user["id"]}, &ot.Params{Return: ot.ReturnGet})
+			return err
+		},
+		"update unknown": func() error {
+			_, err := tbl.Update(bg(), "User", ot.Item{"id": user["id"]}, &ot.Params{Return: "everything"})
+			return err
+		},
+		"update type": func() error {
+			_, err := tbl.Update(bg(), "User", ot.Item{"id": user["id"]}, &ot.Params{Return: 1})
+			return err
+		},
+	} {
+		if err := call(); !errors.As(err, &argErr) {
+			t.Errorf("%s: expected OneTableArgError, got %v", name, err)
+		}
+	}
+
+	// get reads the full item back after the update
+	updated, err := tbl.Update(bg(), "User", ot.Item{"id": user["id"], "age": float64(30)},
+		&ot.Params{Return: ot.ReturnGet})
+	if err != nil {
+		t.Fatalf("Update get: %v", err)
+	}
+	assertNum(t, updated, "age", 30)
+	assertStr(t, updated, "name", "Peter Smith")
+}
+
+func TestUpdate_DeterministicExpression(t *testing.T) {
+	tbl, _ := makeTable(t, "UpdateTable", DefaultSchema, false)
+	noExec := false
+	when := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	build := func() string {
+		cmd, err := tbl.Update(bg(), "User", ot.Item{
+			"id": "42", "name": "Peter Smith", "email": "peter@example.com", "status": "active",
+			"profile": map[string]any{"a": 1, "b": 2},
+		}, &ot.Params{
+			Execute:    &noExec,
+			Timestamps: when,
+			Set:        map[string]string{"age": "{21}", "registered": "{2024}"},
+			Add:        map[string]any{"age": 1},
+			Where:      "${status} = {active}",
+		})
+		if err != nil {
+			t.Fatalf("Update: %v", err)
+		}
+		values := map[string]string{}
+		for k, v := range cmd["ExpressionAttributeValues"].(map[string]types.AttributeValue) {
+			values[k] = avStr(v)
+		}
+		return fmt.Sprint(cmd["UpdateExpression"], cmd["ConditionExpression"],
+			cmd["ExpressionAttributeNames"], values)
+	}
+	want := build()
+	for range 20 {
+		if got := build(); got != want {
+			t.Fatalf("expression differs between runs:\n%s\n%s", want, got)
+		}
+	}
+}
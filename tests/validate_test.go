@@ -123,3 +123,69 @@ func TestValidate_Enum(t *testing.T) {
 		t.Fatal("expected error for invalid enum")
 	}
 }
+
+var NestedValidationSchema = &ot.SchemaDef{
+	Version: "0.0.1",
+	Indexes: map[string]*ot.IndexDef{"primary": {Hash: "pk", Sort: "sk"}},
+	Models: map[string]ot.ModelDef{
+		"Order": {
+			"pk": {Type: ot.FieldTypeString, Value: "order#${id}"},
+			"sk": {Type: ot.FieldTypeString, Value: "order#"},
+			"id": {Type: ot.FieldTypeString, Generate: "ulid"},
+			"shipping": {
+				Type:     ot.FieldTypeObject,
+				Required: true,
+				Schema: ot.FieldMap{
+					"zip":     {Type: ot.FieldTypeString, Required: true, Validate: `/^[0-9]{5}$/`},
+					"country": {Type: ot.FieldTypeString, Default: "US", Enum: []string{"US", "CA"}},
+				},
+			},
+			"lines": {
+				Type: ot.FieldTypeArray,
+				Items: &ot.ItemsDef{Schema: ot.FieldMap{
+					"sku": {Type: ot.FieldTypeString, Required: true},
+					"qty": {Type: ot.FieldTypeNumber},
+				}},
+			},
+		},
+	},
+}
+
+func TestValidate_Nested(t *testing.T) {
+	tbl, _ := makeTable(t, "ValidateTable", NestedValidationSchema, false)
+	order, err := tbl.Create(bg(), "Order", ot.Item{
+		"shipping": ot.Item{"zip": "98011"},
+		"lines":    []ot.Item{{"sku": "A1", "qty": 2}},
+	}, nil)
+	if err != nil {
+		t.Fatalf("Create valid: %v", err)
+	}
+	shipping, _ := order["shipping"].(ot.Item)
+	assertStr(t, shipping, "country", "US")
+	if lines := toAnySlice(order["lines"]); len(lines) != 1 {
+		t.Errorf("expected 1 order line, got %v", order["lines"])
+	}
+
+	_, err = tbl.Create(bg(), "Order", ot.Item{
+		"shipping": ot.Item{"zip": "980", "country": "MX"},
+		"lines":    []any{ot.Item{"sku": "A1"}, ot.Item{"qty": 1}},
+	}, nil)
+	assertErrCode(t, err, ot.ErrValidation)
+	validation, _ := err.(*ot.OneTableError).Context["validation"].(map[string]string)
+	for _, key := range []string{"shipping.zip", "shipping.country", "lines[1].sku"} {
+		if validation[key] == "" {
+			t.Errorf("expected validation error for %q in %v", key, validation)
+		}
+	}
+	if len(validation) != 3 {
+		t.Errorf("unexpected validation errors %v", validation)
+	}
+
+	// a missing required object is checked against its own required fields
+	_, err = tbl.Create(bg(), "Order", ot.Item{}, nil)
+	assertErrCode(t, err, ot.ErrValidation)
+	validation, _ = err.(*ot.OneTableError).Context["validation"].(map[string]string)
+	if validation["shipping.zip"] == "" || len(validation) != 1 {
+		t.Errorf("expected only shipping.zip to be required, got %v", validation)
+	}
+}
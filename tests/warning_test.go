@@ -0,0 +1,75 @@
+package tests
+
+import (
+	"testing"
+
+	ot "github.com/cloudxsgmbh/dynamodb-onetable-go"
+)
+
+var WarningSchema = &ot.SchemaDef{
+	Version: "0.0.1",
+	Indexes: map[string]*ot.IndexDef{
+		"primary": {Hash: "pk", Sort: "sk"},
+		"gs1":     {Hash: "gs1pk", Sort: "gs1sk", Project: "keys"},
+	},
+	Models: map[string]ot.ModelDef{
+		"Account": {
+			"pk":    {Type: ot.FieldTypeString, Value: "account#${id}"},
+			"sk":    {Type: ot.FieldTypeString, Value: "account#"},
+			"gs1pk": {Type: ot.FieldTypeString, Value: "account"},
+			"gs1sk": {Type: ot.FieldTypeString, Value: "account#${id}"},
+			"id":    {Type: ot.FieldTypeString, Required: true},
+			"name":  {Type: ot.FieldTypeString, Required: true},
+		},
+	},
+}
+
+func TestWarning_MissingRequired(t *testing.T) {
+	var warnings []ot.Warning
+	mock := newFullMock()
+	tbl, err := ot.NewTable(ot.TableParams{Name: "WarnTable", Client: mock, Schema: WarningSchema, Warn: true,
+		OnWarning: func(w ot.Warning) { warnings = append(warnings, w) }})
+	if err != nil {
+		t.Fatalf("NewTable: %v", err)
+	}
+	Account, _ := tbl.GetModel("Account")
+	if _, err := Account.Create(bg(), ot.Item{"id": "1", "name": "Acme"}, nil); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	// projected and keys-only reads legitimately lack the name
+	if _, err := Account.Get(bg(), ot.Item{"id": "1"}, &ot.Params{Fields: []string{"id"}}); err != nil {
+		t.Fatalf("Get fields: %v", err)
+	}
+	follow := false
+	if _, err := Account.Find(bg(), ot.Item{}, &ot.Params{Index: "gs1", Follow: &follow}); err != nil {
+		t.Fatalf("Find gs1: %v", err)
+	}
+	if len(warnings) != 0 {
+		t.Fatalf("unexpected warnings: %v", warnings)
+	}
+
+	// a full read of an item without the name is reported once
+	for _, item := range mock.tbl("WarnTable") {
+		delete(item, "name")
+	}
+	if _, err := Account.Get(bg(), ot.Item{"id": "1"}, nil); err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if len(warnings) != 1 {
+		t.Fatalf("expected 1 warning, got %v", warnings)
+	}
+	w := warnings[0]
+	if w.Code != ot.WarnMissingRequired || w.Model != "Account" || w.Field != "name" || w.Op != "get" {
+		t.Errorf("unexpected warning: %+v", w)
+	}
+
+	// without Warn nothing is reported
+	tbl, _ = ot.NewTable(ot.TableParams{Name: "WarnTable", Client: mock, Schema: WarningSchema,
+		OnWarning: func(w ot.Warning) { warnings = append(warnings, w) }})
+	Account, _ = tbl.GetModel("Account")
+	Account.Get(bg(), ot.Item{"id": "1"}, nil) //nolint
+	if len(warnings) != 1 {
+		t.Errorf("warning reported without Warn: %v", warnings)
+	}
+}
@@ -0,0 +1,194 @@
+/*
+Package onetable – sharded time-series helper.
+
+A time-series model stores events under a partition key that includes a time
+bucket, e.g. "${_type}#${device}#${month}", so that no single partition grows
+without bound. The sort key holds the event time. TimeSeries derives the bucket
+on write and fans a time-range query out over every bucket it spans.
+*/
+package onetable
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+)
+
+// Time-series bucket periods.
+const (
+	PeriodHour  = "hour"
+	PeriodDay   = "day"
+	PeriodMonth = "month"
+	PeriodYear  = "year"
+)
+
+// bucketLayouts are the time layouts used to format bucket values.
+var bucketLayouts = map[string]string{
+	PeriodHour:  "2006010215",
+	PeriodDay:   "20060102",
+	PeriodMonth: "200601",
+	PeriodYear:  "2006",
+}
+
+// TimeSeriesParams configures a TimeSeries.
+type TimeSeriesParams struct {
+	// TimeField is the date field holding the event time. It must map to the
+	// primary sort key.
+	TimeField string
+	// BucketField is the string field holding the bucket. It must be referenced
+	// by the primary hash key value template.
+	BucketField string
+	// Period is the bucket width: "hour", "day", "month" (default) or "year".
+	Period string
+}
+
+// TimeSeries writes and queries a model whose partitions are time buckets.
+type TimeSeries struct {
+	model       *Model
+	timeField   string
+	bucketField string
+	period      string
+}
+
+// NewTimeSeries validates that model is laid out as a time series and returns
+// a helper for it.
+func NewTimeSeries(model *Model, params TimeSeriesParams) (*TimeSeries, error) {
+	if model == nil {
+		return nil, NewArgError("Missing time-series model")
+	}
+	period := params.Period
+	if period == "" {
+		period = PeriodMonth
+	}
+	if bucketLayouts[period] == "" {
+		return nil, NewArgError(fmt.Sprintf(`Unknown time-series period "%s"`, period))
+	}
+	fields := model.block.Fields
+	tf := fields[params.TimeField]
+	if tf == nil || tf.Type != FieldTypeDate || tf.Attribute[0] != model.sort {
+		return nil, NewArgError(fmt.Sprintf(`Time-series field "%s" must be a date field mapped to the sort key`, params.TimeField))
+	}
+	bf := fields[params.BucketField]
+	if bf == nil || bf.Type != FieldTypeString {
+		return nil, NewArgError(fmt.Sprintf(`Time-series bucket "%s" must be a string field`, params.BucketField))
+	}
+	var hash *preparedField
+	for _, field := range fields {
+		if field.Attribute[0] == model.hash {
+			hash = field
+		}
+	}
+	if hash == nil || !slices.Contains(getTemplateVars(hash.Def.Value), params.BucketField) {
+		return nil, NewArgError(fmt.Sprintf(`Time-series hash key template must reference "${%s}"`, params.BucketField))
+	}
+	return &TimeSeries{model: model, timeField: params.TimeField, bucketField: params.BucketField, period: period}, nil
+}
+
+// Bucket returns the bucket value for t.
+func (ts *TimeSeries) Bucket(t time.Time) string {
+	return t.UTC().Format(bucketLayouts[ts.period])
+}
+
+// Write creates an event. The time field defaults to now and the bucket field
+// is derived from it.
+func (ts *TimeSeries) Write(ctx context.Context, properties Item, params *Params) (Item, error) {
+	props := maps.Clone(properties)
+	if props == nil {
+		props = Item{}
+	}
+	at, ok := props[ts.timeField].(time.Time)
+	if !ok {
+		if props[ts.timeField] != nil {
+			return nil, NewArgError(fmt.Sprintf(`Time-series field "%s" must be a time.Time`, ts.timeField))
+		}
+		at = time.Now()
+		props[ts.timeField] = at
+	}
+	props[ts.bucketField] = ts.Bucket(at)
+	return ts.model.Create(ctx, props, params)
+}
+
+// Query returns the events matching properties with a time in [from, to],
+// querying each bucket in the range in turn. Items are ordered by time, newest
+// first when params.Reverse is set. params.Limit caps the total number of
+// items; the result has no Next cursor.
+func (ts *TimeSeries) Query(ctx context.Context, properties Item, from, to time.Time, params *Params) (*Result, error) {
+	if to.Before(from) {
+		return nil, NewArgError("Time-series range end is before its start")
+	}
+	var base Params
+	if params != nil {
+		base = *params
+	}
+	buckets := ts.buckets(from, to)
+	if base.Reverse {
+		for i, j := 0, len(buckets)-1; i < j; i, j = i+1, j-1 {
+			buckets[i], buckets[j] = buckets[j], buckets[i]
+		}
+	}
+	result := &Result{Items: []Item{}, table: ts.model.table}
+	for _, bucket := range buckets {
+		props := maps.Clone(properties)
+		if props == nil {
+			props = Item{}
+		}
+		props[ts.bucketField] = bucket
+		props[ts.timeField] = map[string]any{"between": []any{from, to}}
+
+		var next Item
+		for {
+			p := base
+			p.Next = next
+			if base.Limit > 0 {
+				p.Limit = base.Limit - len(result.Items)
+			}
+			page, err := ts.model.Find(ctx, props, &p)
+			if err != nil {
+				return nil, err
+			}
+			result.Items = append(result.Items, page.Items...)
+			if base.Limit > 0 && len(result.Items) >= base.Limit {
+				result.Items = result.Items[:base.Limit]
+				return result, nil
+			}
+			if page.Next == nil {
+				break
+			}
+			next = page.Next
+		}
+	}
+	return result, nil
+}
+
+// buckets lists the bucket values spanned by [from, to] in ascending order.
+func (ts *TimeSeries) buckets(from, to time.Time) []string {
+	from, to = from.UTC(), to.UTC()
+	var t time.Time
+	switch ts.period {
+	case PeriodHour:
+		t = from.Truncate(time.Hour)
+	case PeriodDay:
+		t = time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.UTC)
+	case PeriodMonth:
+		t = time.Date(from.Year(), from.Month(), 1, 0, 0, 0, 0, time.UTC)
+	default:
+		t = time.Date(from.Year(), 1, 1, 0, 0, 0, 0, time.UTC)
+	}
+	var buckets []string
+	for !t.After(to) {
+		buckets = append(buckets, ts.Bucket(t))
+		switch ts.period {
+		case PeriodHour:
+			t = t.Add(time.Hour)
+		case PeriodDay:
+			t = t.AddDate(0, 0, 1)
+		case PeriodMonth:
+			t = t.AddDate(0, 1, 0)
+		default:
+			t = t.AddDate(1, 0, 0)
+		}
+	}
+	return buckets
+}